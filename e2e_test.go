@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/codec"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/consumer"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/httpjson"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/producer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeSchemaRegistry implements the subset of the Schema Registry API used by srclient:
+// latest version of a subject, registering a schema and fetching a schema by ID.
+type fakeSchemaRegistry struct {
+	mu       sync.Mutex
+	schemas  map[int]string
+	subjects map[string][]int
+}
+
+func newFakeSchemaRegistry(t *testing.T) *httptest.Server {
+	r := &fakeSchemaRegistry{schemas: make(map[int]string), subjects: make(map[string][]int)}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (r *fakeSchemaRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 3 && parts[0] == "schemas" && parts[1] == "ids" && req.Method == http.MethodGet:
+		id, _ := strconv.Atoi(parts[2])
+		schema, ok := r.schemas[id]
+		if !ok {
+			httpjson.Write(w, http.StatusNotFound, map[string]interface{}{"error_code": 40403, "message": "Schema not found"})
+			return
+		}
+		httpjson.Write(w, http.StatusOK, map[string]interface{}{"schema": schema})
+	case len(parts) == 4 && parts[0] == "subjects" && parts[3] == "latest" && req.Method == http.MethodGet:
+		ids := r.subjects[parts[1]]
+		if len(ids) == 0 {
+			httpjson.Write(w, http.StatusNotFound, map[string]interface{}{"error_code": 40401, "message": "Subject not found"})
+			return
+		}
+		id := ids[len(ids)-1]
+		httpjson.Write(w, http.StatusOK, map[string]interface{}{"subject": parts[1], "version": len(ids), "id": id, "schema": r.schemas[id]})
+	case len(parts) == 3 && parts[0] == "subjects" && parts[2] == "versions" && req.Method == http.MethodPost:
+		var body struct {
+			Schema string `json:"schema"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			httpjson.Write(w, http.StatusUnprocessableEntity, map[string]interface{}{"error_code": 42201, "message": err.Error()})
+			return
+		}
+		id := len(r.schemas) + 1
+		r.schemas[id] = body.Schema
+		r.subjects[parts[1]] = append(r.subjects[parts[1]], id)
+		httpjson.Write(w, http.StatusOK, map[string]interface{}{"id": id})
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// memoryTopic is an in-memory Kafka topic stand-in. It implements producer.Writer (key-hash
+// partitioning) and consumer.Reader (single group member owning every partition). Messages
+// become fetchable only after fetchDelay, like replication and fetch latency of a real
+// broker; the producer stores the Redis hash after the Kafka write returns.
+type memoryTopic struct {
+	mu         sync.Mutex
+	topic      string
+	fetchDelay time.Duration
+	parts      [][]kafka.Message
+	next       []int64 // per partition: next offset to fetch
+	committed  map[int]int64
+}
+
+func newMemoryTopic(topic string, partitions int, fetchDelay time.Duration) *memoryTopic {
+	return &memoryTopic{
+		topic:      topic,
+		fetchDelay: fetchDelay,
+		parts:      make([][]kafka.Message, partitions),
+		next:       make([]int64, partitions),
+		committed:  make(map[int]int64),
+	}
+}
+
+func (m *memoryTopic) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range msgs {
+		h := fnv.New32a()
+		h.Write(msg.Key)
+		p := int(h.Sum32() % uint32(len(m.parts)))
+		msg.Topic, msg.Partition, msg.Offset, msg.Time = m.topic, p, int64(len(m.parts[p])), time.Now()
+		m.parts[p] = append(m.parts[p], msg)
+	}
+	return nil
+}
+
+func (m *memoryTopic) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		if msg, ok := m.poll(); ok {
+			return msg, nil
+		}
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func (m *memoryTopic) poll() (kafka.Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for p := range m.parts {
+		if m.next[p] < int64(len(m.parts[p])) && time.Since(m.parts[p][m.next[p]].Time) >= m.fetchDelay {
+			msg := m.parts[p][m.next[p]]
+			m.next[p]++
+			return msg, true
+		}
+	}
+	return kafka.Message{}, false
+}
+
+func (m *memoryTopic) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range msgs {
+		m.committed[msg.Partition] = msg.Offset + 1
+	}
+	return nil
+}
+
+func (m *memoryTopic) Close() error { return nil }
+
+// committedTotal returns the number of messages covered by committed offsets.
+func (m *memoryTopic) committedTotal() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var total int64
+	for _, offset := range m.committed {
+		total += offset
+	}
+	return total
+}
+
+func TestProducerConsumerEndToEnd(t *testing.T) {
+	const topic = "e2e-topic"
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	mr := miniredis.RunT(t)
+	registry := newFakeSchemaRegistry(t)
+	kafkaTopic := newMemoryTopic(topic, 3, 20*time.Millisecond)
+
+	// Defaults with the endpoints of the fakes and timings short enough for a test
+	cfg := config.Default()
+	cfg.Topic = topic
+	cfg.GroupID = "e2e-group"
+	cfg.SchemaRegistryURL = registry.URL
+	cfg.RedisAddr = mr.Addr()
+	cfg.ProducerIntervalMs = 2
+	cfg.ProducerStartupDelay = 0
+	cfg.ConsumerWorkers = 2
+	cfg.ConsumerQueueSize = 10
+	cfg.ConsumerCommitInterval = 20 * time.Millisecond
+	cfg.RedisSpoolMaxRecords = 1000
+	cfg.RedisSpoolReplayInterval = time.Second
+	cfg.SchemaRegistryHTTP.Timeout = 5 * time.Second
+	cfg.SchemaRegistryHTTP.AttemptTimeout = time.Second
+	cfg.SchemaRegistryHTTP.BackoffMin = 10 * time.Millisecond
+	cfg.SchemaRegistryHTTP.BackoffMax = 50 * time.Millisecond
+	cfg.Clock.SyncInterval = 100 * time.Millisecond
+	cfg.Clock.SkewWindow = time.Second
+	cfg.SLO.Window = time.Hour
+	cfg.SLO.EvaluationInterval = 100 * time.Millisecond
+	if errs := cfg.Validate(); len(errs) > 0 {
+		t.Fatalf("invalid test configuration: %v", errs)
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	m := metrics.New(prometheus.NewRegistry())
+	received := func() float64 {
+		var total float64
+		for p := 0; p < 3; p++ {
+			total += testutil.ToFloat64(m.ConsumerMessagesReceivedTotal.WithLabelValues(topic, strconv.Itoa(p)))
+		}
+		return total
+	}
+	mismatches := func() float64 {
+		var total float64
+		for p := 0; p < 3; p++ {
+			total += testutil.ToFloat64(m.ConsumerRedisHashMismatchTotal.WithLabelValues(topic, strconv.Itoa(p)))
+		}
+		return total
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		p := producer.New(cfg, producer.Deps{
+			Writer:         kafkaTopic,
+			SchemaRegistry: codec.NewSchemaRegistryClient(cfg.SchemaRegistryURL, cfg.SchemaRegistryHTTP, nil, m, logger),
+			Redis:          rdb,
+			Metrics:        m,
+			Logger:         logger,
+		})
+		if err := p.Run(ctx); err != nil {
+			t.Errorf("producer: %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		c := consumer.New(cfg, consumer.Deps{
+			Reader:         kafkaTopic,
+			SchemaRegistry: codec.NewSchemaRegistryClient(cfg.SchemaRegistryURL, cfg.SchemaRegistryHTTP, nil, m, logger),
+			Redis:          rdb,
+			Metrics:        m,
+			Logger:         logger,
+		})
+		if err := c.Run(ctx); err != nil {
+			t.Errorf("consumer: %v", err)
+		}
+	}()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for received() < 100 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+
+	sent := testutil.ToFloat64(m.ProducerMessagesSentTotal.WithLabelValues(topic))
+	got := received()
+	if got < 100 {
+		t.Fatalf("consumer received %v messages, want at least 100 (sent %v)", got, sent)
+	}
+	if got > sent {
+		t.Errorf("consumer received %v messages, more than the %v sent", got, sent)
+	}
+	if n := mismatches(); n != 0 {
+		t.Errorf("%v hash mismatches, want 0", n)
+	}
+
+	sentTotal, _ := strconv.Atoi(mustGet(t, mr, "metrics:sent_total"))
+	receivedTotal, _ := strconv.Atoi(mustGet(t, mr, "metrics:received_total"))
+	// The hash write of the last message may be cut off by cancellation after the Kafka write.
+	if float64(sentTotal) != sent && float64(sentTotal) != sent-1 {
+		t.Errorf("redis sent_total=%d, want %v", sentTotal, sent)
+	}
+	if float64(receivedTotal) != got {
+		t.Errorf("redis received_total=%d, want %v", receivedTotal, got)
+	}
+	pending := 0
+	for _, key := range mr.Keys() {
+		if strings.HasPrefix(key, cfg.RedisKeyPrefix) {
+			pending++
+		}
+	}
+	if want := sentTotal - receivedTotal; pending != want {
+		t.Errorf("%d message keys pending in redis, want %d (sent - received)", pending, want)
+	}
+	if committed := kafkaTopic.committedTotal(); float64(committed) != got {
+		t.Errorf("committed offsets cover %d messages, want %v", committed, got)
+	}
+}
+
+func mustGet(t *testing.T, mr *miniredis.Miniredis, key string) string {
+	t.Helper()
+	v, err := mr.Get(key)
+	if err != nil {
+		t.Fatalf("redis GET %s: %v", key, err)
+	}
+	return v
+}
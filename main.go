@@ -2,9 +2,6 @@ package main
 
 import (
 	"context"
-	"crypto/sha256"
-	_ "embed"
-	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -12,965 +9,246 @@ import (
 	"os/signal"
 	"strconv"
 	"strings"
-	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/linkedin/goavro/v2"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/consumer"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/control"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/experiment"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/faults"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/guard"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/health"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/inspector"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/kafkaclient"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/producer"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/recovery"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/scenario"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
-	"github.com/riferrei/srclient"
-	"github.com/segmentio/kafka-go"
-	"github.com/segmentio/kafka-go/sasl/scram"
 )
 
-//go:embed message_template.json
-var defaultMessageTemplate []byte
-
-const (
-	ModeProducer = "producer"
-	ModeConsumer = "consumer"
-	messageIDPlaceholder = "{{message_id}}"
-)
-
-// Health status for probes
-var (
-	isReady   atomic.Bool
-	isHealthy atomic.Bool
-	logger    *slog.Logger
-)
-
-type Config struct {
-	Mode              string
-	Brokers           []string
-	Topic             string
-	SchemaRegistryURL string
-	Username          string
-	Password          string
-	GroupID           string
-	// Producer: batch settings (env KAFKA_PRODUCER_BATCH_SIZE, KAFKA_PRODUCER_BATCH_TIMEOUT_MS)
-	ProducerBatchSize    int
-	ProducerBatchTimeout time.Duration
-	// Producer: message rate (env PRODUCER_INTERVAL_MS)
-	ProducerIntervalMs int // ms between messages, 100 = 10 msg/s per producer
-	// Producer: max retry attempts (env KAFKA_PRODUCER_MAX_ATTEMPTS)
-	ProducerMaxAttempts int
-	// Consumer: fetch settings (env KAFKA_CONSUMER_MIN_BYTES, MAX_BYTES, MAX_WAIT_MS)
-	ConsumerMinBytes  int
-	ConsumerMaxBytes  int
-	ConsumerMaxWaitMs int
-	// Redis: store hash of message value for delivery verification and SLO
-	RedisAddr       string
-	RedisPassword   string
-	RedisKeyPrefix  string
-	RedisSLOSeconds int // messages still in Redis older than this are counted as SLO breach
-}
-
-type Message struct {
-	ID        int64     `json:"id"`
-	Timestamp time.Time `json:"timestamp"`
-	Data      string    `json:"data"`
-}
+func main() {
+	// "plan <scenario.yaml>..." validates chaos scenarios and prints their timeline instead of running
+	if len(os.Args) > 1 && os.Args[1] == "plan" {
+		os.Exit(planScenarios(os.Args[2:]))
+	}
 
-func init() {
 	// Initialize JSON logger
-	logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	}))
 	slog.SetDefault(logger)
-}
-
-// loadMessageTemplate returns the message template (from file or embedded default).
-func loadMessageTemplate() string {
-	if path := os.Getenv("MESSAGE_TEMPLATE_FILE"); path != "" {
-		b, err := os.ReadFile(path)
-		if err != nil {
-			logger.Warn("Failed to read MESSAGE_TEMPLATE_FILE, using embedded template", "path", path, "error", err)
-			return string(defaultMessageTemplate)
-		}
-		return string(b)
-	}
-	return string(defaultMessageTemplate)
-}
-
-// buildMessageData substitutes placeholders in the template with actual values.
-func buildMessageData(template string, messageID int64) string {
-	return strings.ReplaceAll(template, messageIDPlaceholder, strconv.FormatInt(messageID, 10))
-}
-
-func main() {
-	config := loadConfig()
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Start health check server
-	go startHealthServer()
-
-	// Handle graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	go func() {
-		<-sigChan
-		logger.Info("Shutting down...")
-		isHealthy.Store(false)
-		isReady.Store(false)
-		cancel()
-	}()
-
-	switch config.Mode {
-	case ModeProducer:
-		runProducer(ctx, config)
-	case ModeConsumer:
-		runConsumer(ctx, config)
-	default:
-		logger.Error("Invalid mode", "mode", config.Mode, "valid_modes", []string{ModeProducer, ModeConsumer})
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Error("Invalid configuration", "errors", strings.Split(err.Error(), "\n"))
 		os.Exit(1)
 	}
-}
-
-// startHealthServer starts HTTP server for health probes
-func startHealthServer() {
-	healthPort := os.Getenv("HEALTH_PORT")
-	if healthPort == "" {
-		healthPort = "8080"
-	}
-
-	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		if isHealthy.Load() {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("ok"))
-		} else {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			w.Write([]byte("not healthy"))
-		}
-	})
-
-	http.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
-		if isReady.Load() {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("ok"))
-		} else {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			w.Write([]byte("not ready"))
-		}
-	})
-
-	http.HandleFunc("/livez", func(w http.ResponseWriter, r *http.Request) {
-		// Liveness is always ok if server is running
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("ok"))
-	})
-
-	// Prometheus metrics endpoint
-	http.Handle("/metrics", promhttp.Handler())
-
-	logger.Info("Starting health server", "port", healthPort)
-	if err := http.ListenAndServe(":"+healthPort, nil); err != nil {
-		logger.Error("Health server error", "error", err)
-	}
-}
-
-func loadConfig() *Config {
-	mode := os.Getenv("MODE")
-	if mode == "" {
-		mode = ModeProducer
-	}
-
-	brokers := os.Getenv("KAFKA_BROKERS")
-	if brokers == "" {
-		brokers = "localhost:9092"
-	}
-
-	topic := os.Getenv("KAFKA_TOPIC")
-	if topic == "" {
-		topic = "test-topic"
-	}
-
-	schemaRegistryURL := os.Getenv("SCHEMA_REGISTRY_URL")
-	if schemaRegistryURL == "" {
-		schemaRegistryURL = "http://localhost:8081"
-	}
-
-	username := os.Getenv("KAFKA_USERNAME")
-	password := os.Getenv("KAFKA_PASSWORD")
-
-	groupID := os.Getenv("KAFKA_GROUP_ID")
-	if groupID == "" {
-		groupID = "test-group"
-	}
-
-	redisAddr := os.Getenv("REDIS_ADDR")
-	if redisAddr == "" {
-		redisAddr = "localhost:6379"
-	}
-	redisPassword := os.Getenv("REDIS_PASSWORD")
-	redisKeyPrefix := os.Getenv("REDIS_KEY_PREFIX")
-	if redisKeyPrefix == "" {
-		redisKeyPrefix = "kafka-msg:"
-	}
-	redisSLOSeconds := 120
-	if s := os.Getenv("REDIS_SLO_SECONDS"); s != "" {
-		if n, err := strconv.Atoi(s); err == nil && n > 0 {
-			redisSLOSeconds = n
-		}
-	}
-
-	producerBatchSize := 50
-	if s := os.Getenv("KAFKA_PRODUCER_BATCH_SIZE"); s != "" {
-		if n, err := strconv.Atoi(s); err == nil && n > 0 {
-			producerBatchSize = n
-		}
-	}
-	producerBatchTimeout := 50 * time.Millisecond
-	if s := os.Getenv("KAFKA_PRODUCER_BATCH_TIMEOUT_MS"); s != "" {
-		if n, err := strconv.Atoi(s); err == nil && n > 0 {
-			producerBatchTimeout = time.Duration(n) * time.Millisecond
-		}
-	}
-
-	producerIntervalMs := 100
-	if s := os.Getenv("PRODUCER_INTERVAL_MS"); s != "" {
-		if n, err := strconv.Atoi(s); err == nil && n > 0 {
-			producerIntervalMs = n
-		}
-	}
-
-	producerMaxAttempts := 5
-	if s := os.Getenv("KAFKA_PRODUCER_MAX_ATTEMPTS"); s != "" {
-		if n, err := strconv.Atoi(s); err == nil && n > 0 {
-			producerMaxAttempts = n
-		}
-	}
-
-	consumerMinBytes := 5000 // 5KB - ждать накопления 5KB перед возвратом данных
-	if s := os.Getenv("KAFKA_CONSUMER_MIN_BYTES"); s != "" {
-		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
-			consumerMinBytes = n
-		}
-	}
-	consumerMaxBytes := 100 * 1024 * 1024 // 100MB
-	if s := os.Getenv("KAFKA_CONSUMER_MAX_BYTES"); s != "" {
-		if n, err := strconv.Atoi(s); err == nil && n > 0 {
-			consumerMaxBytes = n
-		}
-	}
-	consumerMaxWaitMs := 500
-	if s := os.Getenv("KAFKA_CONSUMER_MAX_WAIT_MS"); s != "" {
-		if n, err := strconv.Atoi(s); err == nil && n > 0 {
-			consumerMaxWaitMs = n
-		}
-	}
-
-	return &Config{
-		Mode:              mode,
-		Brokers:           parseBrokers(brokers),
-		Topic:             topic,
-		SchemaRegistryURL: schemaRegistryURL,
-		Username:          username,
-		Password:          password,
-		GroupID:           groupID,
-		RedisAddr:             redisAddr,
-		RedisPassword:         redisPassword,
-		RedisKeyPrefix:        redisKeyPrefix,
-		RedisSLOSeconds:       redisSLOSeconds,
-		ProducerBatchSize:     producerBatchSize,
-		ProducerBatchTimeout:  producerBatchTimeout,
-		ProducerIntervalMs:    producerIntervalMs,
-		ProducerMaxAttempts:   producerMaxAttempts,
-		ConsumerMinBytes:      consumerMinBytes,
-		ConsumerMaxBytes:      consumerMaxBytes,
-		ConsumerMaxWaitMs:     consumerMaxWaitMs,
-	}
-}
-
-func parseBrokers(brokers string) []string {
-	if brokers == "" {
-		return []string{"localhost:9092"}
-	}
-	// Split by comma and trim whitespace
-	parts := strings.Split(brokers, ",")
-	result := make([]string, 0, len(parts))
-	for _, part := range parts {
-		trimmed := strings.TrimSpace(part)
-		if trimmed != "" {
-			result = append(result, trimmed)
-		}
-	}
-	if len(result) == 0 {
-		return []string{"localhost:9092"}
-	}
-	return result
-}
-
-// hashValue returns SHA256 hex of data (used for backward compatibility).
-func hashValue(data []byte) string {
-	h := sha256.Sum256(data)
-	return hex.EncodeToString(h[:])
-}
-
-// hashContent returns SHA256 hex of message body (id + data only). Used for delivery
-// verification so that timestamp differences (retries/duplicates) do not count as mismatch.
-func hashContent(id int64, data string) string {
-	h := sha256.Sum256([]byte(fmt.Sprintf("%d\n%s", id, data)))
-	return hex.EncodeToString(h[:])
-}
-
-// extractIDAndData returns id and data from decoded Avro message for content-hash verification.
-// Returns (nil, "") if decoded is not a map or id/data are missing or have wrong types.
-func extractIDAndData(decoded interface{}) (*int64, string) {
-	m, ok := decoded.(map[string]interface{})
-	if !ok {
-		return nil, ""
-	}
-	var id int64
-	switch v := m["id"].(type) {
-	case int64:
-		id = v
-	case int32:
-		id = int64(v)
-	case int:
-		id = int64(v)
-	case float64:
-		id = int64(v)
-	default:
-		return nil, ""
-	}
-	data, _ := m["data"].(string)
-	return &id, data
-}
-
-const (
-	redisKeySentTotal    = "metrics:sent_total"
-	redisKeyReceivedTotal = "metrics:received_total"
-)
-
-func newRedisClient(cfg *Config) *redis.Client {
-	if cfg.RedisAddr == "" {
-		return nil
-	}
-	opts := &redis.Options{Addr: cfg.RedisAddr}
-	if cfg.RedisPassword != "" {
-		opts.Password = cfg.RedisPassword
+	logger.Info("Configuration loaded", "config", cfg.Dump())
+	m := metrics.New(prometheus.DefaultRegisterer)
+	// Active chaos experiments label the error counters and every log line from here on
+	experiments := experiment.NewTracker(m, logger, experimentAnnotators(cfg)...)
+	logger = slog.New(experiment.LogHandler(logger.Handler(), experiments))
+	slog.SetDefault(logger)
+	if cfg.Experiments.Name != "" {
+		experiments.Start(experiment.Experiment{Name: cfg.Experiments.Name, Source: experiment.SourceEnv})
 	}
-	return redis.NewClient(opts)
-}
-
-// redisMsgKey returns Redis key for a message: prefix + Kafka message key.
-func redisMsgKey(prefix, kafkaKey string) string {
-	return prefix + kafkaKey
-}
-
-func runProducer(ctx context.Context, config *Config) {
-	logger.Info("Starting producer", "brokers", config.Brokers, "topic", config.Topic)
+	// Time to recover after each experiment, from the signals this mode sees (RECOVERY_* settings)
+	recoveries := recovery.New(cfg.Recovery, recovery.Signals(cfg, m), experiments, m, logger)
+	// Readiness follows periodic checks of Kafka, Schema Registry and Redis (HEALTH_* settings)
+	status := health.NewStatus(cfg.Health, m)
 
-	// Mark as healthy (process is running)
-	isHealthy.Store(true)
-
-	// Create writer with simplified configuration
-	writer := &kafka.Writer{
-		Addr:                   kafka.TCP(config.Brokers...),
-		Topic:                  config.Topic,
-		Balancer:               &kafka.LeastBytes{},
-		RequiredAcks:           kafka.RequireAll,
-		AllowAutoTopicCreation: true,
-		BatchSize:              config.ProducerBatchSize,
-		BatchTimeout:           config.ProducerBatchTimeout,
-		MaxAttempts:            config.ProducerMaxAttempts,
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go status.RunChecks(ctx)
+	go recoveries.Run(ctx)
 
-	// Add SASL/SCRAM authentication if credentials provided
-	if config.Username != "" && config.Password != "" {
-		mechanism, err := scram.Mechanism(scram.SHA512, config.Username, config.Password)
+	var chaosGuard *guard.Guard
+	// Chaos Mesh resources in CHAOS_MESH_NAMESPACES start and end experiments as they are injected and recovered
+	if namespaces := cfg.Experiments.ChaosMeshNamespaces; len(namespaces) > 0 {
+		client, err := experiment.InClusterClient()
 		if err != nil {
-			logger.Error("Failed to create SCRAM mechanism", "error", err)
+			logger.Error("Failed to create Kubernetes client for the Chaos Mesh watcher", "error", err)
 			os.Exit(1)
 		}
-		writer.Transport = &kafka.Transport{
-			SASL: mechanism,
-		}
-	}
-	defer writer.Close()
-
-	// Setup Schema Registry client
-	schemaRegistryClient := srclient.CreateSchemaRegistryClient(config.SchemaRegistryURL)
-	// Schema Registry (Karapace) may take some time to respond after rollout/port-forward.
-	// Bump HTTP timeout to avoid flaky startup failures.
-	schemaRegistryClient.SetTimeout(2 * time.Minute)
-
-	// Get or create Avro schema
-	schema, err := getOrCreateSchema(schemaRegistryClient, config.Topic)
+		go experiment.NewChaosMeshWatcher(client, namespaces, cfg.Experiments.ChaosMeshResync, experiments, m, logger).Run(ctx)
+		// The guard deletes all chaos in those namespaces when an abort condition holds (GUARD_PROMETHEUS_URL)
+		if cfg.Guard.PrometheusURL != "" {
+			chaosGuard = guard.New(cfg.Guard, namespaces, client, m, logger)
+			go chaosGuard.Run(ctx)
+		}
+	}
+
+	// Health probes, Prometheus metrics, the effective config, control and fault APIs share one server
+	mux := http.NewServeMux()
+	status.Register(mux)
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/config", cfg)
+
+	// Runtime control of pause/resume, rate, payload and topic, protected by CONTROL_TOKEN
+	ctl := control.FromConfig(cfg, m, logger)
+	if cfg.ControlToken != "" {
+		controlAPI := control.RequireToken(cfg.ControlToken, ctl)
+		mux.Handle("/control", controlAPI)
+		mux.Handle("/control/", controlAPI)
+		experimentAPI := control.RequireToken(cfg.ControlToken, experiments)
+		mux.Handle("/control/experiment", experimentAPI)
+		mux.Handle("/control/experiment/", experimentAPI)
+		mux.Handle("/control/recovery", control.RequireToken(cfg.ControlToken, recoveries))
+		if chaosGuard != nil {
+			guardAPI := control.RequireToken(cfg.ControlToken, chaosGuard)
+			mux.Handle("/control/guard", guardAPI)
+			mux.Handle("/control/guard/", guardAPI)
+		}
+		logger.Info("Control API enabled", "path", "/control")
+	}
+
+	// Optional client-side fault injection for Kafka, Schema Registry and Redis (before clients are created);
+	// its API at /faults is protected by CONTROL_TOKEN like the control API
+	faultAPI := http.NewServeMux()
+	kafkaFaults := faults.SetupKafka(ctx, cfg.Faults.Kafka, faultAPI, m, logger)
+	schemaRegistryFaults := faults.SetupSchemaRegistry(ctx, cfg.Faults.SchemaRegistry, faultAPI, m, logger)
+	redisFaults := faults.SetupRedis(ctx, cfg.Faults.Redis, faultAPI, m, logger)
+	switch {
+	case cfg.ControlToken != "":
+		mux.Handle("/faults/", control.RequireToken(cfg.ControlToken, faultAPI))
+	case kafkaFaults != nil || schemaRegistryFaults != nil || redisFaults != nil:
+		logger.Warn("Fault injection API is not protected, set CONTROL_TOKEN", "path", "/faults")
+		mux.Handle("/faults/", faultAPI)
+	}
+	// TLS of broker connections; rotated certificate files are reloaded (KAFKA_TLS_* settings)
+	kafkaTLS, err := kafkaclient.NewTLS(cfg.TLS, m, logger)
 	if err != nil {
-		logger.Error("Failed to get/create schema", "error", err)
+		logger.Error("Failed to set up Kafka TLS", "error", err)
 		os.Exit(1)
 	}
-
-	codec, err := goavro.NewCodec(schema.Schema())
+	go kafkaTLS.Run(ctx)
+	// SASL mechanism with username/password or OAuth tokens (KAFKA_SASL_MECHANISM, KAFKA_OAUTH_* settings)
+	kafkaSASL, err := kafkaclient.NewSASL(cfg, m, logger)
 	if err != nil {
-		logger.Error("Failed to create Avro codec", "error", err)
+		logger.Error("Failed to set up Kafka SASL", "error", err)
 		os.Exit(1)
 	}
-
-	// Wait for metadata to be fetched
-	logger.Info("Waiting for Kafka metadata...")
-	time.Sleep(5 * time.Second)
-
-	// Mark connection as connected
-	for _, broker := range config.Brokers {
-		kafkaConnectionStatus.WithLabelValues(broker).Set(1)
-	}
-	schemaRegistryConnectionStatus.Set(1)
-
-	// Redis client for delivery verification (hash + SLO)
-	rdb := newRedisClient(config)
-	if rdb != nil {
-		defer rdb.Close()
-		if err := rdb.Ping(ctx).Err(); err != nil {
-			logger.Warn("Redis ping failed, hash storage disabled", "error", err)
-			rdb = nil
-		} else {
-			logger.Info("Redis connected for hash storage")
-		}
-	}
-
-	// Mark as ready (connected to Kafka and Schema Registry)
-	isReady.Store(true)
-	logger.Info("Producer is ready")
-
-	messageTemplate := loadMessageTemplate()
-	messageID := int64(0)
-	interval := time.Duration(config.ProducerIntervalMs) * time.Millisecond
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			logger.Info("Producer stopped")
-			return
-		case <-ticker.C:
-			messageID++
-			msgStartTime := time.Now()
-			data := buildMessageData(messageTemplate, messageID)
-			msg := Message{
-				ID:        messageID,
-				Timestamp: time.Now(),
-				Data:      data,
-			}
-
-			// Convert message to Avro with Confluent wire format
-			encodeStart := time.Now()
-			avroData, err := encodeAvroMessage(codec, schema.ID(), msg)
-			encodeDuration := time.Since(encodeStart).Seconds()
-			producerMessageEncodeDuration.WithLabelValues(config.Topic).Observe(encodeDuration)
-
-			if err != nil {
-				logger.Error("Failed to encode message", "error", err, "message_id", messageID)
-				producerErrorsTotal.WithLabelValues(config.Topic, "encode").Inc()
-				continue
-			}
-
-			// Prepare Kafka message (schema ID is now embedded in the value)
-			kafkaKey := fmt.Sprintf("key-%d", messageID)
-			kafkaMsg := kafka.Message{
-				Key:   []byte(kafkaKey),
-				Value: avroData,
-			}
-
-			err = writer.WriteMessages(ctx, kafkaMsg)
-			totalDuration := time.Since(msgStartTime).Seconds()
-
-			if err != nil {
-				logger.Error("Failed to write message", "error", err, "message_id", messageID)
-				producerErrorsTotal.WithLabelValues(config.Topic, "send").Inc()
-				// Mark connection as disconnected on error
-				for _, broker := range config.Brokers {
-					kafkaConnectionStatus.WithLabelValues(broker).Set(0)
-				}
-				continue
-			}
-
-			// Mark connection as connected after successful write
-			for _, broker := range config.Brokers {
-				kafkaConnectionStatus.WithLabelValues(broker).Set(1)
-			}
-
-			// Store content hash in Redis (id+data only, so timestamp retries don't cause mismatch): key = same as Kafka key, value = contentHash:timestamp_ms (for SLO)
-			if rdb != nil {
-				contentHash := hashContent(msg.ID, msg.Data)
-				redisKey := redisMsgKey(config.RedisKeyPrefix, kafkaKey)
-				redisVal := contentHash + ":" + strconv.FormatInt(time.Now().UnixMilli(), 10)
-				if err := rdb.Set(ctx, redisKey, redisVal, 0).Err(); err != nil {
-					logger.Warn("Redis SET failed", "key", redisKey, "error", err)
-				}
-				if err := rdb.Incr(ctx, redisKeySentTotal).Err(); err != nil {
-					logger.Warn("Redis INCR sent_total failed", "error", err)
-				}
-			}
-
-			// Update metrics
-			producerMessagesSentTotal.WithLabelValues(config.Topic).Inc()
-			producerMessagesSentBytes.WithLabelValues(config.Topic).Add(float64(len(avroData)))
-			producerMessageSendDuration.WithLabelValues(config.Topic).Observe(totalDuration)
-
-			logger.Info("Sent message", "message_id", messageID)
-		}
-	}
-}
-
-func runConsumer(ctx context.Context, config *Config) {
-	logger.Info("Starting consumer", "brokers", config.Brokers, "topic", config.Topic, "group_id", config.GroupID)
-
-	// Mark as healthy (process is running)
-	isHealthy.Store(true)
-
-	// Setup Kafka dialer
-	dialer := &kafka.Dialer{
-		Timeout:   10 * time.Second,
-		DualStack: true,
-	}
-
-	// Add SASL/SCRAM authentication if credentials provided
-	if config.Username != "" && config.Password != "" {
-		mechanism, err := scram.Mechanism(scram.SHA512, config.Username, config.Password)
-		if err != nil {
-			logger.Error("Failed to create SCRAM mechanism", "error", err)
-			os.Exit(1)
-		}
-		dialer.SASLMechanism = mechanism
-	}
-
-	// Setup Kafka reader
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  config.Brokers,
-		Topic:    config.Topic,
-		GroupID:  config.GroupID,
-		MinBytes: config.ConsumerMinBytes, // 5KB по умолчанию - ждать накопления перед ответом
-		MaxBytes: config.ConsumerMaxBytes, // 100MB - при высокой нагрузке читать до 100MB за раз
-		MaxWait:  time.Duration(config.ConsumerMaxWaitMs) * time.Millisecond,
-		Dialer:   dialer,
-	})
-	defer reader.Close()
-
-	// Setup Schema Registry client
-	schemaRegistryClient := srclient.CreateSchemaRegistryClient(config.SchemaRegistryURL)
-	// See producer: avoid flaky startup failures when SR is still warming up.
-	schemaRegistryClient.SetTimeout(2 * time.Minute)
-
-	// Mark connection as connected
-	for _, broker := range config.Brokers {
-		kafkaConnectionStatus.WithLabelValues(broker).Set(1)
-	}
-	schemaRegistryConnectionStatus.Set(1)
-
-	// Setup Admin client for lag metrics
-	transport := &kafka.Transport{
-		SASL: dialer.SASLMechanism,
-	}
-	adminClient := &kafka.Client{
-		Addr:      kafka.TCP(config.Brokers...),
-		Timeout:   10 * time.Second,
-		Transport: transport,
-	}
-
-	// Redis client for delivery verification and SLO
-	rdb := newRedisClient(config)
-	if rdb != nil {
-		defer rdb.Close()
-		if err := rdb.Ping(ctx).Err(); err != nil {
-			logger.Warn("Redis ping failed, delivery verification disabled", "error", err)
-			rdb = nil
-		} else {
-			logger.Info("Redis connected for delivery verification")
-		}
-	}
-
-	// Start lag metrics updater in background
-	go updateConsumerLag(ctx, adminClient, dialer, config)
-
-	// Start Redis SLO metrics updater (pending count and old-pending count)
-	if rdb != nil && config.RedisSLOSeconds > 0 {
-		go updateRedisSLOMetrics(ctx, rdb, config)
+	kafkaSecurity := kafkaclient.Security{SASL: kafkaSASL, TLS: kafkaTLS.Config()}
+	// Tracing of messages across producer, Kafka and consumer (TRACING_EXPORTER)
+	shutdownTracing, err := tracing.Setup(ctx, cfg, logger)
+	if err != nil {
+		logger.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
 	}
 
-	// Mark as ready (connected to Kafka and Schema Registry)
-	isReady.Store(true)
-	logger.Info("Consumer is ready")
-
-	for {
-		select {
-		case <-ctx.Done():
-			logger.Info("Consumer stopped")
-			return
-		default:
-			readStart := time.Now()
-			msg, err := reader.ReadMessage(ctx)
-			if err != nil {
-				if err == context.Canceled {
-					return
-				}
-				logger.Error("Error reading message", "error", err)
-				consumerErrorsTotal.WithLabelValues(config.Topic, "read").Inc()
-				// Mark connection as disconnected on error
-				for _, broker := range config.Brokers {
-					kafkaConnectionStatus.WithLabelValues(broker).Set(0)
-				}
-				time.Sleep(1 * time.Second)
-				continue
-			}
-
-			// Mark connection as connected after successful read
-			for _, broker := range config.Brokers {
-				kafkaConnectionStatus.WithLabelValues(broker).Set(1)
-			}
-
-			partitionStr := fmt.Sprintf("%d", msg.Partition)
-
-			// Decode message using Confluent wire format
-			decodeStart := time.Now()
-			decoded, err := decodeAvroMessage(schemaRegistryClient, msg.Value)
-			decodeDuration := time.Since(decodeStart).Seconds()
-			consumerMessageDecodeDuration.WithLabelValues(config.Topic, partitionStr).Observe(decodeDuration)
-
-			if err != nil {
-				logger.Error("Failed to decode message", "error", err)
-				consumerErrorsTotal.WithLabelValues(config.Topic, "decode").Inc()
-				continue
-			}
-
-			processingDuration := time.Since(readStart).Seconds()
-			consumerMessageProcessingDuration.WithLabelValues(config.Topic, partitionStr).Observe(processingDuration)
-
-			// Delivery verification via Redis: compare content hash (id+data only). Match → same message (timestamp difference OK). Mismatch → body changed, data integrity issue.
-			if rdb != nil {
-				redisKey := redisMsgKey(config.RedisKeyPrefix, string(msg.Key))
-				stored, err := rdb.Get(ctx, redisKey).Result()
-				if err == nil {
-					parts := strings.SplitN(stored, ":", 2)
-					expectedStoredHash := parts[0]
-					var contentHashGot string
-					if id, data := extractIDAndData(decoded); id != nil && data != "" {
-						contentHashGot = hashContent(*id, data)
-					}
-					if contentHashGot != "" && contentHashGot == expectedStoredHash {
-						if err := rdb.Del(ctx, redisKey).Err(); err != nil {
-							logger.Warn("Redis DEL failed", "key", redisKey, "error", err)
-						}
-						if err := rdb.Incr(ctx, redisKeyReceivedTotal).Err(); err != nil {
-							logger.Warn("Redis INCR received_total failed", "error", err)
-						}
-					} else if contentHashGot != "" {
-						logger.Error("Body mismatch: message body (id+data) does not match Redis", "key", redisKey, "expected", expectedStoredHash, "got", contentHashGot)
-						consumerRedisHashMismatchTotal.WithLabelValues(config.Topic, partitionStr).Inc()
-					} else {
-						// Fallback: no id/data in decoded (e.g. old schema); compare full value hash for backward compat
-						fullHash := hashValue(msg.Value)
-						if fullHash == expectedStoredHash {
-							if err := rdb.Del(ctx, redisKey).Err(); err != nil {
-								logger.Warn("Redis DEL failed", "key", redisKey, "error", err)
-							}
-							if err := rdb.Incr(ctx, redisKeyReceivedTotal).Err(); err != nil {
-								logger.Warn("Redis INCR received_total failed", "error", err)
-							}
-						}
-					}
-				} else if err != redis.Nil {
-					logger.Warn("Redis GET failed", "key", redisKey, "error", err)
-				}
-				// If redis.Nil: key not in Redis (e.g. producer didn't use Redis or already deleted)
-			}
-
-			// Calculate end-to-end latency if message has timestamp
-			if decodedMap, ok := decoded.(map[string]interface{}); ok {
-				if timestampVal, ok := decodedMap["timestamp"]; ok {
-					var msgTimestamp time.Time
-					switch v := timestampVal.(type) {
-					case time.Time:
-						// goavro may decode timestamp-millis logicalType as time.Time
-						msgTimestamp = v
-					case int64:
-						msgTimestamp = time.UnixMilli(v)
-					case int32:
-						msgTimestamp = time.UnixMilli(int64(v))
-					case int:
-						msgTimestamp = time.UnixMilli(int64(v))
-					case float64:
-						msgTimestamp = time.UnixMilli(int64(v))
-					case float32:
-						msgTimestamp = time.UnixMilli(int64(v))
-					default:
-						logger.Debug("Timestamp has unsupported type", "type", fmt.Sprintf("%T", v), "value", v)
-						// Try to continue without end-to-end latency metric
-					}
-					if !msgTimestamp.IsZero() {
-						endToEndLatency := time.Since(msgTimestamp).Seconds()
-						consumerEndToEndLatency.WithLabelValues(config.Topic, partitionStr).Observe(endToEndLatency)
-					}
-				}
-			}
-
-			// Update metrics
-			consumerMessagesReceivedTotal.WithLabelValues(config.Topic, partitionStr).Inc()
-			consumerMessagesReceivedBytes.WithLabelValues(config.Topic, partitionStr).Add(float64(len(msg.Value)))
-
-			logger.Info("Received message", "key", string(msg.Key), "value", decoded, "partition", msg.Partition, "offset", msg.Offset)
-		}
+	// The tracing hook comes first so that injected Redis faults show up in the spans
+	var redisHooks []redis.Hook
+	if cfg.Tracing.Exporter != config.TracingNone {
+		redisHooks = append(redisHooks, tracing.RedisHook())
 	}
-}
-
-// updateRedisSLOMetrics periodically counts pending messages in Redis and those older than SLO threshold.
-func updateRedisSLOMetrics(ctx context.Context, rdb *redis.Client, config *Config) {
-	ticker := time.NewTicker(15 * time.Second)
-	defer ticker.Stop()
-	sloThreshold := time.Duration(config.RedisSLOSeconds) * time.Second
-	prefix := config.RedisKeyPrefix
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			var pending, oldPending int
-			iter := rdb.Scan(ctx, 0, prefix+"*", 100).Iterator()
-			for iter.Next(ctx) {
-				key := iter.Val()
-				if key == redisKeySentTotal || key == redisKeyReceivedTotal {
-					continue
-				}
-				pending++
-				val, err := rdb.Get(ctx, key).Result()
-				if err != nil {
-					continue
-				}
-				parts := strings.SplitN(val, ":", 2)
-				if len(parts) != 2 {
-					continue
-				}
-				tsMs, err := strconv.ParseInt(parts[1], 10, 64)
-				if err != nil {
-					continue
-				}
-				if time.Since(time.UnixMilli(tsMs)) > sloThreshold {
-					oldPending++
-				}
-			}
-			if err := iter.Err(); err != nil {
-				logger.Debug("Redis SCAN error", "error", err)
-				continue
-			}
-			redisPendingMessages.Set(float64(pending))
-			redisPendingOldMessages.Set(float64(oldPending))
-		}
+	if redisFaults != nil {
+		redisHooks = append(redisHooks, redisFaults)
 	}
-}
-
-// updateConsumerLag periodically updates consumer lag metrics
-func updateConsumerLag(ctx context.Context, adminClient *kafka.Client, dialer *kafka.Dialer, config *Config) {
-	ticker := time.NewTicker(30 * time.Second) // Update every 30 seconds
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			// Connect to first broker to get partition info
-			conn, err := dialer.DialContext(ctx, "tcp", config.Brokers[0])
-			if err != nil {
-				logger.Debug("Failed to dial broker for lag metrics", "error", err)
-				continue
-			}
-
-			// Get all partitions for the topic
-			partitions, err := conn.ReadPartitions(config.Topic)
-			if err != nil {
-				logger.Debug("Failed to read partitions", "error", err)
-				conn.Close()
-				continue
-			}
-
-			// Build map of partitions for Admin API
-			partitionIDs := make([]int, 0, len(partitions))
-			for _, p := range partitions {
-				partitionIDs = append(partitionIDs, p.ID)
-			}
 
-			// Get consumer group committed offsets using OffsetFetch API
-			offsetFetchResp, err := adminClient.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
-				GroupID: config.GroupID,
-				Topics: map[string][]int{
-					config.Topic: partitionIDs,
-				},
-			})
-			if err != nil {
-				logger.Debug("Failed to get consumer group offsets", "error", err)
-				conn.Close()
-				continue
-			}
-
-			// Build OffsetRequests for high watermark (LastOffset per partition)
-			offsetReqs := make([]kafka.OffsetRequest, len(partitionIDs))
-			for i, id := range partitionIDs {
-				offsetReqs[i] = kafka.LastOffsetOf(id)
-			}
-			listOffsetsResp, err := adminClient.ListOffsets(ctx, &kafka.ListOffsetsRequest{
-				Topics: map[string][]kafka.OffsetRequest{
-					config.Topic: offsetReqs,
-				},
-			})
-			if err != nil {
-				logger.Debug("Failed to get partition offsets", "error", err)
-				conn.Close()
-				continue
-			}
-
-			// For each partition, calculate lag
-			for _, partition := range partitions {
-				// Get high watermark from ListOffsets response
-				highWatermark := int64(-1)
-				if topicOffsets, ok := listOffsetsResp.Topics[config.Topic]; ok {
-					for _, po := range topicOffsets {
-						if po.Partition == partition.ID {
-							highWatermark = po.LastOffset
-							break
-						}
-					}
-				}
-				if highWatermark < 0 {
-					logger.Debug("Failed to get high watermark", "partition", partition.ID)
-					continue
-				}
+	// Start health check server
+	go health.NewServer(":"+strconv.Itoa(cfg.HealthPort), mux, logger).Run(ctx)
 
-				// Get consumer group committed offset from OffsetFetch response
-				consumerOffset := int64(-1)
-				if topicParts, ok := offsetFetchResp.Topics[config.Topic]; ok {
-					for _, p := range topicParts {
-						if p.Partition == partition.ID {
-							consumerOffset = p.CommittedOffset
-							break
-						}
-					}
-				}
+	// Handle graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-				// Calculate lag: highWatermark - consumerOffset
-				if consumerOffset >= 0 {
-					lag := highWatermark - consumerOffset
-					if lag < 0 {
-						lag = 0
-					}
-					partitionStr := fmt.Sprintf("%d", partition.ID)
-					consumerLag.WithLabelValues(config.Topic, partitionStr, config.GroupID).Set(float64(lag))
-				}
-			}
+	go func() {
+		<-sigChan
+		logger.Info("Shutting down...")
+		status.SetHealthy(false)
+		status.SetReady(false)
+		cancel()
+	}()
 
-			conn.Close()
-		}
+	switch cfg.Mode {
+	case config.ModeProducer:
+		err = producer.New(cfg, producer.Deps{
+			KafkaDial:               kafkaFaults.DialFunc(),
+			KafkaSecurity:           kafkaSecurity,
+			SchemaRegistryTransport: schemaRegistryFaults.Wrap(http.DefaultTransport),
+			RedisHooks:              redisHooks,
+			Control:                 ctl,
+			Experiments:             experiments,
+			Metrics:                 m,
+			Health:                  status,
+			Logger:                  logger,
+		}).Run(ctx)
+	case config.ModeConsumer:
+		err = consumer.New(cfg, consumer.Deps{
+			KafkaDial:               kafkaFaults.DialFunc(),
+			KafkaSecurity:           kafkaSecurity,
+			SchemaRegistryTransport: schemaRegistryFaults.Wrap(http.DefaultTransport),
+			RedisHooks:              redisHooks,
+			Control:                 ctl,
+			Experiments:             experiments,
+			Metrics:                 m,
+			Health:                  status,
+			Logger:                  logger,
+		}).Run(ctx)
+	case config.ModeInspector:
+		err = inspector.New(cfg, inspector.Deps{
+			KafkaDial:     kafkaFaults.DialFunc(),
+			KafkaSecurity: kafkaSecurity,
+			Metrics:       m,
+			Health:        status,
+			Logger:        logger,
+		}).Run(ctx)
+	default:
+		logger.Error("Invalid mode", "mode", cfg.Mode, "valid_modes", []string{config.ModeProducer, config.ModeConsumer, config.ModeInspector})
+		os.Exit(1)
 	}
-}
-
-func getOrCreateSchema(client *srclient.SchemaRegistryClient, subject string) (*srclient.Schema, error) {
-	// Try to get latest schema first
-	start := time.Now()
-	schema, err := client.GetLatestSchema(subject)
-	duration := time.Since(start).Seconds()
-	schemaRegistryRequestDuration.WithLabelValues("get_latest_schema").Observe(duration)
-	schemaRegistryRequestsTotal.WithLabelValues("get_latest_schema").Inc()
 
-	if err == nil {
-		return schema, nil
+	// Flush pending spans before exit
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Warn("Failed to flush traces", "error", err)
 	}
-
-	schemaRegistryErrorsTotal.WithLabelValues("get_latest_schema", "not_found").Inc()
-
-	// If not found, create new schema
-	avroSchema := `{
-		"type": "record",
-		"name": "Message",
-		"namespace": "com.example",
-		"fields": [
-			{"name": "id", "type": "long"},
-			{"name": "timestamp", "type": "long", "logicalType": "timestamp-millis"},
-			{"name": "data", "type": "string"}
-		]
-	}`
-
-	start = time.Now()
-	schema, err = client.CreateSchema(subject, avroSchema, srclient.Avro)
-	duration = time.Since(start).Seconds()
-	schemaRegistryRequestDuration.WithLabelValues("create_schema").Observe(duration)
-	schemaRegistryRequestsTotal.WithLabelValues("create_schema").Inc()
+	cancelShutdown()
 
 	if err != nil {
-		schemaRegistryErrorsTotal.WithLabelValues("create_schema", "invalid_schema").Inc()
-		return nil, fmt.Errorf("failed to create schema: %w", err)
+		logger.Error("Fatal error", "mode", cfg.Mode, "error", err)
+		os.Exit(1)
 	}
-
-	return schema, nil
 }
 
-func decodeAvroMessage(client *srclient.SchemaRegistryClient, data []byte) (interface{}, error) {
-	// Confluent wire format: magic byte (0) + schema ID (4 bytes big-endian) + Avro data
-	if len(data) < 5 {
-		return nil, fmt.Errorf("message too short: %d bytes", len(data))
-	}
-
-	if data[0] != 0 {
-		return nil, fmt.Errorf("invalid magic byte: %d", data[0])
-	}
-
-	// Extract schema ID (big-endian)
-	schemaID := int(data[1])<<24 | int(data[2])<<16 | int(data[3])<<8 | int(data[4])
-
-	// Get schema from Schema Registry
-	start := time.Now()
-	schema, err := client.GetSchema(schemaID)
-	duration := time.Since(start).Seconds()
-	schemaRegistryRequestDuration.WithLabelValues("get_schema").Observe(duration)
-	schemaRegistryRequestsTotal.WithLabelValues("get_schema").Inc()
-
-	if err != nil {
-		schemaRegistryErrorsTotal.WithLabelValues("get_schema", "not_found").Inc()
-		return nil, fmt.Errorf("failed to get schema %d: %w", schemaID, err)
-	}
-
-	// Create codec and decode
-	codec, err := goavro.NewCodec(schema.Schema())
-	if err != nil {
-		return nil, fmt.Errorf("failed to create codec: %w", err)
+// experimentAnnotators returns the annotators enabled by the EXPERIMENT_* settings.
+func experimentAnnotators(cfg *config.Config) []experiment.Annotator {
+	var annotators []experiment.Annotator
+	if ex := cfg.Experiments; ex.GrafanaURL != "" {
+		annotators = append(annotators, experiment.NewGrafana(ex.GrafanaURL, ex.GrafanaToken, ex.GrafanaDashboardUID, cfg.Mode))
 	}
-
-	decoded, _, err := codec.NativeFromBinary(data[5:])
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode Avro: %w", err)
+	if ex := cfg.Experiments; ex.VictoriaMetricsURL != "" {
+		annotators = append(annotators, experiment.NewVictoriaMetrics(ex.VictoriaMetricsURL, map[string]string{"mode": cfg.Mode}))
 	}
-
-	return decoded, nil
+	return annotators
 }
 
-func encodeAvroMessage(codec *goavro.Codec, schemaID int, msg Message) ([]byte, error) {
-	// Convert Message to map for Avro encoding
-	avroMap := map[string]interface{}{
-		"id":        msg.ID,
-		"timestamp": msg.Timestamp.UnixMilli(),
-		"data":      msg.Data,
+// planScenarios prints the dry-run plan of every scenario file and returns the exit code:
+// 1 if any is invalid, 2 without arguments.
+func planScenarios(paths []string) int {
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: strimzi-kafka-chaos-testing plan <scenario.yaml>...")
+		return 2
 	}
-
-	// Encode to Avro binary
-	avroData, err := codec.BinaryFromNative(nil, avroMap)
-	if err != nil {
-		return nil, err
+	code := 0
+	for i, path := range paths {
+		if i > 0 {
+			fmt.Println()
+		}
+		s, err := scenario.Load(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+			continue
+		}
+		if err := s.Plan().Write(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
 	}
-
-	// Use Confluent wire format: magic byte (0) + schema ID (4 bytes big-endian) + Avro data
-	// This is the standard format expected by Schema Registry consumers
-	buf := make([]byte, 5+len(avroData))
-	buf[0] = 0 // Magic byte
-	buf[1] = byte(schemaID >> 24)
-	buf[2] = byte(schemaID >> 16)
-	buf[3] = byte(schemaID >> 8)
-	buf[4] = byte(schemaID)
-	copy(buf[5:], avroData)
-
-	return buf, nil
+	return code
 }
@@ -0,0 +1,135 @@
+// Package clock relates the clocks of the producer, the consumer and the Kafka brokers, so
+// that latencies measured across pods stay meaningful while a clock is shifted (e.g. by
+// chaos-experiments/time-chaos.yaml).
+//
+// Every pod measures the offset of its local clock from a shared reference, the Redis
+// server time, and the producer sends its offset with each message (OffsetHeader). Broker
+// clocks cannot be queried; their offset is estimated from record timestamps (see
+// SkewEstimator).
+package clock
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+)
+
+// OffsetHeader carries the producer's clock offset from the reference clock in
+// microseconds; the consumer converts the produce timestamp to reference time with it.
+const OffsetHeader = "clock-offset-us"
+
+// samplesPerSync Redis TIME calls are made per measurement; the one with the shortest
+// round trip is used, as it bounds the offset most tightly.
+const samplesPerSync = 5
+
+// Sync tracks the offset of the local clock from the Redis server clock. A nil *Sync, or
+// one that has not measured yet, reports a zero offset: local time is used as is.
+type Sync struct {
+	rdb      *redis.Client
+	interval time.Duration
+	metrics  *metrics.Metrics
+	logger   *slog.Logger
+
+	mu     sync.Mutex
+	offset time.Duration
+}
+
+// NewSync creates a Sync that measures against rdb every interval once Run is started.
+func NewSync(rdb *redis.Client, interval time.Duration, m *metrics.Metrics, logger *slog.Logger) *Sync {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Sync{rdb: rdb, interval: interval, metrics: m, logger: logger}
+}
+
+// Run measures the offset until ctx is cancelled. A failed measurement keeps the last
+// offset.
+func (s *Sync) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if err := s.measure(ctx); err != nil && ctx.Err() == nil {
+			s.metrics.ClockSyncErrorsTotal.Inc()
+			s.logger.Debug("Clock offset measurement failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// measure estimates the offset NTP-style: the server read its clock at some point of the
+// round trip, taken to be the middle, so the error is at most half the round trip.
+func (s *Sync) measure(ctx context.Context) error {
+	var best, offset time.Duration
+	for i := 0; i < samplesPerSync; i++ {
+		// Wall clock readings only: a shifted clock does not move the monotonic one
+		sent := time.Now().Round(0)
+		server, err := s.rdb.Time(ctx).Result()
+		if err != nil {
+			return err
+		}
+		received := time.Now().Round(0)
+		rtt := received.Sub(sent)
+		if i == 0 || rtt < best {
+			best = rtt
+			offset = sent.Add(rtt / 2).Sub(server)
+		}
+	}
+
+	s.mu.Lock()
+	previous := s.offset
+	s.offset = offset
+	s.mu.Unlock()
+	s.metrics.ClockOffset.Set(offset.Seconds())
+	s.metrics.ClockSyncUncertainty.Set((best / 2).Seconds())
+	// Report shifts that matter for latencies, such as the start or end of a TimeChaos
+	if d := offset - previous; d > time.Second || d < -time.Second {
+		s.logger.Warn("Local clock offset changed", "offset", offset, "previous", previous, "uncertainty", best/2)
+	}
+	return nil
+}
+
+// Offset returns the offset of the local clock from the reference clock (positive when the
+// local clock is ahead).
+func (s *Sync) Offset() time.Duration {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset
+}
+
+// Now returns the current reference time.
+func (s *Sync) Now() time.Time {
+	return time.Now().Round(0).Add(-s.Offset())
+}
+
+// Header returns the OffsetHeader with the current offset, for a produced message.
+func (s *Sync) Header() kafka.Header {
+	return kafka.Header{Key: OffsetHeader, Value: []byte(strconv.FormatInt(s.Offset().Microseconds(), 10))}
+}
+
+// ProducerOffset returns the producer's clock offset sent in the headers of msg, zero
+// when absent.
+func ProducerOffset(msg kafka.Message) time.Duration {
+	for _, h := range msg.Headers {
+		if h.Key == OffsetHeader {
+			us, err := strconv.ParseInt(string(h.Value), 10, 64)
+			if err != nil {
+				return 0
+			}
+			return time.Duration(us) * time.Microsecond
+		}
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package clock
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestSyncMeasuresShiftedClock(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := NewSync(rdb, time.Second, metrics.New(prometheus.NewRegistry()), logger)
+
+	tests := []struct {
+		name   string
+		offset time.Duration // of the local clock from the Redis server clock
+	}{
+		{name: "in sync"},
+		{name: "local clock ahead", offset: 5 * time.Second},
+		{name: "local clock behind", offset: -90 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr.SetTime(time.Now().Add(-tt.offset))
+			if err := s.measure(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			// The server clock is frozen, so the measured offset grows by up to the
+			// round trip: the uncertainty bounds the error
+			uncertainty := time.Duration(testutil.ToFloat64(s.metrics.ClockSyncUncertainty) * float64(time.Second))
+			tolerance := 2*uncertainty + 10*time.Millisecond
+			if got := s.Offset(); got < tt.offset-tolerance || got > tt.offset+tolerance {
+				t.Errorf("Offset = %v, want %v ± %v", got, tt.offset, tolerance)
+			}
+			if got := testutil.ToFloat64(s.metrics.ClockOffset); got != s.Offset().Seconds() {
+				t.Errorf("app_clock_offset_seconds = %v, want %v", got, s.Offset().Seconds())
+			}
+			if got := time.Since(s.Now()); got < tt.offset-tolerance || got > tt.offset+tolerance {
+				t.Errorf("local time - Now = %v, want %v ± %v", got, tt.offset, tolerance)
+			}
+		})
+	}
+}
+
+func TestProducerOffsetHeader(t *testing.T) {
+	var unsynced *Sync
+	if got := unsynced.Offset(); got != 0 {
+		t.Errorf("Offset of a nil Sync = %v, want 0", got)
+	}
+	s := &Sync{offset: -1500 * time.Millisecond}
+	tests := []struct {
+		name    string
+		headers []kafka.Header
+		want    time.Duration
+	}{
+		{name: "offset", headers: []kafka.Header{{Key: "other"}, s.Header()}, want: -1500 * time.Millisecond},
+		{name: "no header"},
+		{name: "malformed", headers: []kafka.Header{{Key: OffsetHeader, Value: []byte("1.5s")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProducerOffset(kafka.Message{Headers: tt.headers}); got != tt.want {
+				t.Errorf("ProducerOffset = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
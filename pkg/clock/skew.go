@@ -0,0 +1,105 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// SkewEstimator bounds the clock offset of the broker that appended a message from its
+// LogAppendTime. With produce and consume times in reference time, the observed
+// produce→append delay is the true delay plus the broker offset and the append→consume
+// delay is the true delay minus it. Neither true delay is negative, so the offset lies
+// between minus the smallest append→consume delay and the smallest produce→append delay
+// seen over a window. No assumption is made about how the two true delays compare: the
+// width of that range is what is known about the offset, and it is reported with it.
+//
+// Estimates are kept per key (e.g. topic and partition) since a shift may hit a single
+// broker.
+type SkewEstimator struct {
+	window time.Duration
+	now    func() time.Time // time.Now, replaced in tests
+
+	mu   sync.Mutex
+	keys map[string]*minDelays
+}
+
+// NewSkewEstimator creates a SkewEstimator that takes the minimum delays over window.
+func NewSkewEstimator(window time.Duration) *SkewEstimator {
+	return &SkewEstimator{window: window, now: time.Now, keys: make(map[string]*minDelays)}
+}
+
+// Skew is the range of broker clock offsets consistent with the observed delays
+// (positive when the broker clock is ahead).
+type Skew struct {
+	Low, High time.Duration
+}
+
+// Offset returns the middle of the range, the offset estimate with the least error bound.
+func (s Skew) Offset() time.Duration {
+	return s.Low + (s.High-s.Low)/2
+}
+
+// Uncertainty returns the largest error of Offset: half the width of the range. The error
+// of the reference time at the producer and the consumer comes on top.
+func (s Skew) Uncertainty() time.Duration {
+	return max(s.High-s.Low, s.Low-s.High) / 2
+}
+
+// minDelays holds the minimum delays of two consecutive half-window buckets, so that old
+// samples expire after between half and a whole window.
+type minDelays struct {
+	current, previous bucket
+}
+
+type bucket struct {
+	start      time.Time // local monotonic time
+	up, down   time.Duration
+	hasSamples bool
+}
+
+func (b *bucket) add(up, down time.Duration) {
+	if !b.hasSamples || up < b.up {
+		b.up = up
+	}
+	if !b.hasSamples || down < b.down {
+		b.down = down
+	}
+	b.hasSamples = true
+}
+
+func (b *bucket) skew() Skew {
+	return Skew{Low: -b.down, High: b.up}
+}
+
+// Observe records the delays of one message under key, up from produce to append and
+// down from append to consume, and returns the range of its broker's offset.
+func (e *SkewEstimator) Observe(key string, up, down time.Duration) Skew {
+	now := e.now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	d, ok := e.keys[key]
+	if !ok {
+		d = &minDelays{current: bucket{start: now}}
+		e.keys[key] = d
+	}
+	if age := now.Sub(d.current.start); age >= e.window {
+		// No samples for a whole window: all of them are outdated
+		d.previous, d.current = bucket{}, bucket{start: now}
+	} else if age >= e.window/2 {
+		d.previous = d.current
+		d.current = bucket{start: now}
+	}
+	d.current.add(up, down)
+
+	skew := d.current.skew()
+	if !d.previous.hasSamples {
+		return skew
+	}
+	both := Skew{Low: max(skew.Low, d.previous.skew().Low), High: min(skew.High, d.previous.skew().High)}
+	if both.Low > both.High {
+		// The offset changed since the previous bucket (e.g. a TimeChaos started or ended):
+		// its samples no longer apply
+		return skew
+	}
+	return both
+}
@@ -0,0 +1,90 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+// delays are true one-way delays of a message, before the broker offset is applied.
+type delays struct {
+	up, down time.Duration
+}
+
+func TestSkewEstimatorObserve(t *testing.T) {
+	const ms = time.Millisecond
+	tests := []struct {
+		name    string
+		offset  time.Duration // of the broker clock
+		samples []delays
+		want    Skew
+	}{
+		{
+			name:    "symmetric delays",
+			samples: []delays{{10 * ms, 10 * ms}, {30 * ms, 12 * ms}, {11 * ms, 40 * ms}},
+			want:    Skew{Low: -10 * ms, High: 10 * ms},
+		},
+		{
+			// A fast produce and a slow consume poll must widen the range, not move
+			// the estimate away from the true zero offset unnoticed
+			name:    "asymmetric delays",
+			samples: []delays{{2 * ms, 50 * ms}, {5 * ms, 80 * ms}, {3 * ms, 60 * ms}},
+			want:    Skew{Low: -50 * ms, High: 2 * ms},
+		},
+		{
+			name:    "broker clock ahead",
+			offset:  5 * time.Second,
+			samples: []delays{{4 * ms, 6 * ms}, {9 * ms, 5 * ms}},
+			want:    Skew{Low: 5*time.Second - 5*ms, High: 5*time.Second + 4*ms},
+		},
+		{
+			name:    "broker clock behind",
+			offset:  -3 * time.Second,
+			samples: []delays{{20 * ms, 1 * ms}, {15 * ms, 2 * ms}},
+			want:    Skew{Low: -3*time.Second - 1*ms, High: -3*time.Second + 15*ms},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewSkewEstimator(time.Minute)
+			var got Skew
+			for _, d := range tt.samples {
+				got = e.Observe("topic/0", d.up+tt.offset, d.down-tt.offset)
+			}
+			if got != tt.want {
+				t.Fatalf("Observe = %+v, want %+v", got, tt.want)
+			}
+			if got.Low > tt.offset || got.High < tt.offset {
+				t.Errorf("range %+v does not contain the broker offset %v", got, tt.offset)
+			}
+			if mid, err := got.Offset(), got.Uncertainty(); mid-err != got.Low || mid+err != got.High {
+				t.Errorf("Offset ± Uncertainty = %v ± %v, want the range %+v", mid, err, got)
+			}
+		})
+	}
+}
+
+func TestSkewEstimatorFollowsClockShift(t *testing.T) {
+	const ms = time.Millisecond
+	now := time.Now()
+	e := NewSkewEstimator(time.Minute)
+	e.now = func() time.Time { return now }
+
+	e.Observe("topic/0", 10*ms, 10*ms)
+	// Another partition is not affected by a shift of this one's leader
+	e.Observe("topic/1", 10*ms, 10*ms)
+	now = now.Add(40 * time.Second)
+	const shift = 2 * time.Second
+	got := e.Observe("topic/0", 10*ms+shift, 10*ms-shift)
+	if want := (Skew{Low: shift - 10*ms, High: shift + 10*ms}); got != want {
+		t.Errorf("Observe after a shift = %+v, want %+v from the new samples only", got, want)
+	}
+	now = now.Add(10 * time.Second)
+	if got, want := e.Observe("topic/1", 12*ms, 12*ms), (Skew{Low: -10 * ms, High: 10 * ms}); got != want {
+		t.Errorf("Observe of another partition = %+v, want %+v", got, want)
+	}
+	// Samples expire after a window
+	now = now.Add(time.Minute)
+	if got, want := e.Observe("topic/1", 30*ms, 30*ms), (Skew{Low: -30 * ms, High: 30 * ms}); got != want {
+		t.Errorf("Observe after a window = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,155 @@
+// Package codec encodes and decodes test messages as Avro in the Confluent wire format,
+// with schemas stored in Schema Registry.
+package codec
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/tracing"
+	"github.com/riferrei/srclient"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Message is the test message sent by the producer.
+type Message struct {
+	ID        int64     `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      string    `json:"data"`
+}
+
+// Codec resolves schemas through Schema Registry and records its request metrics.
+type Codec struct {
+	client  *srclient.SchemaRegistryClient
+	metrics *metrics.Metrics
+}
+
+func New(client *srclient.SchemaRegistryClient, m *metrics.Metrics) *Codec {
+	return &Codec{client: client, metrics: m}
+}
+
+// GetOrCreateSchema returns the latest schema of subject, registering the message schema
+// when the subject does not exist yet. Registry calls are traced as children of ctx.
+func (c *Codec) GetOrCreateSchema(ctx context.Context, subject string) (*srclient.Schema, error) {
+	// Try to get latest schema first
+	start := time.Now()
+	_, span := tracing.Start(ctx, "schema_registry get_latest_schema", attribute.String("schema_registry.subject", subject))
+	schema, err := c.client.GetLatestSchema(subject)
+	span.End()
+	duration := time.Since(start).Seconds()
+	c.metrics.SchemaRegistryRequestDuration.WithLabelValues("get_latest_schema").Observe(duration)
+	c.metrics.SchemaRegistryRequestsTotal.WithLabelValues("get_latest_schema").Inc()
+
+	if err == nil {
+		return schema, nil
+	}
+
+	c.metrics.SchemaRegistryErrorsTotal.WithLabelValues("get_latest_schema", "not_found").Inc()
+
+	// If not found, create new schema
+	avroSchema := `{
+		"type": "record",
+		"name": "Message",
+		"namespace": "com.example",
+		"fields": [
+			{"name": "id", "type": "long"},
+			{"name": "timestamp", "type": "long", "logicalType": "timestamp-millis"},
+			{"name": "data", "type": "string"}
+		]
+	}`
+
+	start = time.Now()
+	_, span = tracing.Start(ctx, "schema_registry create_schema", attribute.String("schema_registry.subject", subject))
+	schema, err = c.client.CreateSchema(subject, avroSchema, srclient.Avro)
+	if err != nil {
+		tracing.RecordError(span, err, "")
+	}
+	span.End()
+	duration = time.Since(start).Seconds()
+	c.metrics.SchemaRegistryRequestDuration.WithLabelValues("create_schema").Observe(duration)
+	c.metrics.SchemaRegistryRequestsTotal.WithLabelValues("create_schema").Inc()
+
+	if err != nil {
+		c.metrics.SchemaRegistryErrorsTotal.WithLabelValues("create_schema", "invalid_schema").Inc()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	return schema, nil
+}
+
+// Decode decodes a message in the Confluent wire format, fetching its schema by ID. The
+// schema lookup (cached by the client after the first call) is traced as a child of ctx.
+func (c *Codec) Decode(ctx context.Context, data []byte) (interface{}, error) {
+	// Confluent wire format: magic byte (0) + schema ID (4 bytes big-endian) + Avro data
+	if len(data) < 5 {
+		return nil, fmt.Errorf("message too short: %d bytes", len(data))
+	}
+
+	if data[0] != 0 {
+		return nil, fmt.Errorf("invalid magic byte: %d", data[0])
+	}
+
+	// Extract schema ID (big-endian)
+	schemaID := int(data[1])<<24 | int(data[2])<<16 | int(data[3])<<8 | int(data[4])
+
+	// Get schema from Schema Registry
+	start := time.Now()
+	_, span := tracing.Start(ctx, "schema_registry get_schema", attribute.Int("schema_registry.schema_id", schemaID))
+	schema, err := c.client.GetSchema(schemaID)
+	if err != nil {
+		tracing.RecordError(span, err, "")
+	}
+	span.End()
+	duration := time.Since(start).Seconds()
+	c.metrics.SchemaRegistryRequestDuration.WithLabelValues("get_schema").Observe(duration)
+	c.metrics.SchemaRegistryRequestsTotal.WithLabelValues("get_schema").Inc()
+
+	if err != nil {
+		c.metrics.SchemaRegistryErrorsTotal.WithLabelValues("get_schema", "not_found").Inc()
+		return nil, fmt.Errorf("failed to get schema %d: %w", schemaID, err)
+	}
+
+	// Create codec and decode
+	codec, err := goavro.NewCodec(schema.Schema())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create codec: %w", err)
+	}
+
+	decoded, _, err := codec.NativeFromBinary(data[5:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode Avro: %w", err)
+	}
+
+	return decoded, nil
+}
+
+// Encode encodes msg with codec in the Confluent wire format for schemaID.
+func Encode(codec *goavro.Codec, schemaID int, msg Message) ([]byte, error) {
+	// Convert Message to map for Avro encoding
+	avroMap := map[string]interface{}{
+		"id":        msg.ID,
+		"timestamp": msg.Timestamp.UnixMilli(),
+		"data":      msg.Data,
+	}
+
+	// Encode to Avro binary
+	avroData, err := codec.BinaryFromNative(nil, avroMap)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use Confluent wire format: magic byte (0) + schema ID (4 bytes big-endian) + Avro data
+	// This is the standard format expected by Schema Registry consumers
+	buf := make([]byte, 5+len(avroData))
+	buf[0] = 0 // Magic byte
+	buf[1] = byte(schemaID >> 24)
+	buf[2] = byte(schemaID >> 16)
+	buf[3] = byte(schemaID >> 8)
+	buf[4] = byte(schemaID)
+	copy(buf[5:], avroData)
+
+	return buf, nil
+}
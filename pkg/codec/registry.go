@@ -0,0 +1,157 @@
+package codec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/health"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/riferrei/srclient"
+)
+
+// NewSchemaRegistryClient creates a srclient for url with the retrying transport configured
+// by policy. base is the underlying transport (e.g. wrapped by a fault injector); nil means
+// http.DefaultTransport.
+func NewSchemaRegistryClient(url string, policy config.SchemaRegistryHTTP, base http.RoundTripper, m *metrics.Metrics, logger *slog.Logger) *srclient.SchemaRegistryClient {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	httpClient := &http.Client{
+		Timeout:   policy.Timeout,
+		Transport: &retryRoundTripper{next: base, policy: policy, metrics: m, logger: logger},
+	}
+	return srclient.NewSchemaRegistryClient(url, srclient.WithClient(httpClient))
+}
+
+// retryRoundTripper retries Schema Registry requests on transport errors, 5xx and 429
+// responses with exponential backoff and jitter.
+type retryRoundTripper struct {
+	next    http.RoundTripper
+	policy  config.SchemaRegistryHTTP
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+}
+
+func (t *retryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := max(t.policy.MaxAttempts, 1)
+	backoff := t.policy.BackoffMin
+	for attempt := 1; ; attempt++ {
+		resp, err := t.attempt(req)
+		status := "error"
+		if err == nil {
+			status = strconv.Itoa(resp.StatusCode)
+		}
+		t.metrics.SchemaRegistryHTTPRequestsTotal.WithLabelValues(req.Method, status).Inc()
+
+		retriable := err != nil || resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		if !retriable || attempt >= attempts || req.Context().Err() != nil {
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			// Body cannot be replayed.
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		t.logger.Warn("Schema Registry request failed, retrying", "method", req.Method, "path", req.URL.Path,
+			"status", status, "error", err, "attempt", attempt)
+		t.metrics.SchemaRegistryHTTPRetriesTotal.WithLabelValues(req.Method).Inc()
+
+		sleep := backoff/2 + time.Duration(rand.Int64N(int64(backoff/2)+1))
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(sleep):
+		}
+		backoff = min(backoff*2, t.policy.BackoffMax)
+	}
+}
+
+// attempt sends one try of req bounded by AttemptTimeout.
+func (t *retryRoundTripper) attempt(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	if t.policy.AttemptTimeout > 0 {
+		ctx, cancel = context.WithTimeout(req.Context(), t.policy.AttemptTimeout)
+	}
+	try := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		try.Body = body
+	}
+	resp, err := t.next.RoundTrip(try)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The attempt context must live until the caller has read the body.
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// HealthCheck returns a check that sends one GET /config to the Schema Registry at url
+// through base (no retries, so a failing registry shows up at once). The result also
+// drives schema_registry_connection_status.
+func HealthCheck(url string, base http.RoundTripper, m *metrics.Metrics) health.Check {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client := &http.Client{Transport: base}
+	return health.Check{
+		Name:  config.DependencySchemaRegistry,
+		Group: config.DependencySchemaRegistry,
+		Probe: func(ctx context.Context) error {
+			err := pingSchemaRegistry(ctx, client, url)
+			if err != nil {
+				m.SchemaRegistryConnectionStatus.Set(0)
+			} else {
+				m.SchemaRegistryConnectionStatus.Set(1)
+			}
+			return err
+		},
+	}
+}
+
+func pingSchemaRegistry(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(url, "/")+"/config", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET /config: %s", resp.Status)
+	}
+	return nil
+}
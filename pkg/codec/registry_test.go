@@ -0,0 +1,206 @@
+package codec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/faults"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// testPolicy retries fast enough for tests.
+func testPolicy() config.SchemaRegistryHTTP {
+	policy := config.Default().SchemaRegistryHTTP
+	policy.Timeout, policy.AttemptTimeout = 5*time.Second, time.Second
+	policy.BackoffMin, policy.BackoffMax = 10*time.Millisecond, 20*time.Millisecond
+	return policy
+}
+
+// newRetryClient returns a client with the retrying transport of NewSchemaRegistryClient.
+func newRetryClient(policy config.SchemaRegistryHTTP) (*http.Client, *metrics.Metrics) {
+	m := metrics.New(prometheus.NewRegistry())
+	return &http.Client{
+		Timeout: policy.Timeout,
+		Transport: &retryRoundTripper{next: http.DefaultTransport, policy: policy, metrics: m,
+			logger: slog.New(slog.NewTextHandler(io.Discard, nil))},
+	}, m
+}
+
+// statusServer answers the statuses in turn, then 200, and counts the requests.
+func statusServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestRetryRoundTripper(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		want     int
+		requests int32
+	}{
+		{"success", nil, http.StatusOK, 1},
+		{"server errors are retried", []int{503, 500}, http.StatusOK, 3},
+		{"too many requests is retried", []int{429}, http.StatusOK, 2},
+		{"client errors are not retried", []int{404}, http.StatusNotFound, 1},
+		{"conflict is not retried", []int{409}, http.StatusConflict, 1},
+		{"attempts are bounded", []int{503, 503, 503, 503, 503, 503}, http.StatusServiceUnavailable, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := statusServer(t, tt.statuses...)
+			client, m := newRetryClient(testPolicy())
+			// A POST body is sent again with every attempt
+			resp, err := client.Post(srv.URL+"/subjects/test-value/versions", "application/json", strings.NewReader(`{"schema":"x"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if resp.StatusCode == http.StatusOK && string(body) != `{"schema":"x"}` {
+				t.Errorf("body of the last attempt = %q", body)
+			}
+			if got := requests.Load(); got != tt.requests {
+				t.Errorf("%d requests, want %d", got, tt.requests)
+			}
+			if got := testutil.ToFloat64(m.SchemaRegistryHTTPRetriesTotal.WithLabelValues(http.MethodPost)); got != float64(tt.requests-1) {
+				t.Errorf("schema_registry_http_retries_total = %v, want %d", got, tt.requests-1)
+			}
+		})
+	}
+}
+
+func TestRetryCountsStatusesAndErrors(t *testing.T) {
+	srv, _ := statusServer(t, 503)
+	client, m := newRetryClient(testPolicy())
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	for status, want := range map[string]float64{"503": 1, "200": 1} {
+		if got := testutil.ToFloat64(m.SchemaRegistryHTTPRequestsTotal.WithLabelValues(http.MethodGet, status)); got != want {
+			t.Errorf("schema_registry_http_requests_total{status=%s} = %v, want %v", status, got, want)
+		}
+	}
+
+	// A closed registry fails every attempt at the transport
+	srv.Close()
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Fatal("GET of a closed registry succeeded")
+	}
+	if got := testutil.ToFloat64(m.SchemaRegistryHTTPRequestsTotal.WithLabelValues(http.MethodGet, "error")); got != 5 {
+		t.Errorf("schema_registry_http_requests_total{status=error} = %v, want 5", got)
+	}
+}
+
+func TestAttemptTimeoutRetriesHungAttempt(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	policy := testPolicy()
+	policy.AttemptTimeout = 50 * time.Millisecond
+	client, m := newRetryClient(policy)
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || requests.Load() != 2 {
+		t.Errorf("GET = %d after %d requests, want 200 after 2", resp.StatusCode, requests.Load())
+	}
+	if got := testutil.ToFloat64(m.SchemaRegistryHTTPRequestsTotal.WithLabelValues(http.MethodGet, "error")); got != 1 {
+		t.Errorf("schema_registry_http_requests_total{status=error} = %v, want 1", got)
+	}
+}
+
+func TestTimeoutBoundsRetries(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	policy := testPolicy()
+	policy.MaxAttempts, policy.Timeout = 1000, 200*time.Millisecond
+	policy.BackoffMin, policy.BackoffMax = 50*time.Millisecond, 50*time.Millisecond
+	client, _ := newRetryClient(policy)
+
+	start := time.Now()
+	_, err := client.Get(srv.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GET = %v, want the client timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GET took %v, want it bounded by the 200ms timeout", elapsed)
+	}
+	if got := requests.Load(); got > 10 {
+		t.Errorf("%d attempts within the timeout", got)
+	}
+}
+
+// TestRegistryOutage runs srclient through the fault injector: an outage fails the lookup
+// after the retries, and the registry is used again once the fault is lifted.
+func TestRegistryOutage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subjects/test-value/versions/latest" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `{"subject":"test-value","version":1,"id":1,"schema":"\"string\""}`)
+	}))
+	defer srv.Close()
+	m := metrics.New(prometheus.NewRegistry())
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	injector := faults.NewHTTPInjector(m, logger)
+	client := NewSchemaRegistryClient(srv.URL, testPolicy(), injector.Wrap(http.DefaultTransport), m, logger)
+
+	injector.Set(faults.HTTPFault{ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable})
+	if _, err := client.GetLatestSchema("test-value"); err == nil {
+		t.Fatal("schema lookup succeeded during the outage")
+	}
+	if got := testutil.ToFloat64(m.SchemaRegistryHTTPRetriesTotal.WithLabelValues(http.MethodGet)); got != 4 {
+		t.Errorf("schema_registry_http_retries_total = %v, want 4", got)
+	}
+
+	injector.Clear()
+	schema, err := client.GetLatestSchema("test-value")
+	if err != nil {
+		t.Fatalf("schema lookup after the outage: %v", err)
+	}
+	if schema.ID() != 1 {
+		t.Errorf("schema ID = %d, want 1", schema.ID())
+	}
+}
@@ -0,0 +1,769 @@
+// Package config loads the producer and consumer configuration from an optional YAML or
+// JSON file (CONFIG_FILE) and environment variables, which override the file.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Modes selected with MODE.
+const (
+	ModeProducer = "producer"
+	ModeConsumer = "consumer"
+	// ModeInspector reports cluster health seen through the Kafka admin API.
+	ModeInspector = "inspector"
+)
+
+// Config is the complete application configuration.
+type Config struct {
+	Mode              string   `yaml:"mode"`
+	Brokers           []string `yaml:"brokers"`
+	Topic             string   `yaml:"topic"`
+	SchemaRegistryURL string   `yaml:"schema_registry_url"`
+	Username          string   `yaml:"username"`
+	Password          string   `yaml:"password"`
+	SASLMechanism     string   `yaml:"sasl_mechanism"` // env KAFKA_SASL_MECHANISM
+	GroupID           string   `yaml:"group_id"`
+	// OAUTHBEARER tokens by the client credentials flow (env KAFKA_OAUTH_TOKEN_URL, KAFKA_OAUTH_CLIENT_ID, KAFKA_OAUTH_CLIENT_SECRET,
+	// KAFKA_OAUTH_SCOPE, KAFKA_OAUTH_REFRESH_RATIO, KAFKA_OAUTH_TIMEOUT_MS)
+	OAuth OAuth `yaml:"oauth"`
+	// TLS and mutual TLS of broker connections (env KAFKA_TLS_ENABLED, KAFKA_TLS_CA_FILE, KAFKA_TLS_CERT_FILE, KAFKA_TLS_KEY_FILE,
+	// KAFKA_TLS_SERVER_NAME, KAFKA_TLS_INSECURE_SKIP_VERIFY, KAFKA_TLS_RELOAD_INTERVAL_MS)
+	TLS KafkaTLS `yaml:"tls"`
+	// Producer: batch settings (env KAFKA_PRODUCER_BATCH_SIZE, KAFKA_PRODUCER_BATCH_TIMEOUT_MS)
+	ProducerBatchSize    int           `yaml:"producer_batch_size"`
+	ProducerBatchTimeout time.Duration `yaml:"producer_batch_timeout"`
+	// Producer: message rate (env PRODUCER_INTERVAL_MS)
+	ProducerIntervalMs int `yaml:"producer_interval_ms"` // ms between messages, 100 = 10 msg/s per producer
+	// Producer: max retry attempts (env KAFKA_PRODUCER_MAX_ATTEMPTS)
+	ProducerMaxAttempts int `yaml:"producer_max_attempts"`
+	// Producer: wait for Kafka metadata before sending (env KAFKA_PRODUCER_STARTUP_DELAY_MS)
+	ProducerStartupDelay time.Duration `yaml:"producer_startup_delay"`
+	// Producer: message data template file, embedded default when empty (env MESSAGE_TEMPLATE_FILE)
+	MessageTemplateFile string `yaml:"message_template_file"`
+	// Consumer: fetch settings (env KAFKA_CONSUMER_MIN_BYTES, MAX_BYTES, MAX_WAIT_MS)
+	ConsumerMinBytes  int `yaml:"consumer_min_bytes"`
+	ConsumerMaxBytes  int `yaml:"consumer_max_bytes"`
+	ConsumerMaxWaitMs int `yaml:"consumer_max_wait_ms"`
+	// Consumer: worker pool (env KAFKA_CONSUMER_WORKERS, KAFKA_CONSUMER_WORKER_QUEUE_SIZE, KAFKA_CONSUMER_COMMIT_INTERVAL_MS)
+	ConsumerWorkers        int           `yaml:"consumer_workers"`
+	ConsumerQueueSize      int           `yaml:"consumer_queue_size"`
+	ConsumerCommitInterval time.Duration `yaml:"consumer_commit_interval"`
+	// Consumer: group balancers in priority order (env KAFKA_CONSUMER_GROUP_BALANCERS) and rack for rack-aware (env KAFKA_CONSUMER_RACK)
+	ConsumerGroupBalancers []string `yaml:"consumer_group_balancers"`
+	ConsumerRack           string   `yaml:"consumer_rack"`
+	// Consumer: simulated slow/flaky downstream (env CONSUMER_PROCESSING_*, CONSUMER_PAUSE_*, CONSUMER_PANIC_RATE)
+	ConsumerSimulation ProcessingSimulation `yaml:"consumer_simulation"`
+	// Schema Registry: HTTP timeouts and retries (env SCHEMA_REGISTRY_TIMEOUT_MS, SCHEMA_REGISTRY_ATTEMPT_TIMEOUT_MS, SCHEMA_REGISTRY_MAX_ATTEMPTS, SCHEMA_REGISTRY_BACKOFF_MIN_MS, SCHEMA_REGISTRY_BACKOFF_MAX_MS)
+	SchemaRegistryHTTP SchemaRegistryHTTP `yaml:"schema_registry_http"`
+	// Redis: store hash of message value for delivery verification and SLO
+	RedisAddr       string `yaml:"redis_addr"`
+	RedisPassword   string `yaml:"redis_password"`
+	RedisKeyPrefix  string `yaml:"redis_key_prefix"`
+	RedisSLOSeconds int    `yaml:"redis_slo_seconds"` // messages still in Redis older than this are counted as SLO breach
+	// Redis: expiry of the keys of sent messages (env REDIS_KEY_TTL_MS); verified markers expire sooner, see verify.Options
+	RedisKeyTTL time.Duration `yaml:"redis_key_ttl"`
+	// Redis: spool for failed verification writes (env REDIS_SPOOL_MAX_RECORDS, REDIS_SPOOL_FILE, REDIS_SPOOL_REPLAY_INTERVAL_MS)
+	RedisSpoolMaxRecords     int           `yaml:"redis_spool_max_records"` // 0 disables spooling: failed writes are dropped (and counted)
+	RedisSpoolFile           string        `yaml:"redis_spool_file"`
+	RedisSpoolReplayInterval time.Duration `yaml:"redis_spool_replay_interval"`
+	// Dependency health checks and readiness policy (env HEALTH_CHECK_INTERVAL_MS, HEALTH_CHECK_TIMEOUT_MS, HEALTH_FAILURE_THRESHOLD, HEALTH_CRITICAL_DEPENDENCIES)
+	Health HealthPolicy `yaml:"health"`
+	// Port of the server for health probes, metrics, /config and the control and fault APIs (env HEALTH_PORT)
+	HealthPort int `yaml:"health_port"`
+	// Runtime control API (/control) bearer token; the API is disabled when empty (env CONTROL_TOKEN)
+	ControlToken string `yaml:"control_token"`
+	// Client-side fault injection and fault schedules (env KAFKA_FAULT_INJECTION, KAFKA_FAULT_SCHEDULE, SCHEMA_REGISTRY_FAULT_INJECTION,
+	// SCHEMA_REGISTRY_FAULT_SCHEDULE, REDIS_FAULT_INJECTION, REDIS_FAULT_SCHEDULE)
+	Faults Faults `yaml:"faults"`
+	// OpenTelemetry tracing (env TRACING_EXPORTER, TRACING_OTLP_ENDPOINT, TRACING_FILE, TRACING_SAMPLE_RATIO, TRACING_SERVICE_NAME)
+	Tracing Tracing `yaml:"tracing"`
+	// Clock offset tracking for latency breakdowns (env CLOCK_SYNC_INTERVAL_MS, CLOCK_SKEW_WINDOW_MS)
+	Clock Clock `yaml:"clock"`
+	// Consumer: delivery SLOs, error budgets and burn-rate alerts (env SLO_WINDOW_MS, SLO_DELIVERY_TARGET, SLO_LOSS_TARGET, SLO_EVALUATION_INTERVAL_MS, SLO_WEBHOOK_URL)
+	SLO SLO `yaml:"slo"`
+	// Chaos experiment context and annotations (env EXPERIMENT_NAME, EXPERIMENT_GRAFANA_URL, EXPERIMENT_GRAFANA_TOKEN, EXPERIMENT_GRAFANA_DASHBOARD_UID, EXPERIMENT_VICTORIAMETRICS_URL,
+	// CHAOS_MESH_NAMESPACES, CHAOS_MESH_RESYNC_MS)
+	Experiments Experiments `yaml:"experiments"`
+	// Inspector mode: topics and period of the cluster health inspection (env INSPECTOR_TOPICS, INSPECTOR_INTERVAL_MS)
+	Inspector Inspector `yaml:"inspector"`
+	// Abort of chaos on safety thresholds (env GUARD_PROMETHEUS_URL, GUARD_INTERVAL_MS, GUARD_MAX_LAG, GUARD_LAG_FOR_MS,
+	// GUARD_ABORT_ON_MISMATCH, GUARD_PRODUCER_BLOCKED_FOR_MS; conditions from the file only)
+	Guard Guard `yaml:"guard"`
+	// Time to recover after each experiment (env RECOVERY_POLL_INTERVAL_MS, RECOVERY_STABLE_FOR_MS, RECOVERY_TIMEOUT_MS,
+	// RECOVERY_LAG_TOLERANCE, RECOVERY_PENDING_TOLERANCE, RECOVERY_REPORT_FILE)
+	Recovery Recovery `yaml:"recovery"`
+}
+
+// SchemaRegistryHTTP configures timeouts and retries of Schema Registry calls.
+type SchemaRegistryHTTP struct {
+	// Timeout bounds a whole srclient call including retries.
+	Timeout time.Duration `yaml:"timeout"`
+	// AttemptTimeout bounds a single HTTP attempt.
+	AttemptTimeout time.Duration `yaml:"attempt_timeout"`
+	MaxAttempts    int           `yaml:"max_attempts"`
+	BackoffMin     time.Duration `yaml:"backoff_min"`
+	BackoffMax     time.Duration `yaml:"backoff_max"`
+}
+
+// Dependencies named in HEALTH_CRITICAL_DEPENDENCIES.
+const (
+	DependencyKafka          = "kafka"
+	DependencySchemaRegistry = "schema-registry"
+	DependencyRedis          = "redis"
+)
+
+// HealthPolicy configures the periodic dependency checks and which failing dependencies
+// make the pod unready.
+type HealthPolicy struct {
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+	// FailureThreshold consecutive failures mark a dependency down for readiness.
+	FailureThreshold int `yaml:"failure_threshold"`
+	// Critical dependencies make the pod unready while down. Kafka counts as down only
+	// when every broker is unreachable.
+	Critical []string `yaml:"critical"`
+}
+
+// Faults enables the client-side fault injectors and their schedules. In the environment a
+// schedule is a JSON list, e.g. [{"at":"30s","duration":"60s","latency":"200ms"}].
+type Faults struct {
+	Kafka          KafkaFaults          `yaml:"kafka"`
+	SchemaRegistry SchemaRegistryFaults `yaml:"schema_registry"`
+	Redis          RedisFaults          `yaml:"redis"`
+}
+
+// KafkaFaults enables the fault injector of Kafka connections and its API /faults/kafka.
+type KafkaFaults struct {
+	Enabled  bool         `yaml:"enabled"`
+	Schedule []KafkaFault `yaml:"schedule"`
+}
+
+// SchemaRegistryFaults enables the fault injector of Schema Registry calls and its API
+// /faults/schema-registry.
+type SchemaRegistryFaults struct {
+	Enabled  bool                  `yaml:"enabled"`
+	Schedule []SchemaRegistryFault `yaml:"schedule"`
+}
+
+// RedisFaults enables the fault injector of Redis commands and its API /faults/redis.
+type RedisFaults struct {
+	Enabled  bool         `yaml:"enabled"`
+	Schedule []RedisFault `yaml:"schedule"`
+}
+
+// FaultWindow is when a scheduled fault starts (offset from startup) and how long it
+// lasts; a zero Duration keeps the fault until it is removed through the API.
+type FaultWindow struct {
+	At       time.Duration `yaml:"at"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+// Window returns w; the faults embedding a FaultWindow inherit it.
+func (w FaultWindow) Window() FaultWindow {
+	return w
+}
+
+// KafkaFault is a network fault of the connections to Broker, an address or "*" (the
+// default) for every broker.
+type KafkaFault struct {
+	FaultWindow `yaml:",inline"`
+	Broker      string `yaml:"broker"`
+	// Latency (plus up to Jitter) is added before every write to the broker.
+	Latency time.Duration `yaml:"latency"`
+	Jitter  time.Duration `yaml:"jitter"`
+	// BandwidthBytesPerSec limits throughput in both directions; 0 = unlimited.
+	BandwidthBytesPerSec int `yaml:"bandwidth_bytes_per_sec"`
+	// ResetRate is the probability (0..1) that a write resets the connection.
+	ResetRate float64 `yaml:"reset_rate"`
+	// Blackhole stalls reads, writes and new dials while the fault lasts.
+	Blackhole bool `yaml:"blackhole"`
+}
+
+// SchemaRegistryFault is a fault of Schema Registry HTTP calls.
+type SchemaRegistryFault struct {
+	FaultWindow `yaml:",inline"`
+	Delay       time.Duration `yaml:"delay"`
+	// ErrorRate is the probability (0..1) to answer with ErrorStatus (503 when zero).
+	ErrorRate   float64 `yaml:"error_rate"`
+	ErrorStatus int     `yaml:"error_status"`
+	// DropRate is the probability (0..1) to fail the call as a dropped connection.
+	DropRate float64 `yaml:"drop_rate"`
+}
+
+// RedisFault is a fault of Redis commands and dials.
+type RedisFault struct {
+	FaultWindow `yaml:",inline"`
+	Delay       time.Duration `yaml:"delay"`
+	// ErrorRate is the probability (0..1) that a command (or dial) fails.
+	ErrorRate float64 `yaml:"error_rate"`
+}
+
+// Trace exporters accepted in TRACING_EXPORTER.
+const (
+	TracingNone = "none"
+	TracingOTLP = "otlp"
+	TracingFile = "file"
+)
+
+// Tracing configures OpenTelemetry tracing of produced and consumed messages.
+type Tracing struct {
+	// Exporter is TracingNone (tracing disabled), TracingOTLP (OTLP/HTTP) or TracingFile
+	// (one JSON span per line, for offline analysis).
+	Exporter string `yaml:"exporter"`
+	// OTLPEndpoint is the OTLP/HTTP collector URL, e.g. http://otel-collector:4318; when
+	// empty the standard OTEL_EXPORTER_OTLP_* variables apply.
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+	File         string `yaml:"file"`
+	// SampleRatio of traces started by the producer (0..1); the consumer follows the
+	// producer's sampling decision carried in the message headers.
+	SampleRatio float64 `yaml:"sample_ratio"`
+	// ServiceName defaults to kafka-<mode>.
+	ServiceName string `yaml:"service_name"`
+}
+
+// Clock configures the clock offset measurements that keep cross-pod latencies meaningful
+// when clocks are shifted.
+type Clock struct {
+	// SyncInterval between measurements of the local clock against the Redis server time.
+	SyncInterval time.Duration `yaml:"sync_interval"`
+	// SkewWindow over which the minimum one-way delays that bound a broker's clock
+	// offset are taken; the bounds follow a clock shift within one window.
+	SkewWindow time.Duration `yaml:"skew_window"`
+}
+
+// SLO configures the delivery objectives evaluated by the consumer. The delivery
+// threshold is redis_slo_seconds.
+type SLO struct {
+	// Window is the rolling period of the error budget.
+	Window time.Duration `yaml:"window"`
+	// DeliveryTarget is the share of consumed messages to be delivered within the
+	// threshold, LossTarget the share of messages to be neither lost nor corrupted.
+	DeliveryTarget float64 `yaml:"delivery_target"`
+	LossTarget     float64 `yaml:"loss_target"`
+	// EvaluationInterval between updates of the SLO metrics and alerts.
+	EvaluationInterval time.Duration `yaml:"evaluation_interval"`
+	// WebhookURL receives a JSON notification when a burn-rate alert fires or resolves;
+	// empty disables notifications.
+	WebhookURL string `yaml:"webhook_url"`
+}
+
+// Experiments configures the chaos experiment context of logs and metrics.
+type Experiments struct {
+	// Name of an experiment active for the whole run, e.g. set by the pipeline that
+	// applies it.
+	Name string `yaml:"name"`
+	// GrafanaURL enables annotations through the Grafana HTTP API, authenticated with
+	// GrafanaToken (a service account token); GrafanaDashboardUID limits them to one
+	// dashboard, otherwise they are organization-wide.
+	GrafanaURL          string `yaml:"grafana_url"`
+	GrafanaToken        string `yaml:"grafana_token"`
+	GrafanaDashboardUID string `yaml:"grafana_dashboard_uid"`
+	// VictoriaMetricsURL enables start and end events pushed as samples through the
+	// import API of VictoriaMetrics (single-node or vminsert URL with the tenant path).
+	VictoriaMetricsURL string `yaml:"victoriametrics_url"`
+	// ChaosMeshNamespaces enables the watcher of Chaos Mesh resources in these
+	// namespaces: their experiments are active while the chaos is injected.
+	ChaosMeshNamespaces []string `yaml:"chaos_mesh_namespaces"`
+	// ChaosMeshResync is the period after which the watched resources are re-evaluated.
+	ChaosMeshResync time.Duration `yaml:"chaos_mesh_resync"`
+}
+
+// Inspector configures the cluster health inspection of the inspector mode.
+type Inspector struct {
+	// Topics whose partitions are inspected; the topic setting when empty.
+	Topics   []string      `yaml:"topics"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// Guard configures the abort of chaos experiments when safety thresholds are breached:
+// every Chaos Mesh resource in the experiments' chaos_mesh_namespaces is deleted.
+type Guard struct {
+	// PrometheusURL is the Prometheus-compatible query API the conditions are evaluated
+	// against (e.g. vmselect); empty disables the guard.
+	PrometheusURL string        `yaml:"prometheus_url"`
+	Interval      time.Duration `yaml:"interval"`
+	// MaxLag aborts when the lag of a partition stays above it for LagFor; 0 disables.
+	MaxLag int           `yaml:"max_lag"`
+	LagFor time.Duration `yaml:"lag_for"`
+	// AbortOnMismatch aborts as soon as a message body differs from Redis.
+	AbortOnMismatch bool `yaml:"abort_on_mismatch"`
+	// ProducerBlockedFor aborts when the producers send nothing but errors for this
+	// long; 0 disables.
+	ProducerBlockedFor time.Duration `yaml:"producer_blocked_for"`
+	// Conditions are additional abort conditions.
+	Conditions []GuardCondition `yaml:"conditions"`
+}
+
+// GuardCondition aborts when a series of the PromQL Query compares to Value with Op
+// (<, <=, ==, !=, >=, >) for at least For.
+type GuardCondition struct {
+	Name  string        `yaml:"name"`
+	Query string        `yaml:"query"`
+	Op    string        `yaml:"op"`
+	Value float64       `yaml:"value"`
+	For   time.Duration `yaml:"for"`
+}
+
+// Recovery configures the measurement of the time to recover after a fault ends.
+type Recovery struct {
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// StableFor is how long a signal must stay recovered to count as recovered.
+	StableFor time.Duration `yaml:"stable_for"`
+	// Timeout ends a measurement whose signals have not recovered.
+	Timeout time.Duration `yaml:"timeout"`
+	// LagTolerance and PendingTolerance are how far consumer lag and pending messages may
+	// stay above their value before the fault.
+	LagTolerance     int `yaml:"lag_tolerance"`
+	PendingTolerance int `yaml:"pending_tolerance"`
+	// ReportFile receives every finished measurement as a JSON line; empty disables it.
+	ReportFile string `yaml:"report_file"`
+}
+
+// SASL mechanisms accepted in KAFKA_SASL_MECHANISM. SCRAM and PLAIN authenticate with
+// username and password and are disabled without them; OAUTHBEARER with a token of the
+// oauth settings.
+const (
+	SASLScramSHA512 = "SCRAM-SHA-512"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLPlain       = "PLAIN"
+	SASLOAuthBearer = "OAUTHBEARER"
+)
+
+// OAuth configures the access tokens of the OAUTHBEARER mechanism, requested from an
+// OAuth 2.0 token endpoint (e.g. Keycloak) with the client credentials grant.
+type OAuth struct {
+	TokenURL     string `yaml:"token_url"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// Scope is sent when not empty.
+	Scope string `yaml:"scope"`
+	// RefreshRatio is the share of a token's lifetime after which new connections request
+	// a new one; the current token is used while the endpoint fails and it is valid.
+	RefreshRatio float64 `yaml:"refresh_ratio"`
+	// Timeout bounds a token request.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// KafkaTLS configures TLS of the broker connections. The files are re-read when they
+// change, so certificates rotated by Strimzi apply to new connections without a restart.
+type KafkaTLS struct {
+	Enabled bool `yaml:"enabled"`
+	// CAFile is the PEM bundle of CAs trusted for broker certificates, e.g. ca.crt of the
+	// <cluster>-cluster-ca-cert Secret; the system roots when empty.
+	CAFile string `yaml:"ca_file"`
+	// CertFile and KeyFile are the client certificate for mutual TLS, e.g. user.crt and
+	// user.key of the Secret of a KafkaUser with tls authentication.
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ServerName overrides the host name verified in broker certificates (SNI).
+	ServerName string `yaml:"server_name"`
+	// InsecureSkipVerify accepts any broker certificate; for labs only.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+	// ReloadInterval is how often the files are checked for changes.
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// Group balancer names accepted in KAFKA_CONSUMER_GROUP_BALANCERS.
+const (
+	BalancerRange      = "range"
+	BalancerRoundRobin = "round-robin"
+	BalancerRackAware  = "rack-aware"
+)
+
+// balancerAliases maps alternative spellings to the group balancer names.
+var balancerAliases = map[string]string{
+	"roundrobin":    BalancerRoundRobin,
+	"rack-affinity": BalancerRackAware,
+}
+
+// Delay distributions accepted in CONSUMER_PROCESSING_DELAY_DISTRIBUTION.
+const (
+	DelayFixed       = "fixed"
+	DelayUniform     = "uniform"
+	DelayNormal      = "normal"
+	DelayExponential = "exponential"
+)
+
+// ProcessingSimulation describes artificial downstream behaviour of the consumer. The zero
+// value disables every simulation.
+type ProcessingSimulation struct {
+	// Delay added to every message; Jitter is the spread for uniform/normal distributions.
+	Delay        time.Duration `yaml:"delay"`
+	Jitter       time.Duration `yaml:"jitter"`
+	Distribution string        `yaml:"distribution"`
+	// ErrorRate is the probability (0..1) that a processing attempt fails and is retried.
+	ErrorRate    float64       `yaml:"error_rate"`
+	MaxRetries   int           `yaml:"max_retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	// PauseInterval after joining the group the consumer stops polling for PauseDuration:
+	// its reader is closed, which stops the heartbeats and leaves the group, and the group
+	// rebalances without it until it rejoins.
+	PauseInterval time.Duration `yaml:"pause_interval"`
+	PauseDuration time.Duration `yaml:"pause_duration"`
+	// PanicRate is the probability (0..1) that a processing attempt panics; the panic fails
+	// the attempt like an error.
+	PanicRate float64 `yaml:"panic_rate"`
+}
+
+// Enabled reports whether any simulation is configured.
+func (s ProcessingSimulation) Enabled() bool {
+	return s.Delay > 0 || s.Jitter > 0 || s.ErrorRate > 0 || s.PauseInterval > 0 || s.PanicRate > 0
+}
+
+// Default returns the configuration used for every setting not given in the file or env.
+func Default() *Config {
+	return &Config{
+		Mode:                   ModeProducer,
+		Brokers:                []string{"localhost:9092"},
+		Topic:                  "test-topic",
+		SchemaRegistryURL:      "http://localhost:8081",
+		SASLMechanism:          SASLScramSHA512,
+		GroupID:                "test-group",
+		ProducerBatchSize:      50,
+		ProducerBatchTimeout:   50 * time.Millisecond,
+		ProducerIntervalMs:     100,
+		ProducerMaxAttempts:    5,
+		ProducerStartupDelay:   5 * time.Second,
+		ConsumerMinBytes:       5000,              // 5KB - ждать накопления 5KB перед возвратом данных
+		ConsumerMaxBytes:       100 * 1024 * 1024, // 100MB
+		ConsumerMaxWaitMs:      500,
+		ConsumerWorkers:        1,
+		ConsumerQueueSize:      100,
+		ConsumerCommitInterval: time.Second,
+		ConsumerSimulation: ProcessingSimulation{
+			Distribution: DelayFixed,
+			MaxRetries:   3,
+			RetryBackoff: 100 * time.Millisecond,
+		},
+		OAuth: OAuth{
+			RefreshRatio: 0.8,
+			Timeout:      10 * time.Second,
+		},
+		// Strimzi renews certificates days before they expire; polling is cheap
+		TLS: KafkaTLS{
+			ReloadInterval: 30 * time.Second,
+		},
+		// Schema Registry (Karapace) may take some time to respond after rollout/port-forward,
+		// hence the generous overall timeout.
+		SchemaRegistryHTTP: SchemaRegistryHTTP{
+			Timeout:        2 * time.Minute,
+			AttemptTimeout: 10 * time.Second,
+			MaxAttempts:    5,
+			BackoffMin:     200 * time.Millisecond,
+			BackoffMax:     5 * time.Second,
+		},
+		RedisAddr:                "localhost:6379",
+		RedisKeyPrefix:           "kafka-msg:",
+		RedisSLOSeconds:          120,
+		RedisKeyTTL:              24 * time.Hour,
+		RedisSpoolMaxRecords:     100000,
+		RedisSpoolReplayInterval: 5 * time.Second,
+		HealthPort:               8080,
+		// Redis is a verification side-channel: its outage is reported, but the pod keeps
+		// serving Kafka traffic.
+		Health: HealthPolicy{
+			Interval:         5 * time.Second,
+			Timeout:          2 * time.Second,
+			FailureThreshold: 3,
+			Critical:         []string{DependencyKafka, DependencySchemaRegistry},
+		},
+		Tracing: Tracing{
+			Exporter:    TracingNone,
+			SampleRatio: 1,
+		},
+		Clock: Clock{
+			SyncInterval: 10 * time.Second,
+			SkewWindow:   time.Minute,
+		},
+		SLO: SLO{
+			Window:             7 * 24 * time.Hour,
+			DeliveryTarget:     0.99,
+			LossTarget:         0.9999,
+			EvaluationInterval: 30 * time.Second,
+		},
+		Experiments: Experiments{
+			ChaosMeshResync: 10 * time.Minute,
+		},
+		Inspector: Inspector{
+			Interval: 15 * time.Second,
+		},
+		Guard: Guard{
+			Interval:           15 * time.Second,
+			LagFor:             5 * time.Minute,
+			AbortOnMismatch:    true,
+			ProducerBlockedFor: 5 * time.Minute,
+		},
+		Recovery: Recovery{
+			PollInterval:     5 * time.Second,
+			StableFor:        30 * time.Second,
+			Timeout:          30 * time.Minute,
+			LagTolerance:     100,
+			PendingTolerance: 1000,
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the file named by CONFIG_FILE (if set) and
+// environment variables, then validates it. The returned error joins every problem found,
+// so a misconfigured deployment fails once with the complete list.
+func Load() (*Config, error) {
+	cfg := Default()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	env := &envReader{}
+	env.apply(cfg)
+	cfg.normalize()
+	return cfg, errors.Join(append(env.errs, cfg.Validate()...)...)
+}
+
+// normalize rewrites names that are matched case-insensitively or have aliases to their
+// canonical form, so that Validate and the code using the configuration see one spelling.
+func (c *Config) normalize() {
+	for i, name := range c.ConsumerGroupBalancers {
+		name = strings.ToLower(name)
+		if alias, ok := balancerAliases[name]; ok {
+			name = alias
+		}
+		c.ConsumerGroupBalancers[i] = name
+	}
+}
+
+// loadFile overlays the settings of a YAML or JSON file (JSON is valid YAML) on cfg.
+// Durations are Go duration strings such as "500ms"; unknown keys are rejected.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// envReader applies environment overrides and collects the variables it could not parse.
+type envReader struct {
+	errs []error
+}
+
+func (r *envReader) apply(c *Config) {
+	r.string("MODE", &c.Mode)
+	r.list("KAFKA_BROKERS", &c.Brokers)
+	r.string("KAFKA_TOPIC", &c.Topic)
+	r.string("SCHEMA_REGISTRY_URL", &c.SchemaRegistryURL)
+	r.string("KAFKA_USERNAME", &c.Username)
+	r.string("KAFKA_PASSWORD", &c.Password)
+	r.string("KAFKA_SASL_MECHANISM", &c.SASLMechanism)
+	r.string("KAFKA_OAUTH_TOKEN_URL", &c.OAuth.TokenURL)
+	r.string("KAFKA_OAUTH_CLIENT_ID", &c.OAuth.ClientID)
+	r.string("KAFKA_OAUTH_CLIENT_SECRET", &c.OAuth.ClientSecret)
+	r.string("KAFKA_OAUTH_SCOPE", &c.OAuth.Scope)
+	r.float("KAFKA_OAUTH_REFRESH_RATIO", &c.OAuth.RefreshRatio)
+	r.millis("KAFKA_OAUTH_TIMEOUT_MS", &c.OAuth.Timeout)
+	r.bool("KAFKA_TLS_ENABLED", &c.TLS.Enabled)
+	r.string("KAFKA_TLS_CA_FILE", &c.TLS.CAFile)
+	r.string("KAFKA_TLS_CERT_FILE", &c.TLS.CertFile)
+	r.string("KAFKA_TLS_KEY_FILE", &c.TLS.KeyFile)
+	r.string("KAFKA_TLS_SERVER_NAME", &c.TLS.ServerName)
+	r.bool("KAFKA_TLS_INSECURE_SKIP_VERIFY", &c.TLS.InsecureSkipVerify)
+	r.millis("KAFKA_TLS_RELOAD_INTERVAL_MS", &c.TLS.ReloadInterval)
+	r.string("KAFKA_GROUP_ID", &c.GroupID)
+
+	r.int("KAFKA_PRODUCER_BATCH_SIZE", &c.ProducerBatchSize)
+	r.millis("KAFKA_PRODUCER_BATCH_TIMEOUT_MS", &c.ProducerBatchTimeout)
+	r.int("PRODUCER_INTERVAL_MS", &c.ProducerIntervalMs)
+	r.int("KAFKA_PRODUCER_MAX_ATTEMPTS", &c.ProducerMaxAttempts)
+	r.millis("KAFKA_PRODUCER_STARTUP_DELAY_MS", &c.ProducerStartupDelay)
+	r.string("MESSAGE_TEMPLATE_FILE", &c.MessageTemplateFile)
+
+	r.int("KAFKA_CONSUMER_MIN_BYTES", &c.ConsumerMinBytes)
+	r.int("KAFKA_CONSUMER_MAX_BYTES", &c.ConsumerMaxBytes)
+	r.int("KAFKA_CONSUMER_MAX_WAIT_MS", &c.ConsumerMaxWaitMs)
+	r.int("KAFKA_CONSUMER_WORKERS", &c.ConsumerWorkers)
+	r.int("KAFKA_CONSUMER_WORKER_QUEUE_SIZE", &c.ConsumerQueueSize)
+	r.millis("KAFKA_CONSUMER_COMMIT_INTERVAL_MS", &c.ConsumerCommitInterval)
+	r.list("KAFKA_CONSUMER_GROUP_BALANCERS", &c.ConsumerGroupBalancers)
+	r.string("KAFKA_CONSUMER_RACK", &c.ConsumerRack)
+
+	sim := &c.ConsumerSimulation
+	r.millis("CONSUMER_PROCESSING_DELAY_MS", &sim.Delay)
+	r.millis("CONSUMER_PROCESSING_JITTER_MS", &sim.Jitter)
+	r.string("CONSUMER_PROCESSING_DELAY_DISTRIBUTION", &sim.Distribution)
+	r.float("CONSUMER_PROCESSING_ERROR_RATE", &sim.ErrorRate)
+	r.int("CONSUMER_PROCESSING_MAX_RETRIES", &sim.MaxRetries)
+	r.millis("CONSUMER_PROCESSING_RETRY_BACKOFF_MS", &sim.RetryBackoff)
+	r.millis("CONSUMER_PAUSE_INTERVAL_MS", &sim.PauseInterval)
+	r.millis("CONSUMER_PAUSE_DURATION_MS", &sim.PauseDuration)
+	r.float("CONSUMER_PANIC_RATE", &sim.PanicRate)
+
+	sr := &c.SchemaRegistryHTTP
+	r.millis("SCHEMA_REGISTRY_TIMEOUT_MS", &sr.Timeout)
+	r.millis("SCHEMA_REGISTRY_ATTEMPT_TIMEOUT_MS", &sr.AttemptTimeout)
+	r.int("SCHEMA_REGISTRY_MAX_ATTEMPTS", &sr.MaxAttempts)
+	r.millis("SCHEMA_REGISTRY_BACKOFF_MIN_MS", &sr.BackoffMin)
+	r.millis("SCHEMA_REGISTRY_BACKOFF_MAX_MS", &sr.BackoffMax)
+
+	r.string("REDIS_ADDR", &c.RedisAddr)
+	r.string("REDIS_PASSWORD", &c.RedisPassword)
+	r.string("REDIS_KEY_PREFIX", &c.RedisKeyPrefix)
+	r.int("REDIS_SLO_SECONDS", &c.RedisSLOSeconds)
+	r.millis("REDIS_KEY_TTL_MS", &c.RedisKeyTTL)
+	r.int("REDIS_SPOOL_MAX_RECORDS", &c.RedisSpoolMaxRecords)
+	r.string("REDIS_SPOOL_FILE", &c.RedisSpoolFile)
+	r.millis("REDIS_SPOOL_REPLAY_INTERVAL_MS", &c.RedisSpoolReplayInterval)
+
+	r.millis("HEALTH_CHECK_INTERVAL_MS", &c.Health.Interval)
+	r.millis("HEALTH_CHECK_TIMEOUT_MS", &c.Health.Timeout)
+	r.int("HEALTH_FAILURE_THRESHOLD", &c.Health.FailureThreshold)
+	if s, ok := os.LookupEnv("HEALTH_CRITICAL_DEPENDENCIES"); ok {
+		// An empty value is meaningful here: no dependency affects readiness.
+		c.Health.Critical = parseList(s)
+	}
+
+	r.int("HEALTH_PORT", &c.HealthPort)
+	r.string("CONTROL_TOKEN", &c.ControlToken)
+	r.bool("KAFKA_FAULT_INJECTION", &c.Faults.Kafka.Enabled)
+	r.yaml("KAFKA_FAULT_SCHEDULE", &c.Faults.Kafka.Schedule)
+	r.bool("SCHEMA_REGISTRY_FAULT_INJECTION", &c.Faults.SchemaRegistry.Enabled)
+	r.yaml("SCHEMA_REGISTRY_FAULT_SCHEDULE", &c.Faults.SchemaRegistry.Schedule)
+	r.bool("REDIS_FAULT_INJECTION", &c.Faults.Redis.Enabled)
+	r.yaml("REDIS_FAULT_SCHEDULE", &c.Faults.Redis.Schedule)
+
+	r.string("TRACING_EXPORTER", &c.Tracing.Exporter)
+	r.string("TRACING_OTLP_ENDPOINT", &c.Tracing.OTLPEndpoint)
+	r.string("TRACING_FILE", &c.Tracing.File)
+	r.float("TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio)
+	r.string("TRACING_SERVICE_NAME", &c.Tracing.ServiceName)
+
+	r.millis("CLOCK_SYNC_INTERVAL_MS", &c.Clock.SyncInterval)
+	r.millis("CLOCK_SKEW_WINDOW_MS", &c.Clock.SkewWindow)
+
+	r.millis("SLO_WINDOW_MS", &c.SLO.Window)
+	r.float("SLO_DELIVERY_TARGET", &c.SLO.DeliveryTarget)
+	r.float("SLO_LOSS_TARGET", &c.SLO.LossTarget)
+	r.millis("SLO_EVALUATION_INTERVAL_MS", &c.SLO.EvaluationInterval)
+	r.string("SLO_WEBHOOK_URL", &c.SLO.WebhookURL)
+
+	r.string("EXPERIMENT_NAME", &c.Experiments.Name)
+	r.string("EXPERIMENT_GRAFANA_URL", &c.Experiments.GrafanaURL)
+	r.string("EXPERIMENT_GRAFANA_TOKEN", &c.Experiments.GrafanaToken)
+	r.string("EXPERIMENT_GRAFANA_DASHBOARD_UID", &c.Experiments.GrafanaDashboardUID)
+	r.string("EXPERIMENT_VICTORIAMETRICS_URL", &c.Experiments.VictoriaMetricsURL)
+	r.list("CHAOS_MESH_NAMESPACES", &c.Experiments.ChaosMeshNamespaces)
+	r.millis("CHAOS_MESH_RESYNC_MS", &c.Experiments.ChaosMeshResync)
+	r.list("INSPECTOR_TOPICS", &c.Inspector.Topics)
+	r.millis("INSPECTOR_INTERVAL_MS", &c.Inspector.Interval)
+	r.string("GUARD_PROMETHEUS_URL", &c.Guard.PrometheusURL)
+	r.millis("GUARD_INTERVAL_MS", &c.Guard.Interval)
+	r.int("GUARD_MAX_LAG", &c.Guard.MaxLag)
+	r.millis("GUARD_LAG_FOR_MS", &c.Guard.LagFor)
+	r.bool("GUARD_ABORT_ON_MISMATCH", &c.Guard.AbortOnMismatch)
+	r.millis("GUARD_PRODUCER_BLOCKED_FOR_MS", &c.Guard.ProducerBlockedFor)
+	r.millis("RECOVERY_POLL_INTERVAL_MS", &c.Recovery.PollInterval)
+	r.millis("RECOVERY_STABLE_FOR_MS", &c.Recovery.StableFor)
+	r.millis("RECOVERY_TIMEOUT_MS", &c.Recovery.Timeout)
+	r.int("RECOVERY_LAG_TOLERANCE", &c.Recovery.LagTolerance)
+	r.int("RECOVERY_PENDING_TOLERANCE", &c.Recovery.PendingTolerance)
+	r.string("RECOVERY_REPORT_FILE", &c.Recovery.ReportFile)
+}
+
+func (r *envReader) string(name string, dst *string) {
+	if s := os.Getenv(name); s != "" {
+		*dst = s
+	}
+}
+
+// list reads a comma-separated list.
+func (r *envReader) list(name string, dst *[]string) {
+	if s := os.Getenv(name); s != "" {
+		*dst = parseList(s)
+	}
+}
+
+func (r *envReader) int(name string, dst *int) {
+	if s := os.Getenv(name); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s=%q: not an integer", name, s))
+			return
+		}
+		*dst = n
+	}
+}
+
+// millis reads a duration given in milliseconds.
+func (r *envReader) millis(name string, dst *time.Duration) {
+	if s := os.Getenv(name); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s=%q: not an integer number of milliseconds", name, s))
+			return
+		}
+		*dst = time.Duration(n) * time.Millisecond
+	}
+}
+
+func (r *envReader) bool(name string, dst *bool) {
+	if s := os.Getenv(name); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s=%q: not a boolean", name, s))
+			return
+		}
+		*dst = b
+	}
+}
+
+func (r *envReader) float(name string, dst *float64) {
+	if s := os.Getenv(name); s != "" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s=%q: not a number", name, s))
+			return
+		}
+		*dst = f
+	}
+}
+
+// yaml reads a value given as YAML or JSON, such as a fault schedule; unknown keys are
+// rejected like in the config file.
+func (r *envReader) yaml(name string, dst interface{}) {
+	if s := os.Getenv(name); s != "" {
+		dec := yaml.NewDecoder(strings.NewReader(s))
+		dec.KnownFields(true)
+		if err := dec.Decode(dst); err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+}
+
+// parseList splits a comma-separated value and drops empty items.
+func parseList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	parts := strings.Split(value, ",")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		trimmed := strings.TrimSpace(part)
+		if trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadNormalizesGroupBalancers(t *testing.T) {
+	t.Setenv("KAFKA_CONSUMER_GROUP_BALANCERS", "Range, RoundRobin,Round-Robin")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []string{BalancerRange, BalancerRoundRobin, BalancerRoundRobin}
+	if !reflect.DeepEqual(cfg.ConsumerGroupBalancers, want) {
+		t.Errorf("ConsumerGroupBalancers = %q, want %q", cfg.ConsumerGroupBalancers, want)
+	}
+}
+
+func TestLoadRejectsGroupBalancers(t *testing.T) {
+	tests := []struct {
+		balancers string
+		rack      string
+		want      string
+	}{
+		{balancers: "sticky", want: `unknown balancer "sticky"`},
+		{balancers: "Rack-Affinity", want: `"rack-aware" requires consumer_rack`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.balancers, func(t *testing.T) {
+			t.Setenv("KAFKA_CONSUMER_GROUP_BALANCERS", tt.balancers)
+			t.Setenv("KAFKA_CONSUMER_RACK", tt.rack)
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFaultSchedule(t *testing.T) {
+	t.Setenv("KAFKA_FAULT_INJECTION", "True")
+	t.Setenv("KAFKA_FAULT_SCHEDULE", `[{"broker":"*","at":"30s","duration":"1m","latency":"200ms","blackhole":true}]`)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := KafkaFaults{Enabled: true, Schedule: []KafkaFault{{
+		FaultWindow: FaultWindow{At: 30 * time.Second, Duration: time.Minute},
+		Broker:      "*",
+		Latency:     200 * time.Millisecond,
+		Blackhole:   true,
+	}}}
+	if !reflect.DeepEqual(cfg.Faults.Kafka, want) {
+		t.Errorf("Faults.Kafka = %+v, want %+v", cfg.Faults.Kafka, want)
+	}
+}
+
+func TestLoadRejectsFaultSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "enabled is not a boolean",
+			env:  map[string]string{"REDIS_FAULT_INJECTION": "yes"},
+			want: `REDIS_FAULT_INJECTION="yes": not a boolean`,
+		},
+		{
+			name: "unknown key",
+			env:  map[string]string{"KAFKA_FAULT_INJECTION": "true", "KAFKA_FAULT_SCHEDULE": `[{"latancy":"1s"}]`},
+			want: "KAFKA_FAULT_SCHEDULE:",
+		},
+		{
+			name: "bad duration",
+			env:  map[string]string{"REDIS_FAULT_INJECTION": "true", "REDIS_FAULT_SCHEDULE": `[{"at":"soon"}]`},
+			want: "REDIS_FAULT_SCHEDULE:",
+		},
+		{
+			name: "rate out of range",
+			env:  map[string]string{"SCHEMA_REGISTRY_FAULT_INJECTION": "true", "SCHEMA_REGISTRY_FAULT_SCHEDULE": `[{"error_rate":1.5}]`},
+			want: "faults.schema_registry.schedule[0].error_rate 1.5: must be within [0, 1]",
+		},
+		{
+			name: "schedule without injection",
+			env:  map[string]string{"KAFKA_FAULT_SCHEDULE": `[{"reset_rate":0.1}]`},
+			want: "faults.kafka.schedule: requires faults.kafka.enabled",
+		},
+		{
+			name: "health port",
+			env:  map[string]string{"HEALTH_PORT": "70000"},
+			want: "health_port 70000: must be within [1, 65535]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsReservedRedisKeyPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{prefix: "metrics:", want: `redis_key_prefix "metrics:": overlaps the reserved "metrics:" keys`},
+		{prefix: "verified:kafka-msg:", want: `redis_key_prefix "verified:kafka-msg:": overlaps the reserved "verified:" keys`},
+		{prefix: "verified", want: `redis_key_prefix "verified": overlaps the reserved "verified:" keys`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			t.Setenv("REDIS_KEY_PREFIX", tt.prefix)
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsExperimentName(t *testing.T) {
+	t.Setenv("EXPERIMENT_NAME", "Pod Kill")
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), `experiments.name "Pod Kill"`) {
+		t.Errorf("Load error = %v, want an experiments.name error", err)
+	}
+}
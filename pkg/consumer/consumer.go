@@ -0,0 +1,404 @@
+// Package consumer reads test messages from Kafka on a per-partition worker pool, verifies
+// their delivery against Redis and commits only contiguous processed offsets.
+package consumer
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/clock"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/codec"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/control"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/experiment"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/health"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/kafkaclient"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/slo"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/tracing"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/verify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/riferrei/srclient"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Reader is the part of kafka.Reader used by the consumer.
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Deps holds the dependencies of a Consumer. Nil clients are created from the config when
+// Run starts; injected clients are not closed. With an injected Reader the consumer group
+// observability (rebalances, lag) that needs a real cluster is not started, topic
+// switches through Control are ignored and a simulated stall only stops fetching.
+type Deps struct {
+	Reader Reader
+	// NewReader creates the group reader of each session from the config built by the
+	// consumer, including the Logger that follows the group lifecycle; nil uses
+	// kafka.NewReader. Its readers are closed when their session ends.
+	NewReader      func(config kafka.ReaderConfig) Reader
+	SchemaRegistry *srclient.SchemaRegistryClient
+	Redis          *redis.Client
+
+	// KafkaDial, SchemaRegistryTransport and RedisHooks are used for created clients
+	// (fault injection); nil keeps the defaults.
+	KafkaDial               func(ctx context.Context, network, address string) (net.Conn, error)
+	SchemaRegistryTransport http.RoundTripper
+	RedisHooks              []redis.Hook
+	// KafkaSecurity authenticates and encrypts broker connections; the zero value means
+	// plaintext without authentication.
+	KafkaSecurity kafkaclient.Security
+
+	// Control carries runtime pause/resume and topic switches; it defaults to the state
+	// given by the config.
+	Control *control.Controller
+	// Experiments labels error counters with the active chaos experiments; nil means none.
+	Experiments *experiment.Tracker
+
+	// Metrics defaults to metrics registered on a private registry, Health to a status
+	// nobody reads and Logger to slog.Default().
+	Metrics *metrics.Metrics
+	Health  *health.Status
+	Logger  *slog.Logger
+}
+
+// Consumer consumes and verifies messages until its context is cancelled.
+type Consumer struct {
+	config  *config.Config
+	deps    Deps
+	control *control.Controller
+	metrics *metrics.Metrics
+	health  *health.Status
+	logger  *slog.Logger
+	latency *latencyRecorder
+	slo     *slo.Engine
+}
+
+func New(cfg *config.Config, deps Deps) *Consumer {
+	if deps.Metrics == nil {
+		deps.Metrics = metrics.New(prometheus.NewRegistry())
+	}
+	if deps.Health == nil {
+		deps.Health = &health.Status{}
+	}
+	if deps.Logger == nil {
+		deps.Logger = slog.Default()
+	}
+	if deps.Control == nil {
+		deps.Control = control.FromConfig(cfg, deps.Metrics, deps.Logger)
+	}
+	return &Consumer{config: cfg, deps: deps, control: deps.Control, metrics: deps.Metrics, health: deps.Health, logger: deps.Logger}
+}
+
+// Run consumes messages until ctx is cancelled. Messages already handed to the workers are
+// processed and their offsets committed before Run returns.
+func (c *Consumer) Run(ctx context.Context) error {
+	config := c.config
+	c.logger.Info("Starting consumer", "brokers", config.Brokers, "topic", config.Topic, "group_id", config.GroupID)
+
+	// Mark as healthy (process is running)
+	c.health.SetHealthy(true)
+
+	var dialer *kafka.Dialer
+	if c.deps.Reader == nil {
+		// Connections of the reader and the admin client are attributed to brokers; the
+		// probes below are not counted
+		tracker := kafkaclient.NewTracker(c.metrics, c.logger)
+		dialer = kafkaclient.NewDialer(tracker.Dial(c.deps.KafkaDial), c.deps.KafkaSecurity)
+		// Probe the bootstrap addresses and every discovered broker with a metadata request for
+		// readiness and connection status
+		probeDialer := kafkaclient.NewDialer(c.deps.KafkaDial, c.deps.KafkaSecurity)
+		kafkaclient.AddBrokerChecks(c.health, config, probeDialer, tracker, c.metrics)
+	}
+	c.health.AddCheck(codec.HealthCheck(config.SchemaRegistryURL, c.deps.SchemaRegistryTransport, c.metrics))
+
+	// Setup Schema Registry client (see producer)
+	schemaRegistryClient := c.deps.SchemaRegistry
+	if schemaRegistryClient == nil {
+		schemaRegistryClient = codec.NewSchemaRegistryClient(config.SchemaRegistryURL, config.SchemaRegistryHTTP,
+			c.deps.SchemaRegistryTransport, c.metrics, c.logger)
+	}
+	registry := codec.New(schemaRegistryClient, c.metrics)
+
+	// Redis client for delivery verification and SLO
+	rdb := c.deps.Redis
+	if rdb == nil && config.RedisAddr != "" {
+		rdb = verify.NewRedisClient(config.RedisAddr, config.RedisPassword, c.deps.RedisHooks...)
+		defer rdb.Close()
+	}
+	if rdb != nil {
+		c.health.AddCheck(verify.HealthCheck(rdb))
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			c.logger.Warn("Redis ping failed, delivery verification disabled", "error", err)
+			rdb = nil
+		} else {
+			c.logger.Info("Redis connected for delivery verification")
+		}
+	}
+	// Delivery SLOs are fed by end-to-end latencies and verification outcomes
+	sloThreshold := time.Duration(config.RedisSLOSeconds) * time.Second
+	c.slo = slo.New(config.SLO, sloThreshold, c.metrics, c.logger)
+	go c.slo.Run(ctx)
+
+	// Verifications that fail on Redis errors are spooled and replayed later; the verifier
+	// also maintains the Redis SLO metrics (pending count and old-pending count)
+	var verifier *verify.Verifier
+	if rdb != nil {
+		verifier = verify.New(rdb, verify.Options{
+			KeyPrefix:           config.RedisKeyPrefix,
+			KeyTTL:              config.RedisKeyTTL,
+			SpoolMaxRecords:     config.RedisSpoolMaxRecords,
+			SpoolFile:           config.RedisSpoolFile,
+			SpoolReplayInterval: config.RedisSpoolReplayInterval,
+			SLOThreshold:        sloThreshold,
+			Observer:            c.slo,
+		}, c.metrics, c.logger)
+		go verifier.Run(ctx)
+	}
+	// Latencies are taken in Redis server time, which TimeChaos on the Kafka or application
+	// pods does not shift; without Redis the local clock is used as is
+	var clockSync *clock.Sync
+	if rdb != nil {
+		clockSync = clock.NewSync(rdb, config.Clock.SyncInterval, c.metrics, c.logger)
+		go clockSync.Run(ctx)
+	}
+	c.latency = newLatencyRecorder(clockSync, config.Clock.SkewWindow, c.metrics, c.logger)
+
+	// Mark as ready (connected to Kafka and Schema Registry)
+	c.health.SetReady(true)
+	c.logger.Info("Consumer is ready")
+
+	// Each session consumes one topic; a topic switch through the control API ends the
+	// session and starts the next one on the new topic, a simulated stall ends it and
+	// starts the next one after the pause.
+	for {
+		topic := c.control.State().Topic
+		end, err := c.consume(ctx, topic, dialer, registry, verifier)
+		if err != nil || end == sessionStopped {
+			return err
+		}
+		if end == sessionStalled {
+			select {
+			case <-ctx.Done():
+				c.logger.Info("Consumer stopped")
+				return nil
+			case <-time.After(config.ConsumerSimulation.PauseDuration):
+			}
+			c.logger.Warn("Simulated poll stall ended, rejoining the group", "topic", topic)
+			continue
+		}
+		c.logger.Info("Switching topic", "from", topic, "to", c.control.State().Topic)
+	}
+}
+
+// sessionEnd tells Run why a consumer session ended.
+type sessionEnd int
+
+const (
+	// sessionStopped: the context was cancelled.
+	sessionStopped sessionEnd = iota
+	// sessionSwitched: the control state selects another topic.
+	sessionSwitched
+	// sessionStalled: a simulated poll stall closed the reader (see scheduleStall).
+	sessionStalled
+)
+
+// consume runs one consumer session on topic until ctx is cancelled, the control state
+// selects another topic or a simulated stall begins. dialer is nil when the Reader is
+// injected.
+func (c *Consumer) consume(ctx context.Context, topic string, dialer *kafka.Dialer, registry *codec.Codec, verifier *verify.Verifier) (sessionEnd, error) {
+	config := c.config
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reader := c.deps.Reader
+	var rebalances *rebalanceObserver
+	if reader == nil {
+		// Rebalance observer receives the reader's group lifecycle log messages
+		rebalances = newRebalanceObserver(topic, config.GroupID, c.metrics, c.logger)
+
+		// Setup Kafka reader
+		newReader := c.deps.NewReader
+		if newReader == nil {
+			newReader = func(config kafka.ReaderConfig) Reader { return kafka.NewReader(config) }
+		}
+		kafkaReader := newReader(kafka.ReaderConfig{
+			Brokers:        config.Brokers,
+			Topic:          topic,
+			GroupID:        config.GroupID,
+			GroupBalancers: parseGroupBalancers(config.ConsumerGroupBalancers, config.ConsumerRack),
+			MinBytes:       config.ConsumerMinBytes, // 5KB по умолчанию - ждать накопления перед ответом
+			MaxBytes:       config.ConsumerMaxBytes, // 100MB - при высокой нагрузке читать до 100MB за раз
+			MaxWait:        time.Duration(config.ConsumerMaxWaitMs) * time.Millisecond,
+			Dialer:         dialer,
+			Logger:         rebalances,
+			ErrorLogger:    kafkaErrorLogger("reader", c.logger),
+		})
+		defer kafkaReader.Close()
+		reader = kafkaReader
+
+		// Setup Admin client for lag metrics
+		transport := &kafka.Transport{
+			SASL: dialer.SASLMechanism,
+			TLS:  dialer.TLS,
+			Dial: dialer.DialFunc,
+		}
+		adminClient := &kafka.Client{
+			Addr:      kafka.TCP(config.Brokers...),
+			Timeout:   10 * time.Second,
+			Transport: transport,
+		}
+
+		// Start lag metrics updater in background (needs the admin API of a real cluster)
+		go c.updateConsumerLag(sessionCtx, topic, adminClient, dialer)
+
+		// End the session when the topic is switched
+		go func() {
+			for {
+				changed := c.control.Changed()
+				if c.control.State().Topic != topic {
+					cancel()
+					return
+				}
+				select {
+				case <-sessionCtx.Done():
+					return
+				case <-changed:
+				}
+			}
+		}()
+	}
+
+	// Messages are fetched here and processed on the worker pool; offsets are committed
+	// by commitProcessedOffsets only up to the contiguous processed range per partition.
+	process := func(ctx context.Context, msg kafka.Message) {
+		c.handleMessage(ctx, topic, registry, verifier, msg)
+	}
+	if config.ConsumerSimulation.Enabled() {
+		c.logger.Info("Simulated processing behaviour enabled", "simulation", config.ConsumerSimulation)
+		simulator := newProcessingSimulator(config.ConsumerSimulation, topic, c.metrics, c.logger)
+		handle := process
+		process = func(ctx context.Context, msg kafka.Message) {
+			simulator.process(ctx, msg, func() { handle(ctx, msg) })
+		}
+	}
+	// The processing span continues the producer's trace from the message headers and
+	// covers simulated delays and retries
+	untraced := process
+	process = func(ctx context.Context, msg kafka.Message) {
+		ctx, span := tracing.StartConsume(ctx, config.GroupID, msg)
+		defer span.End()
+		untraced(ctx, msg)
+	}
+	pool := newWorkerPool(topic, config.ConsumerWorkers, config.ConsumerQueueSize, process, c.metrics, c.logger)
+	// Workers keep running on a non-cancellable context so that queued messages are fully
+	// verified before the final commit on shutdown.
+	pool.start(context.WithoutCancel(ctx))
+	if rebalances != nil {
+		rebalances.setOnAssign(func(assigned, revoked []int) {
+			pool.tracker.forget(revoked)
+		})
+	}
+	go c.commitProcessedOffsets(sessionCtx, reader, pool.tracker)
+	stalled := scheduleStall(sessionCtx, config.ConsumerSimulation, cancel)
+	defer func() {
+		pool.stop()
+		// ctx is already cancelled here; use a fresh one for the final commit.
+		commitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		c.commitOffsets(commitCtx, reader, pool.tracker)
+	}()
+
+	for {
+		msg, err := reader.FetchMessage(sessionCtx)
+		if sessionCtx.Err() != nil {
+			break
+		}
+		if err != nil {
+			class := kafkaclient.Classify(err)
+			c.logger.Error("Error reading message", "error", err,
+				"error_type", class.Type, "error_code", class.Code, "retriable", class.Retriable)
+			c.metrics.ConsumerErrorsTotal.WithLabelValues(topic, "read", c.deps.Experiments.Label()).Inc()
+			c.metrics.ConsumerReadErrorsTotal.WithLabelValues(topic, class.Type, class.CodeLabel(), class.RetriableLabel(),
+				c.deps.Experiments.Label()).Inc()
+			time.Sleep(1 * time.Second)
+			continue
+		}
+
+		// While paused the fetched message is held back; its offset is not committed, so
+		// it is redelivered if the session ends before resume.
+		if c.control.WaitResumed(sessionCtx) != nil {
+			break
+		}
+		if !pool.dispatch(sessionCtx, msg) {
+			break
+		}
+	}
+	switch {
+	case ctx.Err() != nil:
+		c.logger.Info("Consumer stopped")
+		return sessionStopped, nil
+	case stalled.Load():
+		c.metrics.ConsumerSimulatedPausesTotal.WithLabelValues(topic).Inc()
+		c.logger.Warn("Simulated poll stall started, leaving the group", "topic", topic,
+			"duration", config.ConsumerSimulation.PauseDuration.String())
+		return sessionStalled, nil
+	}
+	return sessionSwitched, nil
+}
+
+// handleMessage decodes msg, verifies it against Redis and records consumer metrics.
+// It runs on a pool worker; messages of one partition are handled sequentially.
+func (c *Consumer) handleMessage(ctx context.Context, topic string, registry *codec.Codec, verifier *verify.Verifier, msg kafka.Message) {
+	processStart := time.Now()
+	partitionStr := strconv.Itoa(msg.Partition)
+
+	// Decode message using Confluent wire format
+	decodeStart := time.Now()
+	decodeCtx, decodeSpan := tracing.Start(ctx, "avro decode")
+	decoded, err := registry.Decode(decodeCtx, msg.Value)
+	decodeSpan.End()
+	decodeDuration := time.Since(decodeStart).Seconds()
+	c.metrics.ConsumerMessageDecodeDuration.WithLabelValues(topic, partitionStr).Observe(decodeDuration)
+
+	if err != nil {
+		c.logger.Error("Failed to decode message", "error", err)
+		c.metrics.ConsumerErrorsTotal.WithLabelValues(topic, "decode", c.deps.Experiments.Label()).Inc()
+		tracing.RecordError(trace.SpanFromContext(ctx), err, "decode")
+		return
+	}
+
+	processingDuration := time.Since(processStart).Seconds()
+	c.metrics.ConsumerMessageProcessingDuration.WithLabelValues(topic, partitionStr).Observe(processingDuration)
+
+	// Delivery verification via Redis: compare content hash (id+data only), see verify.Verifier.
+	if verifier != nil {
+		outcome := verifier.VerifyReceived(ctx, msg, decoded)
+		if outcome == "" {
+			outcome = "spooled"
+		}
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("verification.outcome", outcome))
+	}
+
+	// End-to-end latency and its split at the broker, in reference time
+	if produced, err := payloadTimestamp(decoded); err != nil {
+		c.logger.Debug("Timestamp has unsupported type", "error", err)
+	} else if !produced.IsZero() {
+		c.slo.Delivered(c.latency.record(ctx, topic, msg, produced))
+	}
+
+	// Update metrics
+	c.metrics.ConsumerMessagesReceivedTotal.WithLabelValues(topic, partitionStr).Inc()
+	c.metrics.ConsumerMessagesReceivedBytes.WithLabelValues(topic, partitionStr).Add(float64(len(msg.Value)))
+
+	c.logger.Info("Received message", "key", string(msg.Key), "value", decoded, "partition", msg.Partition, "offset", msg.Offset)
+}
@@ -0,0 +1,123 @@
+package consumer
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+)
+
+// topicPartition mirrors the unexported key of the offsets kafka-go logs on subscribe.
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// groupReaders creates readers that log the group lifecycle the way kafka-go does: a
+// reader joins the next generation and is assigned partition 0 when it is created, and
+// ends its generation when it is closed. They return no messages.
+type groupReaders struct {
+	mu     sync.Mutex
+	opened []time.Time
+	closed []time.Time
+}
+
+func (g *groupReaders) newReader(cfg kafka.ReaderConfig) Reader {
+	g.mu.Lock()
+	g.opened = append(g.opened, time.Now())
+	generation := int32(len(g.opened))
+	g.mu.Unlock()
+	cfg.Logger.Printf("Joined group %s as member %s in generation %d", cfg.GroupID, "member-1", generation)
+	cfg.Logger.Printf("subscribed to topics and partitions: %+v", map[topicPartition]int64{{cfg.Topic, 0}: 0})
+	return &groupReader{readers: g, config: cfg}
+}
+
+func (g *groupReaders) times() (opened, closed []time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]time.Time(nil), g.opened...), append([]time.Time(nil), g.closed...)
+}
+
+type groupReader struct {
+	readers *groupReaders
+	config  kafka.ReaderConfig
+}
+
+func (r *groupReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (r *groupReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	return nil
+}
+
+func (r *groupReader) Close() error {
+	r.config.Logger.Printf("stopped commit for group %s", r.config.GroupID)
+	r.readers.mu.Lock()
+	r.readers.closed = append(r.readers.closed, time.Now())
+	r.readers.mu.Unlock()
+	return nil
+}
+
+func TestSimulatedStallLeavesTheGroup(t *testing.T) {
+	cfg := config.Default()
+	cfg.Brokers, cfg.RedisAddr = []string{"127.0.0.1:1"}, ""
+	cfg.Mode, cfg.Topic, cfg.GroupID = config.ModeConsumer, "chaos", "chaos-group"
+	cfg.ConsumerSimulation.PauseInterval = 50 * time.Millisecond
+	cfg.ConsumerSimulation.PauseDuration = 200 * time.Millisecond
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	readers := &groupReaders{}
+	m := metrics.New(prometheus.NewRegistry())
+	c := New(cfg, Deps{
+		NewReader: readers.newReader,
+		Metrics:   m,
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if opened, _ := readers.times(); len(opened) >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("consumer did not rejoin the group after the stall")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// The reader was closed during the stall and the group rebalanced on leaving and rejoining
+	opened, closed := readers.times()
+	if len(closed) == 0 || closed[0].After(opened[1]) {
+		t.Fatalf("first reader closed at %v, want before the second was opened at %v", closed, opened[1])
+	}
+	if stall := opened[1].Sub(closed[0]); stall < cfg.ConsumerSimulation.PauseDuration {
+		t.Errorf("rejoined %v after leaving, want at least %v", stall, cfg.ConsumerSimulation.PauseDuration)
+	}
+	if got := testutil.ToFloat64(m.ConsumerRebalancesTotal.WithLabelValues("chaos", "chaos-group")); got < 2 {
+		t.Errorf("kafka_consumer_rebalances_total = %v, want at least 2", got)
+	}
+	if got := testutil.ToFloat64(m.ConsumerGroupGeneration.WithLabelValues("chaos", "chaos-group")); got < 2 {
+		t.Errorf("kafka_consumer_group_generation = %v, want at least 2", got)
+	}
+	if got := testutil.ToFloat64(m.ConsumerSimulatedPausesTotal.WithLabelValues("chaos")); got < 1 {
+		t.Errorf("kafka_consumer_simulated_pauses_total = %v, want at least 1", got)
+	}
+}
@@ -0,0 +1,114 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// updateConsumerLag periodically updates consumer lag metrics of topic
+func (c *Consumer) updateConsumerLag(ctx context.Context, topic string, adminClient *kafka.Client, dialer *kafka.Dialer) {
+	config := c.config
+	ticker := time.NewTicker(30 * time.Second) // Update every 30 seconds
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Connect to first broker to get partition info
+			conn, err := dialer.DialContext(ctx, "tcp", config.Brokers[0])
+			if err != nil {
+				c.logger.Debug("Failed to dial broker for lag metrics", "error", err)
+				continue
+			}
+
+			// Get all partitions for the topic
+			partitions, err := conn.ReadPartitions(topic)
+			if err != nil {
+				c.logger.Debug("Failed to read partitions", "error", err)
+				conn.Close()
+				continue
+			}
+
+			// Build map of partitions for Admin API
+			partitionIDs := make([]int, 0, len(partitions))
+			for _, p := range partitions {
+				partitionIDs = append(partitionIDs, p.ID)
+			}
+
+			// Get consumer group committed offsets using OffsetFetch API
+			offsetFetchResp, err := adminClient.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+				GroupID: config.GroupID,
+				Topics: map[string][]int{
+					topic: partitionIDs,
+				},
+			})
+			if err != nil {
+				c.logger.Debug("Failed to get consumer group offsets", "error", err)
+				conn.Close()
+				continue
+			}
+
+			// Build OffsetRequests for high watermark (LastOffset per partition)
+			offsetReqs := make([]kafka.OffsetRequest, len(partitionIDs))
+			for i, id := range partitionIDs {
+				offsetReqs[i] = kafka.LastOffsetOf(id)
+			}
+			listOffsetsResp, err := adminClient.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+				Topics: map[string][]kafka.OffsetRequest{
+					topic: offsetReqs,
+				},
+			})
+			if err != nil {
+				c.logger.Debug("Failed to get partition offsets", "error", err)
+				conn.Close()
+				continue
+			}
+
+			// For each partition, calculate lag
+			for _, partition := range partitions {
+				// Get high watermark from ListOffsets response
+				highWatermark := int64(-1)
+				if topicOffsets, ok := listOffsetsResp.Topics[topic]; ok {
+					for _, po := range topicOffsets {
+						if po.Partition == partition.ID {
+							highWatermark = po.LastOffset
+							break
+						}
+					}
+				}
+				if highWatermark < 0 {
+					c.logger.Debug("Failed to get high watermark", "partition", partition.ID)
+					continue
+				}
+
+				// Get consumer group committed offset from OffsetFetch response
+				consumerOffset := int64(-1)
+				if topicParts, ok := offsetFetchResp.Topics[topic]; ok {
+					for _, p := range topicParts {
+						if p.Partition == partition.ID {
+							consumerOffset = p.CommittedOffset
+							break
+						}
+					}
+				}
+
+				// Calculate lag: highWatermark - consumerOffset
+				if consumerOffset >= 0 {
+					lag := highWatermark - consumerOffset
+					if lag < 0 {
+						lag = 0
+					}
+					partitionStr := fmt.Sprintf("%d", partition.ID)
+					c.metrics.ConsumerLag.WithLabelValues(topic, partitionStr, config.GroupID).Set(float64(lag))
+				}
+			}
+
+			conn.Close()
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/clock"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// latencyRecorder records the latency of consumed messages in reference time (see package
+// clock): end to end and, when the topic uses LogAppendTime, split at the broker append.
+// The append time is on the broker's clock and is not corrected: a broker offset moves
+// time from one leg to the other, and its estimated range is recorded next to them.
+type latencyRecorder struct {
+	clock   *clock.Sync
+	skew    *clock.SkewEstimator
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+
+	createTimeOnce sync.Once
+}
+
+func newLatencyRecorder(clockSync *clock.Sync, skewWindow time.Duration, m *metrics.Metrics, logger *slog.Logger) *latencyRecorder {
+	return &latencyRecorder{clock: clockSync, skew: clock.NewSkewEstimator(skewWindow), metrics: m, logger: logger}
+}
+
+// record observes the latencies of msg, produced at the payload timestamp produced (on the
+// producer's clock), and returns its end-to-end latency.
+func (r *latencyRecorder) record(ctx context.Context, topic string, msg kafka.Message, produced time.Time) time.Duration {
+	consumed := r.clock.Now()
+	partition := strconv.Itoa(msg.Partition)
+	exemplar := metrics.Exemplar{Key: string(msg.Key), Partition: partition, Offset: strconv.FormatInt(msg.Offset, 10)}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsSampled() {
+		exemplar.TraceID = sc.TraceID().String()
+	}
+
+	// The producer sets the record timestamp to the payload timestamp; a different record
+	// timestamp was set by the broker (message.timestamp.type=LogAppendTime)
+	logAppendTime := !msg.Time.IsZero() && msg.Time.UnixMilli() != produced.UnixMilli()
+	produced = produced.Add(-clock.ProducerOffset(msg))
+	endToEnd := consumed.Sub(produced)
+	metrics.ObserveWithExemplar(r.metrics.ConsumerEndToEndLatency.WithLabelValues(topic, partition),
+		nonNegative(endToEnd), exemplar)
+	if !logAppendTime {
+		r.createTimeOnce.Do(func() {
+			r.logger.Info("Record timestamps are producer CreateTime, produce→broker latency is not recorded; set message.timestamp.type=LogAppendTime on the topic to split latency at the broker", "topic", topic)
+		})
+		return endToEnd
+	}
+
+	appended := msg.Time
+	up, down := appended.Sub(produced), consumed.Sub(appended)
+	skew := r.skew.Observe(topic+"/"+partition, up, down)
+	r.metrics.ConsumerBrokerClockOffset.WithLabelValues(topic, partition).Set(skew.Offset().Seconds())
+	r.metrics.ConsumerBrokerClockOffsetUncertainty.WithLabelValues(topic, partition).Set(skew.Uncertainty().Seconds())
+	metrics.ObserveWithExemplar(r.metrics.ConsumerProduceToBrokerLatency.WithLabelValues(topic, partition),
+		nonNegative(up), exemplar)
+	metrics.ObserveWithExemplar(r.metrics.ConsumerBrokerToConsumeLatency.WithLabelValues(topic, partition),
+		nonNegative(down), exemplar)
+	return endToEnd
+}
+
+// nonNegative returns d in seconds; residual clock error can make a short delay negative.
+func nonNegative(d time.Duration) float64 {
+	return max(d, 0).Seconds()
+}
+
+// payloadTimestamp returns the timestamp field of a decoded message, the zero time when
+// there is none.
+func payloadTimestamp(decoded interface{}) (time.Time, error) {
+	decodedMap, ok := decoded.(map[string]interface{})
+	if !ok {
+		return time.Time{}, nil
+	}
+	switch v := decodedMap["timestamp"].(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		// goavro may decode timestamp-millis logicalType as time.Time
+		return v, nil
+	case int64:
+		return time.UnixMilli(v), nil
+	case int32:
+		return time.UnixMilli(int64(v)), nil
+	case int:
+		return time.UnixMilli(int64(v)), nil
+	case float64:
+		return time.UnixMilli(int64(v)), nil
+	case float32:
+		return time.UnixMilli(int64(v)), nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported timestamp type %T", v)
+	}
+}
@@ -0,0 +1,172 @@
+package consumer
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/segmentio/kafka-go"
+)
+
+// parseGroupBalancers converts a priority-ordered list of balancer names into kafka-go
+// balancers. The names are normalized and validated by the config package; an empty list
+// leaves the reader defaults.
+func parseGroupBalancers(names []string, rack string) []kafka.GroupBalancer {
+	balancers := make([]kafka.GroupBalancer, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case config.BalancerRange:
+			balancers = append(balancers, kafka.RangeGroupBalancer{})
+		case config.BalancerRoundRobin:
+			balancers = append(balancers, kafka.RoundRobinGroupBalancer{})
+		case config.BalancerRackAware:
+			balancers = append(balancers, kafka.RackAffinityGroupBalancer{Rack: rack})
+		}
+	}
+	return balancers
+}
+
+// rebalanceObserver follows the consumer group lifecycle of a kafka.Reader. The reader has
+// no rebalance callbacks, so the observer is installed as its Logger and reacts to the
+// lifecycle messages kafka-go emits: the end of a generation ("stopped commit"), joining
+// a new generation and subscribing to the assigned partitions.
+type rebalanceObserver struct {
+	topic   string
+	groupID string
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+
+	mu         sync.Mutex
+	onAssign   func(assigned, revoked []int)
+	started    time.Time
+	generation int32
+	memberID   string
+	assigned   map[int]bool
+}
+
+func newRebalanceObserver(topic, groupID string, m *metrics.Metrics, logger *slog.Logger) *rebalanceObserver {
+	return &rebalanceObserver{
+		topic:    topic,
+		groupID:  groupID,
+		metrics:  m,
+		logger:   logger,
+		started:  time.Now(),
+		assigned: make(map[int]bool),
+	}
+}
+
+// setOnAssign registers a callback invoked after every completed rebalance with the newly
+// assigned and the revoked partitions of the topic.
+func (o *rebalanceObserver) setOnAssign(fn func(assigned, revoked []int)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.onAssign = fn
+}
+
+// Printf implements kafka.Logger.
+func (o *rebalanceObserver) Printf(format string, args ...interface{}) {
+	switch {
+	case strings.HasPrefix(format, "stopped commit for group"):
+		o.generationEnded()
+	case strings.HasPrefix(format, "Joined group %s as member %s in generation %d") && len(args) == 3:
+		memberID, _ := args[1].(string)
+		generation, _ := args[2].(int32)
+		o.joined(memberID, generation)
+	case strings.HasPrefix(format, "subscribed to topics and partitions") && len(args) == 1:
+		o.subscribed(assignedPartitions(args[0], o.topic))
+	default:
+		o.logger.Debug("Kafka reader", "message", strings.TrimSpace(fmt.Sprintf(format, args...)))
+	}
+}
+
+func (o *rebalanceObserver) generationEnded() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.started = time.Now()
+	o.logger.Info("Consumer group rebalance started", "group_id", o.groupID, "topic", o.topic,
+		"generation_id", o.generation, "member_id", o.memberID)
+}
+
+func (o *rebalanceObserver) joined(memberID string, generation int32) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.memberID = memberID
+	o.generation = generation
+	o.metrics.ConsumerGroupGeneration.WithLabelValues(o.topic, o.groupID).Set(float64(generation))
+}
+
+func (o *rebalanceObserver) subscribed(partitions []int) {
+	o.mu.Lock()
+	current := make(map[int]bool, len(partitions))
+	var added, revoked []int
+	for _, p := range partitions {
+		current[p] = true
+		if !o.assigned[p] {
+			added = append(added, p)
+		}
+	}
+	for p := range o.assigned {
+		if !current[p] {
+			revoked = append(revoked, p)
+		}
+	}
+	sort.Ints(revoked)
+	o.assigned = current
+	duration := time.Since(o.started)
+	generation, memberID, onAssign := o.generation, o.memberID, o.onAssign
+	o.mu.Unlock()
+
+	o.metrics.ConsumerRebalancesTotal.WithLabelValues(o.topic, o.groupID).Inc()
+	o.metrics.ConsumerRebalanceDuration.WithLabelValues(o.topic, o.groupID).Observe(duration.Seconds())
+	for _, p := range revoked {
+		o.metrics.ConsumerAssignedPartitions.DeleteLabelValues(o.topic, o.groupID, strconv.Itoa(p))
+	}
+	for _, p := range partitions {
+		o.metrics.ConsumerAssignedPartitions.WithLabelValues(o.topic, o.groupID, strconv.Itoa(p)).Set(1)
+	}
+
+	o.logger.Info("Consumer group rebalance completed", "group_id", o.groupID, "topic", o.topic,
+		"generation_id", generation, "member_id", memberID, "duration_seconds", duration.Seconds(),
+		"assigned_partitions", partitions, "added_partitions", added, "revoked_partitions", revoked)
+
+	if onAssign != nil {
+		onAssign(added, revoked)
+	}
+}
+
+// assignedPartitions extracts the partitions of topic from the offsets map the reader logs
+// on subscribe. The map key type is unexported in kafka-go, so it is read via reflection.
+func assignedPartitions(offsets interface{}, topic string) []int {
+	v := reflect.ValueOf(offsets)
+	if v.Kind() != reflect.Map {
+		return nil
+	}
+	partitions := make([]int, 0, v.Len())
+	for _, key := range v.MapKeys() {
+		if key.Kind() != reflect.Struct {
+			continue
+		}
+		t := key.FieldByName("topic")
+		p := key.FieldByName("partition")
+		if !t.IsValid() || !p.IsValid() || t.String() != topic {
+			continue
+		}
+		partitions = append(partitions, int(p.Int()))
+	}
+	sort.Ints(partitions)
+	return partitions
+}
+
+// kafkaErrorLogger forwards kafka-go reader errors to the application logger.
+func kafkaErrorLogger(component string, logger *slog.Logger) kafka.Logger {
+	return kafka.LoggerFunc(func(format string, args ...interface{}) {
+		logger.Warn("Kafka client error", "component", component, "error", strings.TrimSpace(fmt.Sprintf(format, args...)))
+	})
+}
@@ -0,0 +1,126 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/segmentio/kafka-go"
+)
+
+var (
+	errSimulatedProcessing = errors.New("simulated processing error")
+	errSimulatedPanic      = errors.New("simulated panic")
+)
+
+// processingSimulator applies ProcessingSimulation around the real message handler.
+type processingSimulator struct {
+	cfg     config.ProcessingSimulation
+	topic   string
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+}
+
+func newProcessingSimulator(cfg config.ProcessingSimulation, topic string, m *metrics.Metrics, logger *slog.Logger) *processingSimulator {
+	return &processingSimulator{cfg: cfg, topic: topic, metrics: m, logger: logger}
+}
+
+// process runs handle for msg with simulated delays, errors and panics. Failed attempts
+// are retried with backoff; after MaxRetries the message is dropped, the way a consumer
+// without a dead-letter queue would lose it. Long pauses are simulated per session by
+// scheduleStall.
+func (s *processingSimulator) process(ctx context.Context, msg kafka.Message, handle func()) {
+	partitionStr := strconv.Itoa(msg.Partition)
+	for attempt := 0; ; attempt++ {
+		err := s.attempt(ctx, partitionStr)
+		if err == nil {
+			handle()
+			return
+		}
+		if attempt >= s.cfg.MaxRetries {
+			s.logger.Error("Processing failed, dropping message", "error", err, "key", string(msg.Key),
+				"partition", msg.Partition, "offset", msg.Offset, "attempts", attempt+1)
+			s.metrics.ConsumerProcessingFailuresTotal.WithLabelValues(s.topic, partitionStr).Inc()
+			return
+		}
+		s.metrics.ConsumerProcessingRetriesTotal.WithLabelValues(s.topic, partitionStr).Inc()
+		s.logger.Warn("Processing failed, retrying", "error", err, "key", string(msg.Key), "attempt", attempt+1)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.cfg.RetryBackoff * time.Duration(1<<min(attempt, 10))):
+		}
+	}
+}
+
+// attempt performs the simulated part of one processing attempt. A simulated panic is
+// recovered here and fails the attempt like an error, so the message is retried and then
+// dropped instead of being reported as processed.
+func (s *processingSimulator) attempt(ctx context.Context, partitionStr string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errSimulatedPanic, r)
+		}
+	}()
+	if delay := s.delay(); delay > 0 {
+		s.metrics.ConsumerSimulatedDelay.WithLabelValues(s.topic, partitionStr).Observe(delay.Seconds())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	if s.cfg.PanicRate > 0 && rand.Float64() < s.cfg.PanicRate {
+		panic(fmt.Sprintf("simulated panic while processing partition %s", partitionStr))
+	}
+	if s.cfg.ErrorRate > 0 && rand.Float64() < s.cfg.ErrorRate {
+		return errSimulatedProcessing
+	}
+	return nil
+}
+
+// delay draws the artificial processing delay from the configured distribution.
+func (s *processingSimulator) delay() time.Duration {
+	base, jitter := float64(s.cfg.Delay), float64(s.cfg.Jitter)
+	var d float64
+	switch s.cfg.Distribution {
+	case config.DelayUniform:
+		d = base - jitter + rand.Float64()*2*jitter
+	case config.DelayNormal:
+		d = base + rand.NormFloat64()*jitter
+	case config.DelayExponential:
+		d = rand.ExpFloat64() * base
+	default:
+		d = base
+	}
+	return time.Duration(math.Max(d, 0))
+}
+
+// scheduleStall simulates a consumer that stops polling for longer than the session
+// timeout: PauseInterval after a session started it is ended through cancel, and the
+// returned flag reports that it ended this way. The caller closes the reader, which stops
+// its heartbeats and leaves the group, so the partitions are rebalanced to the other
+// members; it waits PauseDuration before joining again.
+func scheduleStall(ctx context.Context, cfg config.ProcessingSimulation, cancel context.CancelFunc) *atomic.Bool {
+	stalled := &atomic.Bool{}
+	if cfg.PauseInterval <= 0 || cfg.PauseDuration <= 0 {
+		return stalled
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-time.After(cfg.PauseInterval):
+			stalled.Store(true)
+			cancel()
+		}
+	}()
+	return stalled
+}
@@ -0,0 +1,36 @@
+package consumer
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestSimulator(cfg config.ProcessingSimulation) (*processingSimulator, *metrics.Metrics) {
+	m := metrics.New(prometheus.NewRegistry())
+	return newProcessingSimulator(cfg, "chaos", m, slog.New(slog.NewTextHandler(io.Discard, nil))), m
+}
+
+func TestSimulatedPanicFailsTheAttempt(t *testing.T) {
+	cfg := config.Default().ConsumerSimulation
+	cfg.PanicRate, cfg.MaxRetries, cfg.RetryBackoff = 1, 2, 0
+	simulator, m := newTestSimulator(cfg)
+
+	handled := false
+	simulator.process(context.Background(), message(0, 7), func() { handled = true })
+	if handled {
+		t.Error("message handled although every attempt panicked")
+	}
+	if got := testutil.ToFloat64(m.ConsumerProcessingRetriesTotal.WithLabelValues("chaos", "0")); got != 2 {
+		t.Errorf("kafka_consumer_processing_retries_total = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.ConsumerProcessingFailuresTotal.WithLabelValues("chaos", "0")); got != 1 {
+		t.Errorf("kafka_consumer_processing_failures_total = %v, want 1", got)
+	}
+}
@@ -0,0 +1,240 @@
+package consumer
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/segmentio/kafka-go"
+)
+
+// offsetTracker remembers fetched offsets per partition and reports the highest offset
+// below which every fetched message has been processed. Only such contiguous ranges
+// are committed, so a crash never skips a message that a slower worker still holds.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[int]*partitionOffsets
+}
+
+type partitionOffsets struct {
+	pending   []int64        // fetched offsets in fetch order, not yet committable
+	done      map[int64]bool // processed offsets still waiting for earlier ones
+	committed int64          // last offset acknowledged as committed, -1 if none
+	last      kafka.Message  // message carrying the committable offset
+	dirty     bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[int]*partitionOffsets)}
+}
+
+// track registers a fetched message before it is dispatched to a worker. Offsets that are
+// already pending (redelivery after a rebalance) are not registered twice.
+func (t *offsetTracker) track(msg kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.partitions[msg.Partition]
+	if !ok {
+		p = &partitionOffsets{done: make(map[int64]bool), committed: -1}
+		t.partitions[msg.Partition] = p
+	}
+	if n := len(p.pending); n > 0 && msg.Offset <= p.pending[n-1] {
+		return
+	}
+	p.pending = append(p.pending, msg.Offset)
+}
+
+// markDone records that msg has been processed and advances the contiguous watermark.
+func (t *offsetTracker) markDone(msg kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.partitions[msg.Partition]
+	if !ok || len(p.pending) == 0 || msg.Offset < p.pending[0] {
+		return
+	}
+	p.done[msg.Offset] = true
+	top, advanced := int64(-1), false
+	for len(p.pending) > 0 && p.done[p.pending[0]] {
+		top = p.pending[0]
+		delete(p.done, top)
+		p.pending = p.pending[1:]
+		advanced = true
+	}
+	if advanced {
+		p.last = msg
+		p.last.Offset = top
+		p.dirty = true
+	}
+}
+
+// committable returns one message per partition whose watermark advanced since the last
+// acknowledged commit.
+func (t *offsetTracker) committable() []kafka.Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	msgs := make([]kafka.Message, 0, len(t.partitions))
+	for _, p := range t.partitions {
+		if p.dirty && p.last.Offset > p.committed {
+			msgs = append(msgs, p.last)
+		}
+	}
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].Partition < msgs[j].Partition })
+	return msgs
+}
+
+// acknowledge marks msgs as committed so they are not returned by committable again.
+func (t *offsetTracker) acknowledge(msgs []kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, m := range msgs {
+		p, ok := t.partitions[m.Partition]
+		if !ok || m.Offset <= p.committed {
+			continue
+		}
+		p.committed = m.Offset
+		if p.last.Offset == m.Offset {
+			p.dirty = false
+		}
+	}
+}
+
+// forget drops the state of partitions revoked in a rebalance. Their remaining offsets
+// are committed by the new owner.
+func (t *offsetTracker) forget(partitions []int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, p := range partitions {
+		delete(t.partitions, p)
+	}
+}
+
+// workerPool processes messages on a fixed number of workers. All messages of a partition
+// go to the same worker (partition modulo worker count), which preserves per-partition order.
+type workerPool struct {
+	topic   string
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+	queues  []chan kafka.Message
+	tracker *offsetTracker
+	process func(ctx context.Context, msg kafka.Message)
+	wg      sync.WaitGroup
+}
+
+func newWorkerPool(topic string, workers, queueSize int, process func(ctx context.Context, msg kafka.Message), m *metrics.Metrics, logger *slog.Logger) *workerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	pool := &workerPool{
+		topic:   topic,
+		metrics: m,
+		logger:  logger,
+		queues:  make([]chan kafka.Message, workers),
+		tracker: newOffsetTracker(),
+		process: process,
+	}
+	for i := range pool.queues {
+		pool.queues[i] = make(chan kafka.Message, queueSize)
+	}
+	return pool
+}
+
+// start launches the workers; they exit once their queue is closed by stop.
+func (p *workerPool) start(ctx context.Context) {
+	for i, queue := range p.queues {
+		p.wg.Add(1)
+		go p.runWorker(ctx, i, queue)
+	}
+}
+
+func (p *workerPool) runWorker(ctx context.Context, id int, queue chan kafka.Message) {
+	defer p.wg.Done()
+	workerStr := strconv.Itoa(id)
+	depth := p.metrics.ConsumerWorkerQueueDepth.WithLabelValues(p.topic, workerStr)
+	busy := p.metrics.ConsumerWorkerBusySeconds.WithLabelValues(p.topic, workerStr)
+	for msg := range queue {
+		depth.Set(float64(len(queue)))
+		start := time.Now()
+		processed := p.safeProcess(ctx, id, msg)
+		busy.Add(time.Since(start).Seconds())
+		if processed {
+			p.tracker.markDone(msg)
+		}
+	}
+	depth.Set(0)
+}
+
+// safeProcess runs the handler and recovers from panics so that one bad message does not
+// take the worker (and the per-partition ordering it owns) down. It reports false after a
+// panic: the message was not handled, so its offset is never marked done and the
+// partition is not committed past it; the message is redelivered after the next rebalance
+// or restart.
+func (p *workerPool) safeProcess(ctx context.Context, id int, msg kafka.Message) (processed bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.logger.Error("Recovered from panic while processing message, offset not committed", "panic", r, "worker", id,
+				"key", string(msg.Key), "partition", msg.Partition, "offset", msg.Offset)
+			p.metrics.ConsumerPanicsRecoveredTotal.WithLabelValues(p.topic, strconv.Itoa(id)).Inc()
+			processed = false
+		}
+	}()
+	p.process(ctx, msg)
+	return true
+}
+
+// dispatch hands msg to the worker owning its partition. Blocks while that worker's
+// queue is full, which applies back-pressure to the fetch loop.
+func (p *workerPool) dispatch(ctx context.Context, msg kafka.Message) bool {
+	p.tracker.track(msg)
+	id := msg.Partition % len(p.queues)
+	queue := p.queues[id]
+	select {
+	case queue <- msg:
+		p.metrics.ConsumerWorkerQueueDepth.WithLabelValues(p.topic, strconv.Itoa(id)).Set(float64(len(queue)))
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// stop closes the queues and waits for the workers to drain them.
+func (p *workerPool) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// commitProcessedOffsets periodically commits the contiguous processed offsets until ctx
+// is cancelled. A final commit after the pool is drained is done by the caller.
+func (c *Consumer) commitProcessedOffsets(ctx context.Context, reader Reader, tracker *offsetTracker) {
+	ticker := time.NewTicker(c.config.ConsumerCommitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.commitOffsets(ctx, reader, tracker)
+		}
+	}
+}
+
+func (c *Consumer) commitOffsets(ctx context.Context, reader Reader, tracker *offsetTracker) {
+	msgs := tracker.committable()
+	if len(msgs) == 0 {
+		return
+	}
+	if err := reader.CommitMessages(ctx, msgs...); err != nil {
+		c.logger.Warn("Failed to commit offsets", "error", err, "partitions", len(msgs))
+		c.metrics.ConsumerErrorsTotal.WithLabelValues(msgs[0].Topic, "commit", c.deps.Experiments.Label()).Inc()
+		return
+	}
+	tracker.acknowledge(msgs)
+	for _, m := range msgs {
+		c.metrics.ConsumerCommittedOffset.WithLabelValues(m.Topic, strconv.Itoa(m.Partition)).Set(float64(m.Offset))
+	}
+}
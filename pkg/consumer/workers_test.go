@@ -0,0 +1,115 @@
+package consumer
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+)
+
+func message(partition int, offset int64) kafka.Message {
+	return kafka.Message{Topic: "chaos", Partition: partition, Offset: offset}
+}
+
+// offsets returns the partition:offset pairs of msgs.
+func offsets(msgs []kafka.Message) [][2]int64 {
+	var pairs [][2]int64
+	for _, m := range msgs {
+		pairs = append(pairs, [2]int64{int64(m.Partition), m.Offset})
+	}
+	return pairs
+}
+
+func TestOffsetTrackerCommitsContiguousRanges(t *testing.T) {
+	tracker := newOffsetTracker()
+	for offset := int64(10); offset < 14; offset++ {
+		tracker.track(message(0, offset))
+	}
+	tracker.track(message(1, 5))
+
+	// A slower worker still holds offset 10: nothing below the gap is committable
+	tracker.markDone(message(0, 11))
+	tracker.markDone(message(0, 12))
+	if got := tracker.committable(); len(got) != 0 {
+		t.Fatalf("committable() = %v, want none while offset 10 is pending", offsets(got))
+	}
+
+	tracker.markDone(message(0, 10))
+	tracker.markDone(message(1, 5))
+	got := tracker.committable()
+	if want := [][2]int64{{0, 12}, {1, 5}}; !slices.Equal(offsets(got), want) {
+		t.Fatalf("committable() = %v, want %v", offsets(got), want)
+	}
+
+	tracker.acknowledge(got)
+	if got := tracker.committable(); len(got) != 0 {
+		t.Errorf("committable() after acknowledge = %v, want none", offsets(got))
+	}
+
+	tracker.markDone(message(0, 13))
+	if got, want := offsets(tracker.committable()), [][2]int64{{0, 13}}; !slices.Equal(got, want) {
+		t.Errorf("committable() = %v, want %v", got, want)
+	}
+}
+
+func TestOffsetTrackerIgnoresRedeliveries(t *testing.T) {
+	tracker := newOffsetTracker()
+	tracker.track(message(0, 1))
+	tracker.track(message(0, 2))
+	// Redelivered after a rebalance: already pending, not registered twice
+	tracker.track(message(0, 1))
+	tracker.markDone(message(0, 1))
+	tracker.markDone(message(0, 2))
+	got := tracker.committable()
+	if want := [][2]int64{{0, 2}}; !slices.Equal(offsets(got), want) {
+		t.Fatalf("committable() = %v, want %v", offsets(got), want)
+	}
+	tracker.acknowledge(got)
+
+	// Processing a redelivered, already committed offset does not move the watermark back
+	tracker.markDone(message(0, 1))
+	if got := tracker.committable(); len(got) != 0 {
+		t.Errorf("committable() = %v, want none", offsets(got))
+	}
+}
+
+func TestOffsetTrackerForgetsRevokedPartitions(t *testing.T) {
+	tracker := newOffsetTracker()
+	tracker.track(message(0, 1))
+	tracker.track(message(1, 1))
+	tracker.markDone(message(0, 1))
+	tracker.markDone(message(1, 1))
+	tracker.forget([]int{1})
+	if got, want := offsets(tracker.committable()), [][2]int64{{0, 1}}; !slices.Equal(got, want) {
+		t.Errorf("committable() = %v, want %v", got, want)
+	}
+}
+
+func TestPanicKeepsTheOffsetUncommitted(t *testing.T) {
+	m := metrics.New(prometheus.NewRegistry())
+	process := func(ctx context.Context, msg kafka.Message) {
+		if msg.Offset == 2 {
+			panic("handler bug")
+		}
+	}
+	pool := newWorkerPool("chaos", 1, 10, process, m, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	pool.start(context.Background())
+	for offset := int64(1); offset <= 3; offset++ {
+		pool.dispatch(context.Background(), message(0, offset))
+	}
+	pool.stop()
+
+	// Offset 3 was processed, but the partition is not committed past the failed offset 2
+	if got, want := offsets(pool.tracker.committable()), [][2]int64{{0, 1}}; !slices.Equal(got, want) {
+		t.Errorf("committable() = %v, want %v", got, want)
+	}
+	if got := testutil.ToFloat64(m.ConsumerPanicsRecoveredTotal.WithLabelValues("chaos", "0")); got != 1 {
+		t.Errorf("kafka_consumer_panics_recovered_total = %v, want 1", got)
+	}
+}
@@ -0,0 +1,330 @@
+// Package control lets an operator change a running producer or consumer without a
+// restart: pause and resume, the producer's message rate and payload profile, and the topic.
+package control
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/httpjson"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+)
+
+// Payload profiles of the producer.
+const (
+	// ProfileTemplate fills the message data from the message template (the default).
+	ProfileTemplate = "template"
+	// ProfileMinimal sends only the message ID as data.
+	ProfileMinimal = "minimal"
+	// ProfileRandom sends SizeBytes of random text as data.
+	ProfileRandom = "random"
+)
+
+// maxPayloadBytes bounds the random payload so that a typo cannot exceed the broker's
+// message.max.bytes (1MB by default).
+const maxPayloadBytes = 900 * 1024
+
+// PayloadProfile selects how the producer builds message data.
+type PayloadProfile struct {
+	Name      string `json:"profile"`
+	SizeBytes int    `json:"size_bytes,omitempty"` // ProfileRandom only
+}
+
+// Validate checks the profile name and that SizeBytes fits it.
+func (p PayloadProfile) Validate() error {
+	switch p.Name {
+	case ProfileTemplate, ProfileMinimal:
+		if p.SizeBytes != 0 {
+			return fmt.Errorf("size_bytes only applies to the %q profile", ProfileRandom)
+		}
+	case ProfileRandom:
+		if p.SizeBytes <= 0 || p.SizeBytes > maxPayloadBytes {
+			return fmt.Errorf("size_bytes must be within [1, %d], got %d", maxPayloadBytes, p.SizeBytes)
+		}
+	default:
+		return fmt.Errorf("unknown profile %q, valid: %s, %s, %s", p.Name, ProfileTemplate, ProfileMinimal, ProfileRandom)
+	}
+	return nil
+}
+
+// State is the runtime-adjustable part of the configuration.
+type State struct {
+	Paused bool
+	// Interval between produced messages (producer only).
+	Interval time.Duration
+	// Payload profile of produced messages (producer only).
+	Payload PayloadProfile
+	Topic   string
+}
+
+// stateJSON is the wire form of State for the HTTP API.
+type stateJSON struct {
+	Mode              string          `json:"mode"`
+	Paused            bool            `json:"paused"`
+	Topic             string          `json:"topic"`
+	IntervalMs        int64           `json:"interval_ms,omitempty"`
+	MessagesPerSecond float64         `json:"messages_per_second,omitempty"`
+	Payload           *PayloadProfile `json:"payload,omitempty"`
+}
+
+// rateJSON is the body of PUT /control/rate; exactly one field is set.
+type rateJSON struct {
+	IntervalMs        int64   `json:"interval_ms,omitempty"`
+	MessagesPerSecond float64 `json:"messages_per_second,omitempty"`
+}
+
+// Controller holds the State shared by the HTTP API and the producer or consumer loop.
+// Loops read State on every iteration and wait on Changed to react to updates promptly.
+type Controller struct {
+	mode    string
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+
+	mu      sync.RWMutex
+	state   State
+	changed chan struct{}
+}
+
+// New creates a Controller for mode (config.ModeProducer or config.ModeConsumer) starting
+// from initial.
+func New(mode string, initial State, m *metrics.Metrics, logger *slog.Logger) *Controller {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if initial.Payload.Name == "" {
+		initial.Payload.Name = ProfileTemplate
+	}
+	c := &Controller{mode: mode, metrics: m, logger: logger, state: initial, changed: make(chan struct{})}
+	c.updateMetrics("", initial)
+	return c
+}
+
+// FromConfig creates a Controller with the state given by cfg.
+func FromConfig(cfg *config.Config, m *metrics.Metrics, logger *slog.Logger) *Controller {
+	return New(cfg.Mode, State{
+		Interval: time.Duration(cfg.ProducerIntervalMs) * time.Millisecond,
+		Topic:    cfg.Topic,
+	}, m, logger)
+}
+
+// State returns the current state.
+func (c *Controller) State() State {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.state
+}
+
+// Changed returns a channel that is closed on the next state change.
+func (c *Controller) Changed() <-chan struct{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.changed
+}
+
+// WaitResumed blocks while paused. It returns ctx.Err() if ctx is cancelled first.
+func (c *Controller) WaitResumed(ctx context.Context) error {
+	for {
+		changed := c.Changed()
+		if !c.State().Paused {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Pause stops production or consumption until Resume.
+func (c *Controller) Pause() {
+	c.update("pause", func(s *State) { s.Paused = true })
+}
+
+// Resume continues after Pause.
+func (c *Controller) Resume() {
+	c.update("resume", func(s *State) { s.Paused = false })
+}
+
+// SetInterval changes the interval between produced messages.
+func (c *Controller) SetInterval(interval time.Duration) error {
+	if err := c.producerOnly("rate"); err != nil {
+		return err
+	}
+	if interval < time.Millisecond {
+		return fmt.Errorf("interval must be at least 1ms, got %s", interval)
+	}
+	c.update("rate", func(s *State) { s.Interval = interval })
+	return nil
+}
+
+// SetPayload changes the payload profile of produced messages.
+func (c *Controller) SetPayload(p PayloadProfile) error {
+	if err := c.producerOnly("payload"); err != nil {
+		return err
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	c.update("payload", func(s *State) { s.Payload = p })
+	return nil
+}
+
+// SetTopic switches the topic produced to or consumed from. The consumer commits the
+// offsets processed so far and rejoins its group on the new topic.
+func (c *Controller) SetTopic(topic string) error {
+	topic = strings.TrimSpace(topic)
+	if topic == "" {
+		return fmt.Errorf("topic must not be empty")
+	}
+	c.update("topic", func(s *State) { s.Topic = topic })
+	return nil
+}
+
+func (c *Controller) producerOnly(action string) error {
+	if c.mode != config.ModeProducer {
+		return fmt.Errorf("%s only applies in %s mode", action, config.ModeProducer)
+	}
+	return nil
+}
+
+// update applies change, wakes up waiters on Changed, logs the new state and records
+// the change in metrics.
+func (c *Controller) update(action string, change func(s *State)) {
+	c.mu.Lock()
+	previous := c.state
+	change(&c.state)
+	state := c.state
+	close(c.changed)
+	c.changed = make(chan struct{})
+	c.mu.Unlock()
+
+	c.logger.Info("Control change applied", "action", action, "paused", state.Paused, "topic", state.Topic,
+		"interval", state.Interval, "payload", state.Payload.Name, "payload_size_bytes", state.Payload.SizeBytes)
+	c.metrics.ControlChangesTotal.WithLabelValues(action).Inc()
+	if previous.Payload != state.Payload {
+		c.metrics.ProducerPayloadProfile.DeleteLabelValues(previous.Payload.Name, strconv.Itoa(previous.Payload.SizeBytes))
+	}
+	c.updateMetrics(previous.Topic, state)
+}
+
+func (c *Controller) updateMetrics(previousTopic string, s State) {
+	if s.Paused {
+		c.metrics.ControlPaused.Set(1)
+	} else {
+		c.metrics.ControlPaused.Set(0)
+	}
+	if previousTopic != "" && previousTopic != s.Topic {
+		c.metrics.ControlActiveTopic.DeleteLabelValues(previousTopic)
+	}
+	c.metrics.ControlActiveTopic.WithLabelValues(s.Topic).Set(1)
+	if c.mode == config.ModeProducer {
+		if s.Interval > 0 {
+			c.metrics.ProducerTargetRate.Set(float64(time.Second) / float64(s.Interval))
+		}
+		c.metrics.ProducerPayloadProfile.WithLabelValues(s.Payload.Name, strconv.Itoa(s.Payload.SizeBytes)).Set(1)
+	}
+}
+
+// ServeHTTP implements the control API:
+//
+//	GET  /control          current state
+//	POST /control/pause    pause producing or consuming
+//	POST /control/resume   resume
+//	PUT  /control/rate     {"interval_ms":50} or {"messages_per_second":20} (producer)
+//	PUT  /control/payload  {"profile":"random","size_bytes":2048} (producer)
+//	PUT  /control/topic    {"topic":"other-topic"}
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/control"), "/")
+	if action == "" {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		httpjson.Write(w, http.StatusOK, c.stateJSON())
+		return
+	}
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var err error
+	switch action {
+	case "pause":
+		c.Pause()
+	case "resume":
+		c.Resume()
+	case "rate":
+		var req rateJSON
+		if err = json.NewDecoder(r.Body).Decode(&req); err == nil {
+			err = c.setRate(req)
+		}
+	case "payload":
+		var req PayloadProfile
+		if err = json.NewDecoder(r.Body).Decode(&req); err == nil {
+			err = c.SetPayload(req)
+		}
+	case "topic":
+		var req struct {
+			Topic string `json:"topic"`
+		}
+		if err = json.NewDecoder(r.Body).Decode(&req); err == nil {
+			err = c.SetTopic(req.Topic)
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	httpjson.Write(w, http.StatusOK, c.stateJSON())
+}
+
+func (c *Controller) setRate(req rateJSON) error {
+	switch {
+	case req.IntervalMs > 0 && req.MessagesPerSecond == 0:
+		return c.SetInterval(time.Duration(req.IntervalMs) * time.Millisecond)
+	case req.MessagesPerSecond > 0 && req.IntervalMs == 0:
+		return c.SetInterval(time.Duration(float64(time.Second) / req.MessagesPerSecond))
+	default:
+		return fmt.Errorf("set exactly one of interval_ms and messages_per_second to a positive value")
+	}
+}
+
+func (c *Controller) stateJSON() stateJSON {
+	s := c.State()
+	j := stateJSON{Mode: c.mode, Paused: s.Paused, Topic: s.Topic}
+	if c.mode == config.ModeProducer {
+		j.IntervalMs = s.Interval.Milliseconds()
+		if s.Interval > 0 {
+			j.MessagesPerSecond = float64(time.Second) / float64(s.Interval)
+		}
+		j.Payload = &s.Payload
+	}
+	return j
+}
+
+// RequireToken protects next with a bearer token (Authorization: Bearer <token>).
+func RequireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="control"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
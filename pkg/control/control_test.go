@@ -0,0 +1,183 @@
+package control
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestController(mode string) *Controller {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(mode, State{Interval: 100 * time.Millisecond, Topic: "test-topic"}, metrics.New(prometheus.NewRegistry()), logger)
+}
+
+// call sends a control API request to h and returns the response.
+func call(h http.Handler, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequireToken(t *testing.T) {
+	h := RequireToken("secret", newTestController(config.ModeProducer))
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{name: "no token", want: http.StatusUnauthorized},
+		{name: "wrong token", authorization: "Bearer other", want: http.StatusUnauthorized},
+		{name: "token without scheme", authorization: "secret", want: http.StatusUnauthorized},
+		{name: "basic auth", authorization: "Basic c2VjcmV0", want: http.StatusUnauthorized},
+		{name: "valid token", authorization: "Bearer secret", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.authorization != "" {
+				header.Set("Authorization", tt.authorization)
+			}
+			rec := call(h, http.MethodGet, "/control", "", header)
+			if rec.Code != tt.want {
+				t.Fatalf("GET /control = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestServeHTTPRejectsInvalidInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{name: "malformed rate", path: "/control/rate", body: `{"interval_ms":`, want: http.StatusBadRequest},
+		{name: "no rate", path: "/control/rate", body: `{}`, want: http.StatusBadRequest},
+		{name: "both rates", path: "/control/rate", body: `{"interval_ms":50,"messages_per_second":20}`, want: http.StatusBadRequest},
+		{name: "negative rate", path: "/control/rate", body: `{"messages_per_second":-1}`, want: http.StatusBadRequest},
+		{name: "rate above 1000/s", path: "/control/rate", body: `{"messages_per_second":5000}`, want: http.StatusBadRequest},
+		{name: "rate in consumer mode", mode: config.ModeConsumer, path: "/control/rate", body: `{"interval_ms":50}`, want: http.StatusBadRequest},
+		{name: "unknown profile", path: "/control/payload", body: `{"profile":"huge"}`, want: http.StatusBadRequest},
+		{name: "random without size", path: "/control/payload", body: `{"profile":"random"}`, want: http.StatusBadRequest},
+		{name: "random too large", path: "/control/payload", body: `{"profile":"random","size_bytes":1048576}`, want: http.StatusBadRequest},
+		{name: "size for template", path: "/control/payload", body: `{"profile":"template","size_bytes":10}`, want: http.StatusBadRequest},
+		{name: "payload in consumer mode", mode: config.ModeConsumer, path: "/control/payload", body: `{"profile":"minimal"}`, want: http.StatusBadRequest},
+		{name: "empty topic", path: "/control/topic", body: `{"topic":"  "}`, want: http.StatusBadRequest},
+		{name: "malformed topic", path: "/control/topic", body: `"other"`, want: http.StatusBadRequest},
+		{name: "unknown action", path: "/control/restart", want: http.StatusNotFound},
+		{name: "GET of an action", method: http.MethodGet, path: "/control/pause", want: http.StatusMethodNotAllowed},
+		{name: "POST of the state", path: "/control", want: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, method := tt.mode, tt.method
+			if mode == "" {
+				mode = config.ModeProducer
+			}
+			if method == "" {
+				method = http.MethodPut
+			}
+			c := newTestController(mode)
+			before := c.State()
+			if rec := call(c, method, tt.path, tt.body, nil); rec.Code != tt.want {
+				t.Errorf("%s %s %s = %d, want %d", method, tt.path, tt.body, rec.Code, tt.want)
+			}
+			if after := c.State(); after != before {
+				t.Errorf("state changed by a rejected request: %+v, want %+v", after, before)
+			}
+		})
+	}
+}
+
+func TestServeHTTPAppliesChanges(t *testing.T) {
+	c := newTestController(config.ModeProducer)
+	m := c.metrics
+	steps := []struct {
+		method, path, body string
+		check              func(t *testing.T, state stateJSON)
+	}{
+		{http.MethodPost, "/control/pause", "", func(t *testing.T, state stateJSON) {
+			if !state.Paused || testutil.ToFloat64(m.ControlPaused) != 1 {
+				t.Errorf("paused = %v, app_paused = %v; want true, 1", state.Paused, testutil.ToFloat64(m.ControlPaused))
+			}
+		}},
+		{http.MethodPost, "/control/resume", "", func(t *testing.T, state stateJSON) {
+			if state.Paused || testutil.ToFloat64(m.ControlPaused) != 0 {
+				t.Errorf("paused = %v, app_paused = %v; want false, 0", state.Paused, testutil.ToFloat64(m.ControlPaused))
+			}
+		}},
+		{http.MethodPut, "/control/rate", `{"messages_per_second":20}`, func(t *testing.T, state stateJSON) {
+			if state.IntervalMs != 50 || testutil.ToFloat64(m.ProducerTargetRate) != 20 {
+				t.Errorf("interval_ms = %d, producer target rate = %v; want 50, 20", state.IntervalMs, testutil.ToFloat64(m.ProducerTargetRate))
+			}
+		}},
+		{http.MethodPut, "/control/rate", `{"interval_ms":200}`, func(t *testing.T, state stateJSON) {
+			if state.MessagesPerSecond != 5 || testutil.ToFloat64(m.ProducerTargetRate) != 5 {
+				t.Errorf("messages_per_second = %v, producer target rate = %v; want 5, 5", state.MessagesPerSecond, testutil.ToFloat64(m.ProducerTargetRate))
+			}
+		}},
+		{http.MethodPut, "/control/payload", `{"profile":"random","size_bytes":2048}`, func(t *testing.T, state stateJSON) {
+			want := PayloadProfile{Name: ProfileRandom, SizeBytes: 2048}
+			if state.Payload == nil || *state.Payload != want {
+				t.Errorf("payload = %+v, want %+v", state.Payload, want)
+			}
+			if got := testutil.ToFloat64(m.ProducerPayloadProfile.WithLabelValues(ProfileRandom, "2048")); got != 1 {
+				t.Errorf("producer payload profile{random,2048} = %v, want 1", got)
+			}
+			if got := testutil.CollectAndCount(m.ProducerPayloadProfile); got != 1 {
+				t.Errorf("producer payload profile series = %d, want only the active profile", got)
+			}
+		}},
+		{http.MethodPut, "/control/topic", `{"topic":" other-topic "}`, func(t *testing.T, state stateJSON) {
+			if state.Topic != "other-topic" {
+				t.Errorf("topic = %q, want %q", state.Topic, "other-topic")
+			}
+			if got := testutil.ToFloat64(m.ControlActiveTopic.WithLabelValues("other-topic")); got != 1 {
+				t.Errorf("app_active_topic{topic=other-topic} = %v, want 1", got)
+			}
+			if got := testutil.CollectAndCount(m.ControlActiveTopic); got != 1 {
+				t.Errorf("app_active_topic series = %d, want only the active topic", got)
+			}
+		}},
+	}
+	for _, step := range steps {
+		changed := c.Changed()
+		rec := call(c, step.method, step.path, step.body, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s = %d %s, want 200", step.method, step.path, rec.Code, rec.Body)
+		}
+		select {
+		case <-changed:
+		default:
+			t.Errorf("%s %s did not wake up the waiters on Changed", step.method, step.path)
+		}
+		var state stateJSON
+		if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+			t.Fatalf("%s %s: decode state: %v", step.method, step.path, err)
+		}
+		step.check(t, state)
+	}
+	if got := testutil.ToFloat64(m.ControlChangesTotal.WithLabelValues("rate")); got != 2 {
+		t.Errorf("app_control_changes_total{action=rate} = %v, want 2", got)
+	}
+}
@@ -0,0 +1,184 @@
+package experiment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Grafana creates a region annotation per experiment through the Grafana HTTP API: it is
+// created at the start and its end time set at the end.
+type Grafana struct {
+	url          string
+	token        string
+	dashboardUID string
+	// tags are added to every annotation, e.g. the mode of the reporting app
+	tags   []string
+	client *http.Client
+
+	mu  sync.Mutex
+	ids map[string]int64 // annotation IDs of active experiments
+}
+
+// NewGrafana creates a Grafana annotator for the Grafana at baseURL. token is a service
+// account token with the annotations:write permission; dashboardUID (optional) limits the
+// annotations to one dashboard.
+func NewGrafana(baseURL, token, dashboardUID string, tags ...string) *Grafana {
+	return &Grafana{
+		url:          strings.TrimSuffix(baseURL, "/"),
+		token:        token,
+		dashboardUID: dashboardUID,
+		tags:         tags,
+		client:       &http.Client{},
+		ids:          make(map[string]int64),
+	}
+}
+
+func (g *Grafana) Name() string { return "grafana" }
+
+func (g *Grafana) Start(ctx context.Context, e Experiment) error {
+	tags := []string{"chaos", e.Name}
+	if e.Kind != "" {
+		tags = append(tags, e.Kind)
+	}
+	body := map[string]interface{}{
+		"time": e.Started.UnixMilli(),
+		"tags": append(tags, g.tags...),
+		"text": annotationText(e),
+	}
+	if g.dashboardUID != "" {
+		body["dashboardUID"] = g.dashboardUID
+	}
+	var resp struct {
+		ID int64 `json:"id"`
+	}
+	if err := g.do(ctx, http.MethodPost, "/api/annotations", body, &resp); err != nil {
+		return err
+	}
+	g.mu.Lock()
+	g.ids[e.Key()] = resp.ID
+	g.mu.Unlock()
+	return nil
+}
+
+func (g *Grafana) End(ctx context.Context, e Experiment, ended time.Time) error {
+	g.mu.Lock()
+	id, ok := g.ids[e.Key()]
+	delete(g.ids, e.Key())
+	g.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no annotation for experiment %s", e.Name)
+	}
+	return g.do(ctx, http.MethodPatch, "/api/annotations/"+strconv.FormatInt(id, 10),
+		map[string]interface{}{"timeEnd": ended.UnixMilli()}, nil)
+}
+
+func (g *Grafana) do(ctx context.Context, method, path string, body, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, g.url+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("grafana %s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if result != nil {
+		return json.NewDecoder(resp.Body).Decode(result)
+	}
+	return nil
+}
+
+// VictoriaMetrics pushes the start and end of experiments as samples of
+// app_chaos_experiment_event through the Prometheus text import API, so that even an
+// experiment shorter than the scrape interval is recorded. Grafana shows them with an
+// annotation query on the series.
+type VictoriaMetrics struct {
+	url    string
+	labels map[string]string
+	client *http.Client
+}
+
+// NewVictoriaMetrics creates an annotator for the VictoriaMetrics at baseURL (for a
+// cluster, the vminsert URL including /insert/<tenant>/prometheus); labels are added to
+// every sample, e.g. the mode of the reporting app.
+func NewVictoriaMetrics(baseURL string, labels map[string]string) *VictoriaMetrics {
+	return &VictoriaMetrics{url: strings.TrimSuffix(baseURL, "/"), labels: labels, client: &http.Client{}}
+}
+
+func (v *VictoriaMetrics) Name() string { return "victoriametrics" }
+
+func (v *VictoriaMetrics) Start(ctx context.Context, e Experiment) error {
+	return v.push(ctx, e, "start", e.Started)
+}
+
+func (v *VictoriaMetrics) End(ctx context.Context, e Experiment, ended time.Time) error {
+	return v.push(ctx, e, "end", ended)
+}
+
+func (v *VictoriaMetrics) push(ctx context.Context, e Experiment, event string, at time.Time) error {
+	labels := []string{
+		label("experiment", e.Name), label("kind", e.Kind), label("namespace", e.Namespace),
+		label("source", e.Source), label("event", event),
+	}
+	names := make([]string, 0, len(v.labels))
+	for name := range v.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		labels = append(labels, label(name, v.labels[name]))
+	}
+	line := fmt.Sprintf("app_chaos_experiment_event{%s} 1 %d\n", strings.Join(labels, ","), at.UnixMilli())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url+"/api/v1/import/prometheus", strings.NewReader(line))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("victoriametrics import: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func label(name, value string) string {
+	return name + "=" + strconv.Quote(value)
+}
+
+func annotationText(e Experiment) string {
+	text := "Chaos experiment " + e.Name
+	if e.Kind != "" {
+		text += " (" + e.Kind + ")"
+	}
+	if e.Namespace != "" {
+		text += " in " + e.Namespace
+	}
+	return text + ", source: " + e.Source
+}
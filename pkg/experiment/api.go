@@ -0,0 +1,104 @@
+package experiment
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/httpjson"
+)
+
+// maxAPIExperiments bounds the experiments active through the API at a time: their names
+// are joined into the experiment label, so each one multiplies its values.
+const maxAPIExperiments = 8
+
+var (
+	// apiKind matches kinds such as PodChaos; apiNamespace matches Kubernetes namespaces.
+	apiKind      = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]{0,62}$`)
+	apiNamespace = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+)
+
+// ServeHTTP implements the experiment part of the control API:
+//
+//	GET    /control/experiment         active experiments
+//	PUT    /control/experiment         {"name":"pod-kill","kind":"PodChaos","namespace":"kafka-cluster"}
+//	DELETE /control/experiment/<name>  end every experiment started with the API under name
+//
+// Only experiments started through the API can be ended through it. Names, kinds and
+// namespaces label metrics and are limited to Kubernetes-like names.
+func (t *Tracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/control/experiment"), "/")
+	switch {
+	case r.Method == http.MethodGet && name == "":
+	case (r.Method == http.MethodPut || r.Method == http.MethodPost) && name == "":
+		var e Experiment
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		e = Experiment{Name: strings.TrimSpace(e.Name), Kind: e.Kind, Namespace: e.Namespace, Source: SourceAPI}
+		if err := validateAPIExperiment(e); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !t.startAPI(e) {
+			http.Error(w, fmt.Sprintf("at most %d experiments can be active through the API", maxAPIExperiments), http.StatusConflict)
+			return
+		}
+	case r.Method == http.MethodDelete && name != "":
+		ended := 0
+		for _, e := range t.Active() {
+			if e.Source == SourceAPI && e.Name == name && t.End(e.Key()) {
+				ended++
+			}
+		}
+		if ended == 0 {
+			http.Error(w, "no experiment "+name+" started through the API", http.StatusNotFound)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	httpjson.Write(w, http.StatusOK, struct {
+		Experiment string       `json:"experiment"`
+		Active     []Experiment `json:"active"`
+	}{t.Label(), t.Active()})
+}
+
+// validateAPIExperiment checks the fields of an experiment started through the API.
+func validateAPIExperiment(e Experiment) error {
+	switch {
+	case e.Name == "":
+		return fmt.Errorf("name must not be empty")
+	case !config.ValidExperimentName(e.Name):
+		return fmt.Errorf("name %q: must be lowercase alphanumerics, '-' or '.', at most 63 characters", e.Name)
+	case e.Kind != "" && !apiKind.MatchString(e.Kind):
+		return fmt.Errorf("kind %q: must be alphanumeric, at most 63 characters", e.Kind)
+	case e.Namespace != "" && !apiNamespace.MatchString(e.Namespace):
+		return fmt.Errorf("namespace %q: must be a Kubernetes namespace name", e.Namespace)
+	}
+	return nil
+}
+
+// startAPI starts e unless maxAPIExperiments are already active through the API; starting
+// an active experiment again is allowed.
+func (t *Tracker) startAPI(e Experiment) bool {
+	count := 0
+	for _, active := range t.Active() {
+		if active.Key() == e.Key() {
+			return true
+		}
+		if active.Source == SourceAPI {
+			count++
+		}
+	}
+	if count >= maxAPIExperiments {
+		return false
+	}
+	t.Start(e)
+	return true
+}
@@ -0,0 +1,96 @@
+package experiment
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newTestTracker(t *testing.T) *Tracker {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewTracker(metrics.New(prometheus.NewRegistry()), logger)
+}
+
+func serve(t *Tracker, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	t.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func TestAPIRejectsInvalidExperiments(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "empty name", body: `{"name":" "}`, want: "name must not be empty"},
+		{name: "uppercase name", body: `{"name":"Pod-Kill"}`, want: `name "Pod-Kill"`},
+		{name: "long name", body: `{"name":"` + strings.Repeat("a", 64) + `"}`, want: "at most 63 characters"},
+		{name: "label syntax", body: `{"name":"kill\",x=\"1"}`, want: "must be lowercase"},
+		{name: "kind", body: `{"name":"pod-kill","kind":"Pod Chaos"}`, want: `kind "Pod Chaos"`},
+		{name: "namespace", body: `{"name":"pod-kill","namespace":"Kafka_Cluster"}`, want: `namespace "Kafka_Cluster"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newTestTracker(t)
+			w := serve(tracker, http.MethodPut, "/control/experiment", tt.body)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("PUT %s = %d %q, want 400 with %q", tt.body, w.Code, w.Body, tt.want)
+			}
+			if active := tracker.Active(); len(active) != 0 {
+				t.Errorf("active after a rejected PUT = %+v", active)
+			}
+		})
+	}
+}
+
+func TestAPILimitsActiveExperiments(t *testing.T) {
+	tracker := newTestTracker(t)
+	for i := range maxAPIExperiments {
+		if w := serve(tracker, http.MethodPut, "/control/experiment", `{"name":"exp-`+strconv.Itoa(i)+`"}`); w.Code != http.StatusOK {
+			t.Fatalf("PUT experiment %d = %d %q", i, w.Code, w.Body)
+		}
+	}
+	if w := serve(tracker, http.MethodPut, "/control/experiment", `{"name":"exp-0"}`); w.Code != http.StatusOK {
+		t.Errorf("PUT of an active experiment = %d %q, want 200", w.Code, w.Body)
+	}
+	if w := serve(tracker, http.MethodPut, "/control/experiment", `{"name":"one-more"}`); w.Code != http.StatusConflict {
+		t.Errorf("PUT over the limit = %d %q, want 409", w.Code, w.Body)
+	}
+	// Experiments of other sources do not count towards the limit
+	tracker.Start(Experiment{Name: "from-env", Source: SourceEnv})
+	serve(tracker, http.MethodDelete, "/control/experiment/exp-0", "")
+	if w := serve(tracker, http.MethodPut, "/control/experiment", `{"name":"one-more"}`); w.Code != http.StatusOK {
+		t.Errorf("PUT after a DELETE = %d %q, want 200", w.Code, w.Body)
+	}
+}
+
+func TestAPIDeleteEndsEveryExperimentWithName(t *testing.T) {
+	tracker := newTestTracker(t)
+	serve(tracker, http.MethodPut, "/control/experiment", `{"name":"net-delay","kind":"NetworkChaos","namespace":"kafka-a"}`)
+	serve(tracker, http.MethodPut, "/control/experiment", `{"name":"net-delay","kind":"NetworkChaos","namespace":"kafka-b"}`)
+	serve(tracker, http.MethodPut, "/control/experiment", `{"name":"pod-kill"}`)
+	tracker.Start(Experiment{Name: "net-delay", Source: SourceChaosMesh, Kind: "NetworkChaos", Namespace: "kafka-c"})
+
+	if w := serve(tracker, http.MethodDelete, "/control/experiment/net-delay", ""); w.Code != http.StatusOK {
+		t.Fatalf("DELETE = %d %q", w.Code, w.Body)
+	}
+	var names []string
+	for _, e := range tracker.Active() {
+		names = append(names, e.Source+":"+e.Name)
+	}
+	if got, want := strings.Join(names, ","), "api:pod-kill,chaos-mesh:net-delay"; got != want {
+		t.Errorf("active after DELETE = %s, want %s", got, want)
+	}
+	if w := serve(tracker, http.MethodDelete, "/control/experiment/net-delay", ""); w.Code != http.StatusNotFound {
+		t.Errorf("second DELETE = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,221 @@
+package experiment
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ChaosMeshResources maps the watched Chaos Mesh kinds (those applied from
+// chaos-experiments/) to their resources. Schedules are not watched: the chaos they
+// create is.
+var ChaosMeshResources = map[string]schema.GroupVersionResource{
+	"PodChaos":     chaosMeshResource("podchaos"),
+	"NetworkChaos": chaosMeshResource("networkchaos"),
+	"IOChaos":      chaosMeshResource("iochaos"),
+	"StressChaos":  chaosMeshResource("stresschaos"),
+	"TimeChaos":    chaosMeshResource("timechaos"),
+	"DNSChaos":     chaosMeshResource("dnschaos"),
+	"HTTPChaos":    chaosMeshResource("httpchaos"),
+	"JVMChaos":     chaosMeshResource("jvmchaos"),
+}
+
+func chaosMeshResource(resource string) schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: "chaos-mesh.org", Version: "v1alpha1", Resource: resource}
+}
+
+// InClusterClient returns a dynamic client authenticated with the pod's service account.
+func InClusterClient() (dynamic.Interface, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes in-cluster config: %w", err)
+	}
+	return dynamic.NewForConfig(cfg)
+}
+
+// ChaosMeshWatcher follows Chaos Mesh resources in a set of namespaces and keeps their
+// experiments active in the Tracker while the chaos is injected: from the injection to
+// the recovery, pause or deletion of the resource.
+type ChaosMeshWatcher struct {
+	client     dynamic.Interface
+	namespaces []string
+	resync     time.Duration
+	tracker    *Tracker
+	metrics    *metrics.Metrics
+	logger     *slog.Logger
+
+	mu       sync.Mutex
+	injected map[string]bool // experiment keys
+}
+
+// NewChaosMeshWatcher creates a watcher of the ChaosMeshResources in namespaces; resync
+// is the period after which every resource is evaluated again.
+func NewChaosMeshWatcher(client dynamic.Interface, namespaces []string, resync time.Duration, t *Tracker, m *metrics.Metrics, logger *slog.Logger) *ChaosMeshWatcher {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &ChaosMeshWatcher{
+		client:     client,
+		namespaces: namespaces,
+		resync:     resync,
+		tracker:    t,
+		metrics:    m,
+		logger:     logger,
+		injected:   make(map[string]bool),
+	}
+}
+
+// Run watches until ctx is done. Missing resources (e.g. a Chaos Mesh release without
+// JVMChaos) or RBAC denials are logged and counted, the other kinds are still watched.
+func (w *ChaosMeshWatcher) Run(ctx context.Context) {
+	var factories []dynamicinformer.DynamicSharedInformerFactory
+	for _, ns := range w.namespaces {
+		factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(w.client, w.resync, ns, nil)
+		for kind, gvr := range ChaosMeshResources {
+			informer := factory.ForResource(gvr).Informer()
+			if err := informer.SetWatchErrorHandler(w.watchErrorHandler(kind, ns)); err != nil {
+				w.logger.Warn("Failed to set Chaos Mesh watch error handler", "kind", kind, "namespace", ns, "error", err)
+			}
+			if _, err := informer.AddEventHandler(w.handler(kind)); err != nil {
+				w.logger.Warn("Failed to watch Chaos Mesh resources", "kind", kind, "namespace", ns, "error", err)
+			}
+		}
+		factory.Start(ctx.Done())
+		factories = append(factories, factory)
+	}
+	w.logger.Info("Watching Chaos Mesh experiments", "namespaces", w.namespaces)
+
+	<-ctx.Done()
+	for _, factory := range factories {
+		factory.Shutdown()
+	}
+}
+
+func (w *ChaosMeshWatcher) watchErrorHandler(kind, namespace string) cache.WatchErrorHandler {
+	return func(_ *cache.Reflector, err error) {
+		w.metrics.ChaosMeshWatchErrorsTotal.WithLabelValues(kind, namespace).Inc()
+		w.logger.Warn("Chaos Mesh watch failed", "kind", kind, "namespace", namespace, "error", err)
+	}
+}
+
+func (w *ChaosMeshWatcher) handler(kind string) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { w.update(kind, obj) },
+		UpdateFunc: func(_, obj interface{}) { w.update(kind, obj) },
+		DeleteFunc: func(obj interface{}) { w.delete(kind, obj) },
+	}
+}
+
+// update starts or ends the experiment of obj when its injection state changed.
+func (w *ChaosMeshWatcher) update(kind string, obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	e := Experiment{Name: u.GetName(), Kind: kind, Namespace: u.GetNamespace(), Source: SourceChaosMesh}
+	injected, started := injectionState(u)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch {
+	case injected && !w.injected[e.Key()]:
+		w.injected[e.Key()] = true
+		e.Started = started
+		w.tracker.Start(e)
+		w.metrics.ChaosMeshEventsTotal.WithLabelValues(kind, e.Namespace, "injected").Inc()
+	case !injected && w.injected[e.Key()]:
+		delete(w.injected, e.Key())
+		w.tracker.End(e.Key())
+		w.metrics.ChaosMeshEventsTotal.WithLabelValues(kind, e.Namespace, "recovered").Inc()
+	}
+}
+
+// delete ends the experiment of a deleted resource that was still injected.
+func (w *ChaosMeshWatcher) delete(kind string, obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	key := Experiment{Name: u.GetName(), Kind: kind, Namespace: u.GetNamespace()}.Key()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.injected[key] {
+		return
+	}
+	delete(w.injected, key)
+	w.tracker.End(key)
+	w.metrics.ChaosMeshEventsTotal.WithLabelValues(kind, u.GetNamespace(), "deleted").Inc()
+}
+
+// injectionState reports whether the chaos of u is injected and, if the records tell,
+// since when. Chaos Mesh keeps the desired phase (Run or Stop, Stop also when paused
+// or the duration has passed) and one record per selected target with its phase and
+// the applied and recovered events in status.experiment.
+func injectionState(u *unstructured.Unstructured) (bool, time.Time) {
+	phase, _, _ := unstructured.NestedString(u.Object, "status", "experiment", "desiredPhase")
+	if phase != "Run" || condition(u, "Paused") {
+		return false, time.Time{}
+	}
+	records, _, _ := unstructured.NestedSlice(u.Object, "status", "experiment", "containerRecords")
+	injected := condition(u, "AllInjected")
+	var started time.Time
+	for _, r := range records {
+		record, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if phase, _ := record["phase"].(string); !strings.HasPrefix(phase, "Injected") {
+			continue
+		}
+		injected = true
+		// the chaos began with the earliest target's latest successful apply
+		if applied := lastApplied(record); !applied.IsZero() && (started.IsZero() || applied.Before(started)) {
+			started = applied
+		}
+	}
+	return injected, started
+}
+
+// condition reports whether the status condition typ of u is True.
+func condition(u *unstructured.Unstructured, typ string) bool {
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if ok && cond["type"] == typ {
+			return cond["status"] == "True"
+		}
+	}
+	return false
+}
+
+// lastApplied returns the time of the latest successful apply event of a record.
+func lastApplied(record map[string]interface{}) time.Time {
+	events, _ := record["events"].([]interface{})
+	var last time.Time
+	for _, ev := range events {
+		event, ok := ev.(map[string]interface{})
+		if !ok || event["operation"] != "Apply" || event["type"] != "Succeeded" {
+			continue
+		}
+		ts, _ := event["timestamp"].(string)
+		if t, err := time.Parse(time.RFC3339, ts); err == nil && t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
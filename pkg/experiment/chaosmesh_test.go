@@ -0,0 +1,164 @@
+package experiment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+const testNamespace = "kafka-cluster"
+
+// podChaos returns a PodChaos with the given desired phase and, when applied is not zero,
+// a target injected at applied.
+func podChaos(name, phase string, applied time.Time) *unstructured.Unstructured {
+	experiment := map[string]interface{}{"desiredPhase": phase}
+	if !applied.IsZero() {
+		experiment["containerRecords"] = []interface{}{map[string]interface{}{
+			"id":    testNamespace + "/kafka-cluster-kafka-0",
+			"phase": "Injected",
+			"events": []interface{}{map[string]interface{}{
+				"operation": "Apply",
+				"type":      "Succeeded",
+				"timestamp": applied.UTC().Format(time.RFC3339),
+			}},
+		}}
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "chaos-mesh.org/v1alpha1",
+		"kind":       "PodChaos",
+		"metadata":   map[string]interface{}{"name": name, "namespace": testNamespace},
+		"status":     map[string]interface{}{"experiment": experiment},
+	}}
+}
+
+func startWatcher(t *testing.T) (dynamic.ResourceInterface, *Tracker, *metrics.Metrics) {
+	t.Helper()
+	listKinds := make(map[schema.GroupVersionResource]string)
+	for kind, gvr := range ChaosMeshResources {
+		listKinds[gvr] = kind + "List"
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds)
+	tracker := newTestTracker(t)
+	m := metrics.New(prometheus.NewRegistry())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewChaosMeshWatcher(client, []string{testNamespace}, time.Minute, tracker, m, tracker.logger).Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return client.Resource(ChaosMeshResources["PodChaos"]).Namespace(testNamespace), tracker, m
+}
+
+// eventually fails the test unless ok becomes true within a few seconds.
+func eventually(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestChaosMeshWatcherFollowsInjection(t *testing.T) {
+	ctx := context.Background()
+	resources, tracker, m := startWatcher(t)
+	var ended []Experiment
+	endedCh := make(chan Experiment, 1)
+	tracker.OnEnd(func(e Experiment, _ time.Time) { endedCh <- e })
+	events := func(event string) float64 {
+		return testutil.ToFloat64(m.ChaosMeshEventsTotal.WithLabelValues("PodChaos", testNamespace, event))
+	}
+
+	// Created but not injected yet
+	if _, err := resources.Create(ctx, podChaos("pod-kill", "Run", time.Time{}), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if active := tracker.Active(); len(active) != 0 {
+		t.Fatalf("experiment active before injection: %+v", active)
+	}
+
+	// Injected: the experiment starts at the apply time of the target
+	applied := time.Now().Add(-time.Minute).Truncate(time.Second)
+	if _, err := resources.Update(ctx, podChaos("pod-kill", "Run", applied), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the injected experiment", func() bool { return len(tracker.Active()) == 1 })
+	e := tracker.Active()[0]
+	if e.Name != "pod-kill" || e.Kind != "PodChaos" || e.Namespace != testNamespace || e.Source != SourceChaosMesh || !e.Started.Equal(applied) {
+		t.Errorf("experiment = %+v, want pod-kill PodChaos started at %s", e, applied)
+	}
+	if tracker.Label() != "pod-kill" || events("injected") != 1 {
+		t.Errorf("label %q, injected events %v; want pod-kill, 1", tracker.Label(), events("injected"))
+	}
+
+	// Recovered: the desired phase is Stop once the duration has passed
+	if _, err := resources.Update(ctx, podChaos("pod-kill", "Stop", applied), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ended = append(ended, <-endedCh)
+	if len(tracker.Active()) != 0 || events("recovered") != 1 {
+		t.Errorf("after recovery: active %+v, recovered events %v", tracker.Active(), events("recovered"))
+	}
+
+	// Deleted while injected
+	if _, err := resources.Update(ctx, podChaos("pod-kill", "Run", applied), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the injected experiment", func() bool { return len(tracker.Active()) == 1 })
+	if err := resources.Delete(ctx, "pod-kill", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ended = append(ended, <-endedCh)
+	if len(tracker.Active()) != 0 || events("deleted") != 1 || tracker.Label() != None {
+		t.Errorf("after deletion: active %+v, deleted events %v, label %q", tracker.Active(), events("deleted"), tracker.Label())
+	}
+	for _, e := range ended {
+		if e.Key() != "PodChaos/"+testNamespace+"/pod-kill" {
+			t.Errorf("ended experiment %q, want PodChaos/%s/pod-kill", e.Key(), testNamespace)
+		}
+	}
+}
+
+func TestInjectionState(t *testing.T) {
+	applied := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	paused := podChaos("paused", "Run", applied)
+	paused.Object["status"].(map[string]interface{})["conditions"] = []interface{}{
+		map[string]interface{}{"type": "Paused", "status": "True"},
+	}
+	tests := []struct {
+		name     string
+		obj      *unstructured.Unstructured
+		injected bool
+		started  time.Time
+	}{
+		{name: "no targets yet", obj: podChaos("a", "Run", time.Time{})},
+		{name: "injected", obj: podChaos("b", "Run", applied), injected: true, started: applied},
+		{name: "stopped", obj: podChaos("c", "Stop", applied)},
+		{name: "paused", obj: paused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			injected, started := injectionState(tt.obj)
+			if injected != tt.injected || !started.Equal(tt.started) {
+				t.Errorf("injectionState = %v, %s; want %v, %s", injected, started, tt.injected, tt.started)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+// Package experiment tracks the chaos experiments active while the producer or consumer
+// runs, so that their effects can be attributed afterwards: the active experiments are
+// exported as an info metric, label the error counters, are added to every log line and
+// can be pushed as Grafana or VictoriaMetrics annotations.
+//
+// Experiments are started and ended by the EXPERIMENT_NAME setting, the control API
+// (/control/experiment) or a watcher of Chaos Mesh resources.
+package experiment
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+)
+
+// None is the experiment label value while no experiment is active.
+const None = "none"
+
+// Sources of experiments.
+const (
+	SourceEnv       = "env"
+	SourceAPI       = "api"
+	SourceChaosMesh = "chaos-mesh"
+)
+
+// Experiment is one active chaos experiment.
+type Experiment struct {
+	Name string `json:"name"`
+	// Kind is the Chaos Mesh kind (PodChaos, NetworkChaos, ...) or any free-form type.
+	Kind      string    `json:"kind,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Source    string    `json:"source"`
+	Started   time.Time `json:"started"`
+}
+
+// Key identifies the experiment among the active ones.
+func (e Experiment) Key() string {
+	return e.Kind + "/" + e.Namespace + "/" + e.Name
+}
+
+// Annotator records the start and end of experiments in an external system.
+type Annotator interface {
+	// Name labels the annotator in metrics and logs.
+	Name() string
+	Start(ctx context.Context, e Experiment) error
+	End(ctx context.Context, e Experiment, ended time.Time) error
+}
+
+// annotateTimeout bounds one annotation request.
+const annotateTimeout = 10 * time.Second
+
+// annotationQueueSize bounds the annotations waiting to be pushed; more are dropped.
+const annotationQueueSize = 64
+
+// Tracker holds the active experiments. A nil *Tracker has none.
+type Tracker struct {
+	metrics    *metrics.Metrics
+	logger     *slog.Logger
+	annotators []Annotator
+	// annotations are pushed in order by one goroutine, so an end follows its start
+	annotations chan func()
+
+	mu     sync.RWMutex
+	active map[string]Experiment
+	label  string
+	onEnd  []func(e Experiment, ended time.Time)
+}
+
+// NewTracker creates a Tracker without active experiments; annotators are notified of
+// every start and end. logger must not be wrapped by LogHandler: the Tracker adds the
+// experiment attribute to its own log lines.
+func NewTracker(m *metrics.Metrics, logger *slog.Logger, annotators ...Annotator) *Tracker {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	t := &Tracker{metrics: m, annotators: annotators, active: make(map[string]Experiment), label: None}
+	t.logger = slog.New(LogHandler(logger.Handler(), t))
+	if len(annotators) > 0 {
+		t.annotations = make(chan func(), annotationQueueSize)
+		go func() {
+			for push := range t.annotations {
+				push()
+			}
+		}()
+	}
+	return t
+}
+
+// Start marks e active. Starting an active experiment again keeps its start time.
+func (t *Tracker) Start(e Experiment) {
+	if e.Started.IsZero() {
+		e.Started = time.Now()
+	}
+	t.mu.Lock()
+	if _, ok := t.active[e.Key()]; ok {
+		t.mu.Unlock()
+		return
+	}
+	t.active[e.Key()] = e
+	t.updateLabel()
+	t.mu.Unlock()
+
+	t.metrics.ChaosExperimentInfo.WithLabelValues(e.Name, e.Kind, e.Namespace, e.Source).Set(1)
+	t.logger.Info("Chaos experiment started", "name", e.Name, "kind", e.Kind, "namespace", e.Namespace, "source", e.Source)
+	t.annotate(func(ctx context.Context, a Annotator) error { return a.Start(ctx, e) })
+}
+
+// End marks the experiment with key inactive; it returns false if it was not active.
+func (t *Tracker) End(key string) bool {
+	ended := time.Now()
+	t.mu.Lock()
+	e, ok := t.active[key]
+	if ok {
+		delete(t.active, key)
+		t.updateLabel()
+	}
+	t.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	t.metrics.ChaosExperimentInfo.DeleteLabelValues(e.Name, e.Kind, e.Namespace, e.Source)
+	t.logger.Info("Chaos experiment ended", "name", e.Name, "kind", e.Kind, "namespace", e.Namespace, "source", e.Source,
+		"duration", ended.Sub(e.Started).Round(time.Second))
+	t.annotate(func(ctx context.Context, a Annotator) error { return a.End(ctx, e, ended) })
+	t.mu.RLock()
+	onEnd := t.onEnd
+	t.mu.RUnlock()
+	for _, f := range onEnd {
+		f(e, ended)
+	}
+	return true
+}
+
+// OnEnd registers f to be called synchronously after every experiment ends.
+func (t *Tracker) OnEnd(f func(e Experiment, ended time.Time)) {
+	t.mu.Lock()
+	t.onEnd = append(t.onEnd, f)
+	t.mu.Unlock()
+}
+
+// Active returns the active experiments ordered by start time.
+func (t *Tracker) Active() []Experiment {
+	if t == nil {
+		return nil
+	}
+	t.mu.RLock()
+	active := make([]Experiment, 0, len(t.active))
+	for _, e := range t.active {
+		active = append(active, e)
+	}
+	t.mu.RUnlock()
+	sort.Slice(active, func(i, j int) bool {
+		if !active[i].Started.Equal(active[j].Started) {
+			return active[i].Started.Before(active[j].Started)
+		}
+		return active[i].Key() < active[j].Key()
+	})
+	return active
+}
+
+// Label returns the names of the active experiments for the experiment metric label and
+// log attribute: sorted and joined with "+", None when there are none.
+func (t *Tracker) Label() string {
+	if t == nil {
+		return None
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.label
+}
+
+// updateLabel recomputes the label; t.mu must be held.
+func (t *Tracker) updateLabel() {
+	names := make(map[string]bool, len(t.active))
+	for _, e := range t.active {
+		names[e.Name] = true
+	}
+	if len(names) == 0 {
+		t.label = None
+		return
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	t.label = strings.Join(sorted, "+")
+}
+
+// annotate queues call for every annotator; failures are logged and counted but do not
+// affect the experiment state.
+func (t *Tracker) annotate(call func(ctx context.Context, a Annotator) error) {
+	for _, a := range t.annotators {
+		push := func() {
+			ctx, cancel := context.WithTimeout(context.Background(), annotateTimeout)
+			defer cancel()
+			if err := call(ctx, a); err != nil {
+				t.metrics.ChaosAnnotationsTotal.WithLabelValues(a.Name(), "failed").Inc()
+				t.logger.Warn("Failed to push experiment annotation", "target", a.Name(), "error", err)
+				return
+			}
+			t.metrics.ChaosAnnotationsTotal.WithLabelValues(a.Name(), "sent").Inc()
+		}
+		select {
+		case t.annotations <- push:
+		default:
+			t.metrics.ChaosAnnotationsTotal.WithLabelValues(a.Name(), "failed").Inc()
+			t.logger.Warn("Experiment annotation queue full, annotation dropped", "target", a.Name())
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package experiment
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogHandler adds the active experiments of t (the experiment label, "none" without) as
+// the top-level "experiment" attribute of every record handled by next, also on loggers
+// with groups.
+func LogHandler(next slog.Handler, t *Tracker) slog.Handler {
+	return &logHandler{next: next, tracker: t}
+}
+
+// logHandler passes attributes to next until a group is opened. The groups and the
+// attributes added within them are kept here and applied to each record in Handle, after
+// the experiment attribute, so that it stays outside the groups.
+type logHandler struct {
+	next    slog.Handler
+	tracker *Tracker
+	groups  []group
+}
+
+// group is a group opened with WithGroup and the attributes added to it.
+type group struct {
+	name  string
+	attrs []slog.Attr
+}
+
+func (h *logHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	var attrs []slog.Attr
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		g := h.groups[i]
+		attrs = []slog.Attr{{Key: g.name, Value: slog.GroupValue(append(g.attrs[:len(g.attrs):len(g.attrs)], attrs...)...)}}
+	}
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	out.AddAttrs(slog.String("experiment", h.tracker.Label()))
+	out.AddAttrs(attrs...)
+	return h.next.Handle(ctx, out)
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	if len(h.groups) == 0 {
+		return &logHandler{next: h.next.WithAttrs(attrs), tracker: h.tracker}
+	}
+	groups := append([]group(nil), h.groups...)
+	last := &groups[len(groups)-1]
+	last.attrs = append(last.attrs[:len(last.attrs):len(last.attrs)], attrs...)
+	return &logHandler{next: h.next, tracker: h.tracker, groups: groups}
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	groups := append(h.groups[:len(h.groups):len(h.groups)], group{name: name})
+	return &logHandler{next: h.next, tracker: h.tracker, groups: groups}
+}
@@ -0,0 +1,62 @@
+package experiment
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestLogHandlerKeepsExperimentAtTopLevel(t *testing.T) {
+	tracker := newTestTracker(t)
+	tracker.Start(Experiment{Name: "pod-kill"})
+	tests := []struct {
+		name string
+		log  func(logger *slog.Logger)
+		want map[string]interface{}
+	}{
+		{
+			name: "no group",
+			log:  func(logger *slog.Logger) { logger.With("a", 1).Info("msg", "b", 2) },
+			want: map[string]interface{}{"experiment": "pod-kill", "a": 1.0, "b": 2.0},
+		},
+		{
+			name: "group",
+			log:  func(logger *slog.Logger) { logger.WithGroup("kafka").Info("msg", "b", 2) },
+			want: map[string]interface{}{"experiment": "pod-kill", "kafka": map[string]interface{}{"b": 2.0}},
+		},
+		{
+			name: "attributes around nested groups",
+			log: func(logger *slog.Logger) {
+				logger.With("a", 1).WithGroup("g").With("b", 2).WithGroup("h").Info("msg", "c", 3)
+			},
+			want: map[string]interface{}{
+				"experiment": "pod-kill",
+				"a":          1.0,
+				"g":          map[string]interface{}{"b": 2.0, "h": map[string]interface{}{"c": 3.0}},
+			},
+		},
+		{
+			name: "empty group",
+			log:  func(logger *slog.Logger) { logger.WithGroup("g").Info("msg") },
+			want: map[string]interface{}{"experiment": "pod-kill"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			tt.log(slog.New(LogHandler(slog.NewJSONHandler(&out, nil), tracker)))
+			var got map[string]interface{}
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("decode %s: %v", out.String(), err)
+			}
+			for _, key := range []string{slog.TimeKey, slog.LevelKey, slog.MessageKey} {
+				delete(got, key)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("record = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Package faults injects client-side faults into Kafka connections, Schema Registry HTTP
+// calls and Redis commands. Every injector has an HTTP control API and can follow a JSON
+// schedule of fault windows given in the environment.
+package faults
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+)
+
+// parseOptionalDuration parses s as a time.Duration; an empty string is zero.
+func parseOptionalDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// scheduled is an entry of a fault schedule in the configuration.
+type scheduled interface {
+	Window() config.FaultWindow
+}
+
+// runSchedule applies every fault of schedule with start at its offset from now; the
+// function start returns removes that fault again after its duration.
+func runSchedule[F scheduled](ctx context.Context, schedule []F, start func(F) (stop func())) {
+	for _, fault := range schedule {
+		w := fault.Window()
+		go scheduleWindow(ctx, w.At, w.Duration, func() func() { return start(fault) })
+	}
+}
+
+// scheduleWindow calls start after at and the stop function it returns after a further
+// duration. A non-positive duration keeps the fault until it is removed through the API.
+func scheduleWindow(ctx context.Context, at, duration time.Duration, start func() (stop func())) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(at):
+	}
+	stop := start()
+	if duration <= 0 {
+		return
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(duration):
+	}
+	stop()
+}
+
+// layers holds the faults applied to one target; the last one is in effect. The fault set
+// through the API is one layer, replaced by the next API call, and each scheduled fault
+// adds its own layer and removes only that one when its window ends, so overlapping
+// faults stay in effect.
+type layers[T any] struct {
+	stack []layer[T]
+	next  uint64
+	// api is the id of the layer set through the API, 0 if none.
+	api uint64
+}
+
+type layer[T any] struct {
+	id    uint64
+	fault T
+}
+
+// push applies fault on top and returns the id that removes it.
+func (l *layers[T]) push(fault T) uint64 {
+	l.next++
+	l.stack = append(l.stack, layer[T]{id: l.next, fault: fault})
+	return l.next
+}
+
+// setAPI replaces the fault set through the API.
+func (l *layers[T]) setAPI(fault T) {
+	l.remove(l.api)
+	l.api = l.push(fault)
+}
+
+// remove removes the layer id and reports whether it was still applied.
+func (l *layers[T]) remove(id uint64) bool {
+	i := slices.IndexFunc(l.stack, func(x layer[T]) bool { return x.id == id })
+	if i < 0 {
+		return false
+	}
+	l.stack = slices.Delete(l.stack, i, i+1)
+	if id == l.api {
+		l.api = 0
+	}
+	return true
+}
+
+// clear removes every layer, scheduled ones included.
+func (l *layers[T]) clear() {
+	l.stack, l.api = nil, 0
+}
+
+// top returns the fault in effect.
+func (l *layers[T]) top() (T, bool) {
+	if len(l.stack) == 0 {
+		var zero T
+		return zero, false
+	}
+	return l.stack[len(l.stack)-1].fault, true
+}
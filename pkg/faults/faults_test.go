@@ -0,0 +1,52 @@
+package faults
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestFaultAPIsAnswerAlike(t *testing.T) {
+	m := metrics.New(prometheus.NewRegistry())
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	apis := []struct {
+		path    string
+		handler http.Handler
+		fault   string
+		active  string
+	}{
+		{"/faults/kafka", NewKafkaInjector(m, logger), `{"broker":"*","latency":"10ms"}`, `"latency":"10ms"`},
+		{"/faults/redis", NewRedisHook(m, logger), `{"delay":"10ms"}`, `"active":true`},
+		{"/faults/schema-registry", NewHTTPInjector(m, logger), `{"error_rate":1}`, `"active":true`},
+	}
+	for _, api := range apis {
+		t.Run(api.path, func(t *testing.T) {
+			serve := func(method, body string) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				api.handler.ServeHTTP(w, httptest.NewRequest(method, api.path, strings.NewReader(body)))
+				return w
+			}
+			if w := serve(http.MethodPut, `{"delay":`); w.Code != http.StatusBadRequest {
+				t.Errorf("PUT invalid JSON = %d, want 400", w.Code)
+			}
+			if w := serve(http.MethodPut, api.fault); w.Code != http.StatusNoContent {
+				t.Errorf("PUT = %d, want 204", w.Code)
+			}
+			if w := serve(http.MethodGet, ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), api.active) {
+				t.Errorf("GET = %d %s, want 200 with %s", w.Code, w.Body, api.active)
+			}
+			if w := serve(http.MethodDelete, ""); w.Code != http.StatusNoContent {
+				t.Errorf("DELETE = %d, want 204", w.Code)
+			}
+			if w := serve(http.MethodGet, ""); strings.Contains(w.Body.String(), api.active) {
+				t.Errorf("GET after DELETE = %s, want no fault", w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/httpjson"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+)
+
+// HTTPFault describes faults injected into Schema Registry HTTP calls; the local
+// counterpart of chaos-experiments/http-chaos.yaml.
+type HTTPFault struct {
+	Delay time.Duration
+	// ErrorRate is the probability (0..1) to answer with ErrorStatus without calling the registry.
+	ErrorRate   float64
+	ErrorStatus int
+	// DropRate is the probability (0..1) to fail the request as a dropped connection.
+	DropRate float64
+}
+
+func newHTTPFault(f config.SchemaRegistryFault) HTTPFault {
+	status := f.ErrorStatus
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+	return HTTPFault{Delay: f.Delay, ErrorRate: f.ErrorRate, ErrorStatus: status, DropRate: f.DropRate}
+}
+
+// httpFaultJSON is the wire form of HTTPFault for the HTTP API.
+type httpFaultJSON struct {
+	Delay       string  `json:"delay,omitempty"`
+	ErrorRate   float64 `json:"error_rate,omitempty"`
+	ErrorStatus int     `json:"error_status,omitempty"`
+	DropRate    float64 `json:"drop_rate,omitempty"`
+}
+
+func (j httpFaultJSON) fault() (HTTPFault, error) {
+	f := config.SchemaRegistryFault{ErrorRate: j.ErrorRate, ErrorStatus: j.ErrorStatus, DropRate: j.DropRate}
+	var err error
+	if f.Delay, err = parseOptionalDuration(j.Delay); err != nil {
+		return HTTPFault{}, fmt.Errorf("delay: %w", err)
+	}
+	if err := f.Validate(); err != nil {
+		return HTTPFault{}, err
+	}
+	return newHTTPFault(f), nil
+}
+
+var errInjectedConnectionDrop = errors.New("injected fault: connection dropped")
+
+// HTTPInjector holds the active HTTPFault; Wrap installs it in front of a transport.
+type HTTPInjector struct {
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+
+	mu     sync.RWMutex
+	faults layers[HTTPFault]
+}
+
+// NewHTTPInjector creates a Schema Registry fault injector without an active fault.
+func NewHTTPInjector(m *metrics.Metrics, logger *slog.Logger) *HTTPInjector {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &HTTPInjector{metrics: m, logger: logger}
+}
+
+// Set activates fault for all subsequent Schema Registry calls, replacing the fault set
+// before; a scheduled fault applied later takes precedence until its window ends.
+func (f *HTTPInjector) Set(fault HTTPFault) {
+	f.mu.Lock()
+	f.faults.setAPI(fault)
+	f.mu.Unlock()
+	f.injected(fault)
+}
+
+// Clear removes every fault, scheduled ones included.
+func (f *HTTPInjector) Clear() {
+	f.mu.Lock()
+	f.faults.clear()
+	f.mu.Unlock()
+	f.metrics.SchemaRegistryFaultActive.Set(0)
+	f.logger.Info("Schema Registry fault removed")
+}
+
+// apply activates a scheduled fault; the returned function removes only that fault.
+func (f *HTTPInjector) apply(fault HTTPFault) (stop func()) {
+	f.mu.Lock()
+	id := f.faults.push(fault)
+	f.mu.Unlock()
+	f.injected(fault)
+	return func() {
+		f.mu.Lock()
+		removed := f.faults.remove(id)
+		_, active := f.faults.top()
+		f.mu.Unlock()
+		if !removed {
+			return
+		}
+		if !active {
+			f.metrics.SchemaRegistryFaultActive.Set(0)
+		}
+		f.logger.Info("Scheduled Schema Registry fault removed", "faults_left", active)
+	}
+}
+
+func (f *HTTPInjector) injected(fault HTTPFault) {
+	f.metrics.SchemaRegistryFaultActive.Set(1)
+	f.logger.Warn("Schema Registry fault injected", "delay", fault.Delay.String(), "error_rate", fault.ErrorRate,
+		"error_status", fault.ErrorStatus, "drop_rate", fault.DropRate)
+}
+
+// current returns the fault in effect.
+func (f *HTTPInjector) current() (HTTPFault, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.faults.top()
+}
+
+// Wrap returns a transport that applies the active fault before delegating to next. A nil
+// injector returns next unchanged.
+func (f *HTTPInjector) Wrap(next http.RoundTripper) http.RoundTripper {
+	if f == nil {
+		return next
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return f.roundTrip(next, req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return fn(req) }
+
+func (f *HTTPInjector) roundTrip(next http.RoundTripper, req *http.Request) (*http.Response, error) {
+	fault, active := f.current()
+	if !active {
+		return next.RoundTrip(req)
+	}
+	if fault.Delay > 0 {
+		f.metrics.SchemaRegistryFaultsInjectedTotal.WithLabelValues("delay").Inc()
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(fault.Delay):
+		}
+	}
+	if fault.DropRate > 0 && rand.Float64() < fault.DropRate {
+		f.metrics.SchemaRegistryFaultsInjectedTotal.WithLabelValues("drop").Inc()
+		return nil, errInjectedConnectionDrop
+	}
+	if fault.ErrorRate > 0 && rand.Float64() < fault.ErrorRate {
+		f.metrics.SchemaRegistryFaultsInjectedTotal.WithLabelValues("status_" + strconv.Itoa(fault.ErrorStatus)).Inc()
+		body := fmt.Sprintf(`{"error_code":%d,"message":"injected fault"}`, fault.ErrorStatus)
+		return &http.Response{
+			StatusCode: fault.ErrorStatus,
+			Status:     fmt.Sprintf("%d %s", fault.ErrorStatus, http.StatusText(fault.ErrorStatus)),
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+		}, nil
+	}
+	return next.RoundTrip(req)
+}
+
+// ServeHTTP implements the fault control API at /faults/schema-registry:
+// GET shows the fault in effect, PUT sets one (httpFaultJSON body), DELETE removes every
+// fault; PUT and DELETE answer 204.
+func (f *HTTPInjector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		fault, active := f.current()
+		httpjson.Write(w, http.StatusOK, map[string]interface{}{
+			"active": active, "delay": fault.Delay.String(), "error_rate": fault.ErrorRate,
+			"error_status": fault.ErrorStatus, "drop_rate": fault.DropRate,
+		})
+	case http.MethodPut, http.MethodPost:
+		var req httpFaultJSON
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fault, err := req.fault()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Set(fault)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		f.Clear()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// SetupSchemaRegistry creates the Schema Registry fault injector when cfg enables it,
+// registers its HTTP API on mux and starts the schedule of cfg. It returns nil when
+// disabled.
+func SetupSchemaRegistry(ctx context.Context, cfg config.SchemaRegistryFaults, mux *http.ServeMux, m *metrics.Metrics, logger *slog.Logger) *HTTPInjector {
+	if !cfg.Enabled {
+		return nil
+	}
+	f := NewHTTPInjector(m, logger)
+	mux.Handle("/faults/schema-registry", f)
+	f.logger.Warn("Schema Registry fault injection enabled")
+	runSchedule(ctx, cfg.Schedule, func(fault config.SchemaRegistryFault) func() { return f.apply(newHTTPFault(fault)) })
+	return f
+}
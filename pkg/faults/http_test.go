@@ -0,0 +1,93 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestRegistry starts a registry stand-in answering 200 and counting its requests, and
+// returns a client whose transport is wrapped by a new injector.
+func newTestRegistry(t *testing.T) (*HTTPInjector, *http.Client, string, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		io.WriteString(w, `{"compatibilityLevel":"BACKWARD"}`)
+	}))
+	t.Cleanup(srv.Close)
+	f := NewHTTPInjector(metrics.New(prometheus.NewRegistry()), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return f, &http.Client{Transport: f.Wrap(http.DefaultTransport)}, srv.URL + "/config", &requests
+}
+
+func TestHTTPInjectorFaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		fault    config.SchemaRegistryFault
+		status   int
+		err      error
+		reached  bool
+		injected string
+	}{
+		{"no fault", config.SchemaRegistryFault{}, http.StatusOK, nil, true, ""},
+		{"delay", config.SchemaRegistryFault{Delay: 50 * time.Millisecond}, http.StatusOK, nil, true, "delay"},
+		{"server error", config.SchemaRegistryFault{ErrorRate: 1}, http.StatusServiceUnavailable, nil, false, "status_503"},
+		{"client error", config.SchemaRegistryFault{ErrorRate: 1, ErrorStatus: 404}, http.StatusNotFound, nil, false, "status_404"},
+		{"dropped connection", config.SchemaRegistryFault{DropRate: 1}, 0, errInjectedConnectionDrop, false, "drop"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, client, url, requests := newTestRegistry(t)
+			if tt.fault != (config.SchemaRegistryFault{}) {
+				f.Set(newHTTPFault(tt.fault))
+			}
+			start := time.Now()
+			resp, err := client.Get(url)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("GET error = %v, want %v", err, tt.err)
+			}
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode != tt.status {
+					t.Errorf("GET = %d, want %d", resp.StatusCode, tt.status)
+				}
+			}
+			if elapsed := time.Since(start); elapsed < tt.fault.Delay {
+				t.Errorf("GET took %v, want at least the delay %v", elapsed, tt.fault.Delay)
+			}
+			if reached := requests.Load() > 0; reached != tt.reached {
+				t.Errorf("request reached the registry = %v, want %v", reached, tt.reached)
+			}
+			if tt.injected != "" {
+				if got := testutil.ToFloat64(f.metrics.SchemaRegistryFaultsInjectedTotal.WithLabelValues(tt.injected)); got != 1 {
+					t.Errorf("schema_registry_faults_injected_total{fault=%s} = %v, want 1", tt.injected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPInjectorDelayFollowsTheRequestContext(t *testing.T) {
+	f, client, url, requests := newTestRegistry(t)
+	f.Set(HTTPFault{Delay: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GET during delay = %v, want deadline exceeded", err)
+	}
+	if requests.Load() != 0 {
+		t.Error("delayed request reached the registry after its deadline")
+	}
+}
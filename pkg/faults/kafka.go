@@ -0,0 +1,438 @@
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/httpjson"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+)
+
+// anyBroker matches every broker address in fault rules.
+const anyBroker = "*"
+
+// blackholePollInterval is how often stalled operations check whether the blackhole was
+// removed.
+const blackholePollInterval = 50 * time.Millisecond
+
+// BrokerFault is the set of network faults applied to connections to one broker. It is the
+// in-process counterpart of chaos-experiments/network-delay.yaml and network-partition.yaml.
+type BrokerFault struct {
+	// Latency (plus up to Jitter) is added before every write to the broker.
+	Latency time.Duration
+	Jitter  time.Duration
+	// BandwidthBytesPerSec limits throughput in both directions; 0 = unlimited.
+	BandwidthBytesPerSec int
+	// ResetRate is the probability (0..1) that a write resets the connection.
+	ResetRate float64
+	// Blackhole stalls reads, writes and new dials until the fault is removed or their
+	// deadline passes, as if the broker stopped answering.
+	Blackhole bool
+}
+
+func newBrokerFault(f config.KafkaFault) BrokerFault {
+	return BrokerFault{
+		Latency:              f.Latency,
+		Jitter:               f.Jitter,
+		BandwidthBytesPerSec: f.BandwidthBytesPerSec,
+		ResetRate:            f.ResetRate,
+		Blackhole:            f.Blackhole,
+	}
+}
+
+// brokerFaultJSON is the wire form of BrokerFault used by the HTTP API.
+type brokerFaultJSON struct {
+	Broker               string  `json:"broker"`
+	Latency              string  `json:"latency,omitempty"`
+	Jitter               string  `json:"jitter,omitempty"`
+	BandwidthBytesPerSec int     `json:"bandwidth_bytes_per_sec,omitempty"`
+	ResetRate            float64 `json:"reset_rate,omitempty"`
+	Blackhole            bool    `json:"blackhole,omitempty"`
+}
+
+func (j brokerFaultJSON) fault() (BrokerFault, error) {
+	f := config.KafkaFault{
+		BandwidthBytesPerSec: j.BandwidthBytesPerSec,
+		ResetRate:            j.ResetRate,
+		Blackhole:            j.Blackhole,
+	}
+	var err error
+	if f.Latency, err = parseOptionalDuration(j.Latency); err != nil {
+		return BrokerFault{}, fmt.Errorf("latency: %w", err)
+	}
+	if f.Jitter, err = parseOptionalDuration(j.Jitter); err != nil {
+		return BrokerFault{}, fmt.Errorf("jitter: %w", err)
+	}
+	if err := f.Validate(); err != nil {
+		return BrokerFault{}, err
+	}
+	return newBrokerFault(f), nil
+}
+
+func toBrokerFaultJSON(broker string, f BrokerFault) brokerFaultJSON {
+	j := brokerFaultJSON{
+		Broker:               broker,
+		BandwidthBytesPerSec: f.BandwidthBytesPerSec,
+		ResetRate:            f.ResetRate,
+		Blackhole:            f.Blackhole,
+	}
+	if f.Latency > 0 {
+		j.Latency = f.Latency.String()
+	}
+	if f.Jitter > 0 {
+		j.Jitter = f.Jitter.String()
+	}
+	return j
+}
+
+// KafkaInjector wraps connections dialed by kafka-go and applies the configured per-broker
+// faults. It is installed through kafka.Dialer.DialFunc and kafka.Transport.Dial.
+type KafkaInjector struct {
+	dialer  *net.Dialer
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+
+	mu     sync.RWMutex
+	faults map[string]*layers[BrokerFault]
+	conns  map[*faultConn]struct{}
+}
+
+// NewKafkaInjector creates a Kafka fault injector without active faults.
+func NewKafkaInjector(m *metrics.Metrics, logger *slog.Logger) *KafkaInjector {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &KafkaInjector{
+		dialer:  &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second},
+		metrics: m,
+		logger:  logger,
+		faults:  make(map[string]*layers[BrokerFault]),
+		conns:   make(map[*faultConn]struct{}),
+	}
+}
+
+// DialFunc returns the dial function to install in kafka-go, or nil when f is nil so that
+// kafka-go keeps its default dialer.
+func (f *KafkaInjector) DialFunc() func(ctx context.Context, network, address string) (net.Conn, error) {
+	if f == nil {
+		return nil
+	}
+	return f.dial
+}
+
+func (f *KafkaInjector) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	if f.lookup(address).Blackhole {
+		f.metrics.KafkaFaultsInjectedTotal.WithLabelValues(address, "blackhole_dial").Inc()
+		for f.lookup(address).Blackhole {
+			select {
+			case <-ctx.Done():
+				return nil, &net.OpError{Op: "dial", Net: network, Err: ctx.Err()}
+			case <-time.After(blackholePollInterval):
+			}
+		}
+	}
+	conn, err := f.dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	fc := &faultConn{Conn: conn, injector: f, broker: address}
+	f.mu.Lock()
+	f.conns[fc] = struct{}{}
+	f.mu.Unlock()
+	return fc, nil
+}
+
+// lookup returns the fault in effect for address, falling back to the wildcard rule.
+func (f *KafkaInjector) lookup(address string) BrokerFault {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if l, ok := f.faults[address]; ok {
+		if fault, ok := l.top(); ok {
+			return fault
+		}
+	}
+	if l, ok := f.faults[anyBroker]; ok {
+		fault, _ := l.top()
+		return fault
+	}
+	return BrokerFault{}
+}
+
+// layersOf returns the faults of broker, creating them; f.mu must be held.
+func (f *KafkaInjector) layersOf(broker string) *layers[BrokerFault] {
+	l, ok := f.faults[broker]
+	if !ok {
+		l = &layers[BrokerFault]{}
+		f.faults[broker] = l
+	}
+	return l
+}
+
+// Set installs fault for broker (an address or "*"), replacing the fault set before; a
+// scheduled fault applied later takes precedence until its window ends.
+func (f *KafkaInjector) Set(broker string, fault BrokerFault) {
+	f.mu.Lock()
+	f.layersOf(broker).setAPI(fault)
+	f.mu.Unlock()
+	f.injected(broker, fault)
+}
+
+// Clear removes every fault of broker, scheduled ones included.
+func (f *KafkaInjector) Clear(broker string) {
+	f.mu.Lock()
+	_, ok := f.faults[broker]
+	delete(f.faults, broker)
+	f.mu.Unlock()
+	if ok {
+		f.metrics.KafkaFaultActive.DeleteLabelValues(broker)
+		f.logger.Info("Kafka fault removed", "broker", broker)
+	}
+}
+
+// apply installs a scheduled fault for broker; the returned function removes only that
+// fault, so faults set through the API or by overlapping schedule entries stay.
+func (f *KafkaInjector) apply(broker string, fault BrokerFault) (stop func()) {
+	f.mu.Lock()
+	l := f.layersOf(broker)
+	id := l.push(fault)
+	f.mu.Unlock()
+	f.injected(broker, fault)
+	return func() {
+		f.mu.Lock()
+		removed := f.faults[broker] == l && l.remove(id)
+		_, active := l.top()
+		if removed && !active {
+			delete(f.faults, broker)
+		}
+		f.mu.Unlock()
+		if !removed {
+			return
+		}
+		if !active {
+			f.metrics.KafkaFaultActive.DeleteLabelValues(broker)
+		}
+		f.logger.Info("Scheduled Kafka fault removed", "broker", broker, "faults_left", active)
+	}
+}
+
+func (f *KafkaInjector) injected(broker string, fault BrokerFault) {
+	f.metrics.KafkaFaultActive.WithLabelValues(broker).Set(1)
+	f.logger.Warn("Kafka fault injected", "broker", broker, "fault", toBrokerFaultJSON(broker, fault))
+}
+
+// ResetConnections closes all open connections to broker ("*" = all brokers), the
+// equivalent of a TCP reset caused by a pod kill.
+func (f *KafkaInjector) ResetConnections(broker string) int {
+	f.mu.RLock()
+	var victims []*faultConn
+	for c := range f.conns {
+		if broker == anyBroker || c.broker == broker {
+			victims = append(victims, c)
+		}
+	}
+	f.mu.RUnlock()
+	for _, c := range victims {
+		c.Close()
+		f.metrics.KafkaFaultsInjectedTotal.WithLabelValues(c.broker, "reset").Inc()
+	}
+	f.logger.Warn("Kafka connections reset", "broker", broker, "connections", len(victims))
+	return len(victims)
+}
+
+func (f *KafkaInjector) snapshot() []brokerFaultJSON {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	out := make([]brokerFaultJSON, 0, len(f.faults))
+	for broker, l := range f.faults {
+		if fault, ok := l.top(); ok {
+			out = append(out, toBrokerFaultJSON(broker, fault))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Broker < out[j].Broker })
+	return out
+}
+
+// brokerOrAny returns broker, or the wildcard for an empty broker.
+func brokerOrAny(broker string) string {
+	if broker == "" {
+		return anyBroker
+	}
+	return broker
+}
+
+// ServeHTTP implements the fault control API:
+//
+//	GET    /faults/kafka                   list the faults in effect
+//	PUT    /faults/kafka                   set a fault (brokerFaultJSON body), 204
+//	DELETE /faults/kafka?broker=<addr|*>   remove every fault of the broker, 204
+//	POST   /faults/kafka/reset?broker=...  reset open connections
+func (f *KafkaInjector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	broker := brokerOrAny(r.URL.Query().Get("broker"))
+	switch {
+	case r.URL.Path == "/faults/kafka/reset" && r.Method == http.MethodPost:
+		httpjson.Write(w, http.StatusOK, map[string]int{"reset_connections": f.ResetConnections(broker)})
+	case r.URL.Path != "/faults/kafka":
+		http.NotFound(w, r)
+	case r.Method == http.MethodGet:
+		httpjson.Write(w, http.StatusOK, f.snapshot())
+	case r.Method == http.MethodPut || r.Method == http.MethodPost:
+		var req brokerFaultJSON
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fault, err := req.fault()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Set(brokerOrAny(req.Broker), fault)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		f.Clear(broker)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// SetupKafka creates the Kafka fault injector when cfg enables it, registers its HTTP API
+// on mux and starts the schedule of cfg. It returns nil when disabled.
+func SetupKafka(ctx context.Context, cfg config.KafkaFaults, mux *http.ServeMux, m *metrics.Metrics, logger *slog.Logger) *KafkaInjector {
+	if !cfg.Enabled {
+		return nil
+	}
+	f := NewKafkaInjector(m, logger)
+	mux.Handle("/faults/kafka", f)
+	mux.Handle("/faults/kafka/reset", f)
+	f.logger.Warn("Kafka fault injection enabled")
+	runSchedule(ctx, cfg.Schedule, func(fault config.KafkaFault) func() {
+		return f.apply(brokerOrAny(fault.Broker), newBrokerFault(fault))
+	})
+	return f
+}
+
+// faultConn applies the current fault of its broker to every read and write.
+type faultConn struct {
+	net.Conn
+	injector *KafkaInjector
+	broker   string
+
+	mu            sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+	closed        bool
+}
+
+func (c *faultConn) Read(b []byte) (int, error) {
+	fault := c.injector.lookup(c.broker)
+	if fault.Blackhole {
+		c.injector.metrics.KafkaFaultsInjectedTotal.WithLabelValues(c.broker, "blackhole_read").Inc()
+		if err := c.waitBlackhole(false); err != nil {
+			return 0, err
+		}
+	}
+	n, err := c.Conn.Read(b)
+	c.throttle(n, c.injector.lookup(c.broker))
+	return n, err
+}
+
+func (c *faultConn) Write(b []byte) (int, error) {
+	fault := c.injector.lookup(c.broker)
+	if fault.Blackhole {
+		// Nothing is written while the fault lasts, so the stream stays intact
+		c.injector.metrics.KafkaFaultsInjectedTotal.WithLabelValues(c.broker, "blackhole_write").Inc()
+		if err := c.waitBlackhole(true); err != nil {
+			return 0, err
+		}
+		fault = c.injector.lookup(c.broker)
+	}
+	if fault.ResetRate > 0 && rand.Float64() < fault.ResetRate {
+		c.injector.metrics.KafkaFaultsInjectedTotal.WithLabelValues(c.broker, "reset").Inc()
+		c.Close()
+		return 0, &net.OpError{Op: "write", Net: "tcp", Err: syscall.ECONNRESET}
+	}
+	if delay := fault.Latency; delay > 0 || fault.Jitter > 0 {
+		if fault.Jitter > 0 {
+			delay += time.Duration(rand.Int64N(int64(fault.Jitter)))
+		}
+		c.injector.metrics.KafkaFaultsInjectedTotal.WithLabelValues(c.broker, "latency").Inc()
+		time.Sleep(delay)
+	}
+	n, err := c.Conn.Write(b)
+	c.throttle(n, fault)
+	return n, err
+}
+
+// throttle sleeps long enough for n bytes to fit into the bandwidth limit.
+func (c *faultConn) throttle(n int, fault BrokerFault) {
+	if fault.BandwidthBytesPerSec <= 0 || n <= 0 {
+		return
+	}
+	time.Sleep(time.Duration(float64(n) / float64(fault.BandwidthBytesPerSec) * float64(time.Second)))
+}
+
+// waitBlackhole blocks until the blackhole is lifted, the read (or write) deadline passes
+// or the connection is closed.
+func (c *faultConn) waitBlackhole(write bool) error {
+	for {
+		c.mu.Lock()
+		closed, deadline := c.closed, c.readDeadline
+		if write {
+			deadline = c.writeDeadline
+		}
+		c.mu.Unlock()
+		if closed {
+			return net.ErrClosed
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return os.ErrDeadlineExceeded
+		}
+		if !c.injector.lookup(c.broker).Blackhole {
+			return nil
+		}
+		time.Sleep(blackholePollInterval)
+	}
+}
+
+func (c *faultConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline, c.writeDeadline = t, t
+	c.mu.Unlock()
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *faultConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *faultConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.writeDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetWriteDeadline(t)
+}
+
+func (c *faultConn) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.injector.mu.Lock()
+	delete(c.injector.conns, c)
+	c.injector.mu.Unlock()
+	return c.Conn.Close()
+}
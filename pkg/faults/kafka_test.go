@@ -0,0 +1,166 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestInjector(t *testing.T) (*KafkaInjector, net.Listener) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewKafkaInjector(metrics.New(prometheus.NewRegistry()), logger), ln
+}
+
+func TestBlackholeWriteKeepsStreamIntact(t *testing.T) {
+	f, ln := newTestInjector(t)
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	conn, err := f.dial(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Set(anyBroker, BrokerFault{Blackhole: true})
+	conn.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, err := conn.Write([]byte("dropped")); n != 0 || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Write during blackhole = %d, %v; want 0, deadline exceeded", n, err)
+	}
+
+	conn.SetWriteDeadline(time.Time{})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		f.Clear(anyBroker)
+	}()
+	if _, err := conn.Write([]byte("frame")); err != nil {
+		t.Fatalf("Write after blackhole: %v", err)
+	}
+	conn.Close()
+	if data := <-received; string(data) != "frame" {
+		t.Errorf("broker received %q, want %q", data, "frame")
+	}
+}
+
+func TestBlackholeDialResumesWhenLifted(t *testing.T) {
+	f, ln := newTestInjector(t)
+	f.Set(anyBroker, BrokerFault{Blackhole: true})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		f.Clear(anyBroker)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := f.dial(ctx, "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial after blackhole: %v", err)
+	}
+	conn.Close()
+
+	f.Set(anyBroker, BrokerFault{Blackhole: true})
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := f.dial(ctx, "tcp", ln.Addr().String()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("dial during blackhole = %v, want context deadline exceeded", err)
+	}
+}
+
+func TestScheduleAppliesAndRemovesFaults(t *testing.T) {
+	f, _ := newTestInjector(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runSchedule(ctx, []config.KafkaFault{
+		{FaultWindow: config.FaultWindow{Duration: 100 * time.Millisecond}, Latency: time.Second},
+		{FaultWindow: config.FaultWindow{At: 50 * time.Millisecond}, Broker: "broker-0:9092", Blackhole: true},
+	}, func(fault config.KafkaFault) func() { return f.apply(brokerOrAny(fault.Broker), newBrokerFault(fault)) })
+
+	time.Sleep(75 * time.Millisecond)
+	if got := f.snapshot(); len(got) != 2 || got[0].Broker != anyBroker || !got[1].Blackhole {
+		t.Fatalf("faults during both windows = %+v", got)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := f.snapshot(); len(got) != 1 || got[0].Broker != "broker-0:9092" {
+		t.Errorf("faults after the first window = %+v, want only the open-ended blackhole", got)
+	}
+}
+
+func TestScheduledStopKeepsOtherFaults(t *testing.T) {
+	f, _ := newTestInjector(t)
+	const broker = "broker-0:9092"
+	f.Set(broker, BrokerFault{Latency: time.Second})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runSchedule(ctx, []config.KafkaFault{
+		{FaultWindow: config.FaultWindow{Duration: 100 * time.Millisecond}, Broker: broker, Blackhole: true},
+		{FaultWindow: config.FaultWindow{At: 50 * time.Millisecond, Duration: 150 * time.Millisecond}, Broker: broker, ResetRate: 0.5},
+	}, func(fault config.KafkaFault) func() { return f.apply(brokerOrAny(fault.Broker), newBrokerFault(fault)) })
+
+	steps := []struct {
+		at   time.Duration
+		want BrokerFault
+	}{
+		{25 * time.Millisecond, BrokerFault{Blackhole: true}},
+		{75 * time.Millisecond, BrokerFault{ResetRate: 0.5}},
+		// The end of the first window removes only the blackhole underneath
+		{150 * time.Millisecond, BrokerFault{ResetRate: 0.5}},
+		// The fault set through the API is in effect again
+		{250 * time.Millisecond, BrokerFault{Latency: time.Second}},
+	}
+	start := time.Now()
+	for _, step := range steps {
+		time.Sleep(time.Until(start.Add(step.at)))
+		if got := f.lookup(broker); got != step.want {
+			t.Errorf("fault at %v = %+v, want %+v", step.at, got, step.want)
+		}
+	}
+	if got := testutil.ToFloat64(f.metrics.KafkaFaultActive.WithLabelValues(broker)); got != 1 {
+		t.Errorf("kafka_fault_active = %v, want 1", got)
+	}
+}
+
+func TestBlackholeReadIsCounted(t *testing.T) {
+	f, ln := newTestInjector(t)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			io.Copy(io.Discard, conn)
+		}
+	}()
+	conn, err := f.dial(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	f.Set(anyBroker, BrokerFault{Blackhole: true})
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Read during blackhole = %v, want deadline exceeded", err)
+	}
+	if got := testutil.ToFloat64(f.metrics.KafkaFaultsInjectedTotal.WithLabelValues(ln.Addr().String(), "blackhole_read")); got != 1 {
+		t.Errorf("kafka_faults_injected_total{fault=blackhole_read} = %v, want 1", got)
+	}
+}
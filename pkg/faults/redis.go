@@ -0,0 +1,214 @@
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/httpjson"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisFault describes faults injected into Redis commands by redisFaultHook.
+type RedisFault struct {
+	Delay time.Duration
+	// ErrorRate is the probability (0..1) that a command (or dial) fails.
+	ErrorRate float64
+}
+
+// redisFaultJSON is the wire form of RedisFault for the HTTP API.
+type redisFaultJSON struct {
+	Delay     string  `json:"delay,omitempty"`
+	ErrorRate float64 `json:"error_rate,omitempty"`
+}
+
+func (j redisFaultJSON) fault() (RedisFault, error) {
+	f := config.RedisFault{ErrorRate: j.ErrorRate}
+	var err error
+	if f.Delay, err = parseOptionalDuration(j.Delay); err != nil {
+		return RedisFault{}, fmt.Errorf("delay: %w", err)
+	}
+	if err := f.Validate(); err != nil {
+		return RedisFault{}, err
+	}
+	return newRedisFault(f), nil
+}
+
+func newRedisFault(f config.RedisFault) RedisFault {
+	return RedisFault{Delay: f.Delay, ErrorRate: f.ErrorRate}
+}
+
+var errInjectedRedisFault = errors.New("injected fault: redis unavailable")
+
+// RedisHook is a go-redis hook that delays or fails commands while a fault is active.
+type RedisHook struct {
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+
+	mu     sync.RWMutex
+	faults layers[RedisFault]
+}
+
+// NewRedisHook creates a Redis fault hook without an active fault.
+func NewRedisHook(m *metrics.Metrics, logger *slog.Logger) *RedisHook {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &RedisHook{metrics: m, logger: logger}
+}
+
+// current returns the fault in effect.
+func (h *RedisHook) current() (RedisFault, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.faults.top()
+}
+
+// Set activates fault for all subsequent Redis calls, replacing the fault set before; a
+// scheduled fault applied later takes precedence until its window ends.
+func (h *RedisHook) Set(fault RedisFault) {
+	h.mu.Lock()
+	h.faults.setAPI(fault)
+	h.mu.Unlock()
+	h.injected(fault)
+}
+
+// Clear removes every fault, scheduled ones included.
+func (h *RedisHook) Clear() {
+	h.mu.Lock()
+	h.faults.clear()
+	h.mu.Unlock()
+	h.metrics.RedisFaultActive.Set(0)
+	h.logger.Info("Redis fault removed")
+}
+
+// apply activates a scheduled fault; the returned function removes only that fault.
+func (h *RedisHook) apply(fault RedisFault) (stop func()) {
+	h.mu.Lock()
+	id := h.faults.push(fault)
+	h.mu.Unlock()
+	h.injected(fault)
+	return func() {
+		h.mu.Lock()
+		removed := h.faults.remove(id)
+		_, active := h.faults.top()
+		h.mu.Unlock()
+		if !removed {
+			return
+		}
+		if !active {
+			h.metrics.RedisFaultActive.Set(0)
+		}
+		h.logger.Info("Scheduled Redis fault removed", "faults_left", active)
+	}
+}
+
+func (h *RedisHook) injected(fault RedisFault) {
+	h.metrics.RedisFaultActive.Set(1)
+	h.logger.Warn("Redis fault injected", "delay", fault.Delay.String(), "error_rate", fault.ErrorRate)
+}
+
+// inject applies the active fault and returns the error to fail the operation with, if any.
+func (h *RedisHook) inject(ctx context.Context) error {
+	fault, active := h.current()
+	if !active {
+		return nil
+	}
+	if fault.Delay > 0 {
+		h.metrics.RedisFaultsInjectedTotal.WithLabelValues("delay").Inc()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(fault.Delay):
+		}
+	}
+	if fault.ErrorRate > 0 && rand.Float64() < fault.ErrorRate {
+		h.metrics.RedisFaultsInjectedTotal.WithLabelValues("error").Inc()
+		return errInjectedRedisFault
+	}
+	return nil
+}
+
+func (h *RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if err := h.inject(ctx); err != nil {
+			return nil, err
+		}
+		return next(ctx, network, addr)
+	}
+}
+
+func (h *RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := h.inject(ctx); err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h *RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := h.inject(ctx); err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		return next(ctx, cmds)
+	}
+}
+
+// ServeHTTP implements the fault control API at /faults/redis:
+// GET shows the fault in effect, PUT sets one (redisFaultJSON body), DELETE removes every
+// fault; PUT and DELETE answer 204.
+func (h *RedisHook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		fault, active := h.current()
+		httpjson.Write(w, http.StatusOK, map[string]interface{}{
+			"active": active, "delay": fault.Delay.String(), "error_rate": fault.ErrorRate,
+		})
+	case http.MethodPut, http.MethodPost:
+		var req redisFaultJSON
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fault, err := req.fault()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.Set(fault)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		h.Clear()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// SetupRedis creates the Redis fault hook when cfg enables it, registers its HTTP API on
+// mux and starts the schedule of cfg. It returns nil when disabled.
+func SetupRedis(ctx context.Context, cfg config.RedisFaults, mux *http.ServeMux, m *metrics.Metrics, logger *slog.Logger) *RedisHook {
+	if !cfg.Enabled {
+		return nil
+	}
+	h := NewRedisHook(m, logger)
+	mux.Handle("/faults/redis", h)
+	h.logger.Warn("Redis fault injection enabled")
+	runSchedule(ctx, cfg.Schedule, func(fault config.RedisFault) func() { return h.apply(newRedisFault(fault)) })
+	return h
+}
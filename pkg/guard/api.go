@@ -0,0 +1,32 @@
+package guard
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/httpjson"
+)
+
+// ServeHTTP implements the guard part of the control API:
+//
+//	GET  /control/guard        conditions, whether the guard is tripped and why
+//	POST /control/guard/reset  re-arm the tripped guards of every replica and allow chaos again
+func (g *Guard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/control/guard"), "/")
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		g.sync(r.Context())
+	case action == "reset" && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+		if err := g.Reset(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	case action == "" || action == "reset":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	httpjson.Write(w, http.StatusOK, g.status())
+}
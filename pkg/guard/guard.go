@@ -0,0 +1,417 @@
+// Package guard aborts chaos experiments that leave the cluster unable to recover. It
+// evaluates abort conditions (consumer lag, data mismatches, blocked producers and
+// custom PromQL conditions) against Prometheus or VictoriaMetrics; when one holds long
+// enough it deletes every Chaos Mesh resource in the watched namespaces and keeps
+// deleting new ones until it is reset, so the rest of a scenario is halted too. The trip
+// is shared through a ConfigMap, so every replica running the guard halts the chaos and
+// one reset re-arms them all.
+package guard
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/experiment"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// deleteTimeout bounds one sweep of the chaos resources.
+const deleteTimeout = 30 * time.Second
+
+// Names of the built-in conditions.
+const (
+	ConditionConsumerLag     = "consumer_lag"
+	ConditionDataMismatch    = "data_mismatch"
+	ConditionProducerBlocked = "producer_blocked"
+)
+
+// Abort records why and when the guard aborted the chaos.
+type Abort struct {
+	Condition string    `json:"condition"`
+	Query     string    `json:"query"`
+	Value     float64   `json:"value"`
+	Since     time.Time `json:"since"`
+	At        time.Time `json:"at"`
+	// Deleted lists the resources deleted by the abort as "Kind namespace/name".
+	Deleted []string `json:"deleted"`
+}
+
+// Guard evaluates the abort conditions every interval.
+type Guard struct {
+	conditions []config.GuardCondition
+	interval   time.Duration
+	query      func(ctx context.Context, q string) ([]float64, error)
+	client     dynamic.Interface
+	namespaces []string
+	state      sharedState
+	metrics    *metrics.Metrics
+	logger     *slog.Logger
+
+	mu sync.Mutex
+	// since holds when each breached condition started to hold
+	since map[string]time.Time
+	abort *Abort
+	// stored is false while a local abort could not be written to the shared state
+	stored bool
+}
+
+// New creates a guard with the built-in conditions enabled by cfg followed by
+// cfg.Conditions; chaos resources are deleted through client in namespaces, and the trip
+// is stored in StateConfigMap in the first of them.
+func New(cfg config.Guard, namespaces []string, client dynamic.Interface, m *metrics.Metrics, logger *slog.Logger) *Guard {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Guard{
+		conditions: Conditions(cfg),
+		interval:   cfg.Interval,
+		query:      newPromAPI(cfg.PrometheusURL).query,
+		client:     client,
+		namespaces: namespaces,
+		state:      sharedState{client: client, namespace: namespaces[0]},
+		metrics:    m,
+		logger:     logger,
+		since:      make(map[string]time.Time),
+	}
+}
+
+// Conditions returns the abort conditions of cfg: the enabled built-in ones, then the
+// configured ones.
+func Conditions(cfg config.Guard) []config.GuardCondition {
+	var conditions []config.GuardCondition
+	if cfg.MaxLag > 0 {
+		conditions = append(conditions, config.GuardCondition{
+			Name:  ConditionConsumerLag,
+			Query: "max(kafka_consumer_lag)",
+			Op:    ">",
+			Value: float64(cfg.MaxLag),
+			For:   cfg.LagFor,
+		})
+	}
+	if cfg.AbortOnMismatch {
+		conditions = append(conditions, config.GuardCondition{
+			Name:  ConditionDataMismatch,
+			Query: "sum(increase(kafka_consumer_redis_hash_mismatch_total[5m]))",
+			Op:    ">",
+			Value: 0,
+		})
+	}
+	if cfg.ProducerBlockedFor > 0 {
+		// nothing sent while sends fail; a producer paused through the control API has no errors
+		conditions = append(conditions, config.GuardCondition{
+			Name:  ConditionProducerBlocked,
+			Query: "sum(rate(kafka_producer_messages_sent_total[1m])) == 0 and on() sum(rate(kafka_producer_errors_total[1m])) > 0",
+			Op:    "==",
+			Value: 0,
+			For:   cfg.ProducerBlockedFor,
+		})
+	}
+	return append(conditions, cfg.Conditions...)
+}
+
+// Run evaluates the conditions until ctx is done.
+func (g *Guard) Run(ctx context.Context) {
+	names := make([]string, 0, len(g.conditions))
+	for _, c := range g.conditions {
+		names = append(names, c.Name)
+	}
+	g.logger.Info("Chaos abort guard started", "conditions", names, "namespaces", g.namespaces)
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			g.evaluate(ctx)
+		}
+	}
+}
+
+// evaluate checks every condition and aborts on the first that has held long enough.
+// Once tripped it only deletes chaos resources applied since.
+func (g *Guard) evaluate(ctx context.Context) {
+	g.sync(ctx)
+	if g.Tripped() {
+		if deleted := g.sweep(ctx); len(deleted) > 0 {
+			g.logger.Warn("Chaos applied while the guard is tripped, deleted", "deleted", deleted)
+		}
+		return
+	}
+	now := time.Now()
+	for _, c := range g.conditions {
+		qctx, cancel := context.WithTimeout(ctx, queryTimeout)
+		values, err := g.query(qctx, c.Query)
+		cancel()
+		if err != nil {
+			// an unavailable monitoring backend does not abort the run
+			g.metrics.ChaosGuardQueryErrorsTotal.WithLabelValues(c.Name).Inc()
+			g.logger.Warn("Failed to evaluate abort condition", "condition", c.Name, "error", err)
+			continue
+		}
+		value, breached := breach(c, values)
+
+		g.mu.Lock()
+		since, ok := g.since[c.Name]
+		switch {
+		case breached && !ok:
+			since = now
+			g.since[c.Name] = since
+		case !breached:
+			delete(g.since, c.Name)
+		}
+		g.mu.Unlock()
+
+		if !breached {
+			g.metrics.ChaosGuardConditionBreached.WithLabelValues(c.Name).Set(0)
+			continue
+		}
+		g.metrics.ChaosGuardConditionBreached.WithLabelValues(c.Name).Set(1)
+		if now.Sub(since) >= c.For {
+			g.trip(ctx, c, value, since)
+			return
+		}
+	}
+}
+
+// breach returns the first value that satisfies the condition.
+func breach(c config.GuardCondition, values []float64) (float64, bool) {
+	for _, v := range values {
+		if compare(v, c.Op, c.Value) {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+func compare(v float64, op string, threshold float64) bool {
+	switch op {
+	case "<":
+		return v < threshold
+	case "<=":
+		return v <= threshold
+	case "==":
+		return v == threshold
+	case "!=":
+		return v != threshold
+	case ">=":
+		return v >= threshold
+	case ">":
+		return v > threshold
+	}
+	return false
+}
+
+// sync adopts a trip or reset made by another replica. A local trip that could not be
+// stored is stored now instead of being reset.
+func (g *Guard) sync(ctx context.Context) {
+	g.mu.Lock()
+	local, stored := g.abort, g.stored
+	g.mu.Unlock()
+	if local != nil && !stored {
+		g.store(ctx, local)
+		return
+	}
+
+	shared, err := g.state.load(ctx)
+	if err != nil {
+		g.logger.Warn("Failed to read the shared guard state", "configmap", g.state.namespace+"/"+StateConfigMap, "error", err)
+		return
+	}
+	switch {
+	case shared != nil:
+		g.mu.Lock()
+		g.abort, g.stored = shared, true
+		g.mu.Unlock()
+		if local == nil {
+			g.metrics.ChaosGuardTripped.Set(1)
+			g.logger.Warn("Chaos aborted by another guard; new chaos is deleted until POST /control/guard/reset",
+				"condition", shared.Condition, "at", shared.At)
+		}
+	case local != nil:
+		g.resetLocal("reset by another guard")
+	}
+}
+
+// store writes a local abort to the shared state, or adopts the abort another replica
+// stored first.
+func (g *Guard) store(ctx context.Context, abort *Abort) {
+	shared, _, err := g.state.create(ctx, abort)
+	if err != nil {
+		g.logger.Warn("Failed to store the guard trip, other replicas do not see it",
+			"configmap", g.state.namespace+"/"+StateConfigMap, "error", err)
+		return
+	}
+	g.mu.Lock()
+	if g.abort == abort {
+		g.abort, g.stored = shared, true
+	}
+	g.mu.Unlock()
+}
+
+// trip aborts the chaos because of condition c.
+func (g *Guard) trip(ctx context.Context, c config.GuardCondition, value float64, since time.Time) {
+	abort := &Abort{Condition: c.Name, Query: c.Query, Value: value, Since: since, At: time.Now()}
+	shared, created, err := g.state.create(ctx, abort)
+	switch {
+	case err != nil:
+		g.logger.Warn("Failed to store the guard trip, other replicas do not see it",
+			"configmap", g.state.namespace+"/"+StateConfigMap, "error", err)
+	case !created:
+		// another replica aborted first; its abort is adopted and the chaos swept by evaluate
+		g.mu.Lock()
+		g.abort, g.stored = shared, true
+		g.mu.Unlock()
+		g.metrics.ChaosGuardTripped.Set(1)
+		g.logger.Warn("Chaos already aborted by another guard", "condition", shared.Condition, "at", shared.At)
+		return
+	}
+	g.mu.Lock()
+	g.abort, g.stored = abort, err == nil
+	g.mu.Unlock()
+	g.metrics.ChaosGuardTripped.Set(1)
+	g.metrics.ChaosGuardAbortsTotal.WithLabelValues(c.Name).Inc()
+	g.logger.Error("Abort condition breached, deleting all chaos",
+		"condition", c.Name, "query", c.Query, "op", c.Op, "threshold", c.Value, "value", value,
+		"breached_for", abort.At.Sub(since).Round(time.Second))
+
+	deleted := g.sweep(ctx)
+	g.mu.Lock()
+	abort.Deleted = deleted
+	stored := g.stored && g.abort == abort
+	g.mu.Unlock()
+	if stored {
+		if err := g.state.update(ctx, abort); err != nil {
+			g.logger.Warn("Failed to store the resources deleted by the guard", "error", err)
+		}
+	}
+	g.logger.Error("Chaos aborted by the guard; new chaos is deleted until POST /control/guard/reset",
+		"condition", c.Name, "deleted", deleted)
+}
+
+// sweep deletes every Chaos Mesh resource in the namespaces: schedules and workflows
+// first so that they cannot create new chaos, then the chaos itself.
+func (g *Guard) sweep(ctx context.Context) []string {
+	ctx, cancel := context.WithTimeout(ctx, deleteTimeout)
+	defer cancel()
+	var deleted []string
+	for _, ns := range g.namespaces {
+		for _, r := range sweepOrder() {
+			list, err := g.client.Resource(r.gvr).Namespace(ns).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				if !apierrors.IsNotFound(err) { // the kind is not installed
+					g.logger.Warn("Failed to list chaos resources", "kind", r.kind, "namespace", ns, "error", err)
+				}
+				continue
+			}
+			for _, item := range list.Items {
+				err := g.client.Resource(r.gvr).Namespace(ns).Delete(ctx, item.GetName(), metav1.DeleteOptions{})
+				if err != nil && !apierrors.IsNotFound(err) {
+					g.metrics.ChaosGuardDeletedTotal.WithLabelValues(r.kind, "failed").Inc()
+					g.logger.Error("Failed to delete chaos resource", "kind", r.kind, "namespace", ns, "name", item.GetName(), "error", err)
+					continue
+				}
+				g.metrics.ChaosGuardDeletedTotal.WithLabelValues(r.kind, "deleted").Inc()
+				deleted = append(deleted, fmt.Sprintf("%s %s/%s", r.kind, ns, item.GetName()))
+			}
+		}
+	}
+	return deleted
+}
+
+type resource struct {
+	kind string
+	gvr  schema.GroupVersionResource
+}
+
+// sweepOrder lists the resources deleted by an abort in order.
+func sweepOrder() []resource {
+	order := []resource{
+		{"Schedule", schema.GroupVersionResource{Group: "chaos-mesh.org", Version: "v1alpha1", Resource: "schedules"}},
+		{"Workflow", schema.GroupVersionResource{Group: "chaos-mesh.org", Version: "v1alpha1", Resource: "workflows"}},
+	}
+	kinds := make([]string, 0, len(experiment.ChaosMeshResources))
+	for kind := range experiment.ChaosMeshResources {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		order = append(order, resource{kind, experiment.ChaosMeshResources[kind]})
+	}
+	return order
+}
+
+// Tripped reports whether the guard has aborted the chaos and not been reset.
+func (g *Guard) Tripped() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.abort != nil
+}
+
+// Reset re-arms a tripped guard in every replica by deleting the shared state; conditions
+// must hold for their full duration again.
+func (g *Guard) Reset(ctx context.Context) error {
+	if err := g.state.clear(ctx); err != nil {
+		return fmt.Errorf("delete configmap %s/%s: %w", g.state.namespace, StateConfigMap, err)
+	}
+	g.resetLocal("reset")
+	return nil
+}
+
+func (g *Guard) resetLocal(how string) {
+	g.mu.Lock()
+	abort := g.abort
+	g.abort, g.stored = nil, false
+	g.since = make(map[string]time.Time)
+	g.mu.Unlock()
+	g.metrics.ChaosGuardTripped.Set(0)
+	if abort != nil {
+		g.logger.Info("Chaos abort guard "+how, "condition", abort.Condition, "tripped_for", time.Since(abort.At).Round(time.Second))
+	}
+}
+
+// status is the state returned by the guard API.
+type status struct {
+	Tripped    bool              `json:"tripped"`
+	Abort      *Abort            `json:"abort,omitempty"`
+	Conditions []conditionStatus `json:"conditions"`
+}
+
+type conditionStatus struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+	For   string `json:"for"`
+	// BreachedSince is set while the condition holds but has not held for long enough.
+	BreachedSince *time.Time `json:"breached_since,omitempty"`
+}
+
+func (g *Guard) status() status {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s := status{Tripped: g.abort != nil, Conditions: make([]conditionStatus, 0, len(g.conditions))}
+	if g.abort != nil {
+		abort := *g.abort
+		s.Abort = &abort
+	}
+	for _, c := range g.conditions {
+		cs := conditionStatus{Name: c.Name, Query: c.Query, Op: c.Op, Value: strconv.FormatFloat(c.Value, 'g', -1, 64), For: c.For.String()}
+		if since, ok := g.since[c.Name]; ok {
+			cs.BreachedSince = &since
+		}
+		s.Conditions = append(s.Conditions, cs)
+	}
+	return s
+}
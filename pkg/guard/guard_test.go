@@ -0,0 +1,165 @@
+package guard
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/experiment"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+const testNamespace = "kafka-cluster"
+
+func newFakeClient() *dynamicfake.FakeDynamicClient {
+	listKinds := make(map[schema.GroupVersionResource]string)
+	for _, r := range sweepOrder() {
+		listKinds[r.gvr] = r.kind + "List"
+	}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds)
+}
+
+// applyPodChaos creates a PodChaos named name, as kubectl apply would.
+func applyPodChaos(t *testing.T, client *dynamicfake.FakeDynamicClient, name string) {
+	t.Helper()
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "chaos-mesh.org/v1alpha1",
+		"kind":       "PodChaos",
+		"metadata":   map[string]interface{}{"name": name, "namespace": testNamespace},
+	}}
+	_, err := client.Resource(experiment.ChaosMeshResources["PodChaos"]).Namespace(testNamespace).Create(context.Background(), obj, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testGuard is a guard whose single condition holds while breached is set.
+type testGuard struct {
+	*Guard
+	metrics  *metrics.Metrics
+	breached bool
+}
+
+func newTestGuard(t *testing.T, client *dynamicfake.FakeDynamicClient) *testGuard {
+	t.Helper()
+	cfg := config.Default().Guard
+	cfg.PrometheusURL = "http://vmselect:8481/select/0/prometheus"
+	cfg.MaxLag, cfg.AbortOnMismatch, cfg.ProducerBlockedFor = 0, false, 0
+	cfg.Conditions = []config.GuardCondition{{Name: "lag", Query: "max(kafka_consumer_lag)", Op: ">", Value: 100}}
+	m := metrics.New(prometheus.NewRegistry())
+	tg := &testGuard{metrics: m}
+	tg.Guard = New(cfg, []string{testNamespace}, client, m, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	tg.query = func(ctx context.Context, q string) ([]float64, error) {
+		if tg.breached {
+			return []float64{500}, nil
+		}
+		return []float64{0}, nil
+	}
+	return tg
+}
+
+func chaosNames(t *testing.T, client *dynamicfake.FakeDynamicClient) []string {
+	t.Helper()
+	list, err := client.Resource(experiment.ChaosMeshResources["PodChaos"]).Namespace(testNamespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, item := range list.Items {
+		names = append(names, item.GetName())
+	}
+	return names
+}
+
+func TestTripIsSharedBetweenReplicas(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	applyPodChaos(t, client, "pod-kill")
+	first, second := newTestGuard(t, client), newTestGuard(t, client)
+
+	first.breached = true
+	first.evaluate(ctx)
+	if !first.Tripped() {
+		t.Fatal("guard not tripped by a breached condition")
+	}
+	if names := chaosNames(t, client); len(names) != 0 {
+		t.Errorf("chaos left after the abort: %v", names)
+	}
+	abort, err := first.state.load(ctx)
+	if err != nil || abort == nil || abort.Condition != "lag" || len(abort.Deleted) != 1 {
+		t.Fatalf("shared state = %+v, %v; want the lag abort with one deleted resource", abort, err)
+	}
+
+	// The other replica sees no breach itself but halts the chaos applied later
+	applyPodChaos(t, client, "next-step")
+	second.evaluate(ctx)
+	if !second.Tripped() {
+		t.Fatal("second replica did not adopt the shared trip")
+	}
+	if got := testutil.ToFloat64(second.metrics.ChaosGuardTripped); got != 1 {
+		t.Errorf("app_chaos_guard_tripped of the second replica = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(second.metrics.ChaosGuardAbortsTotal.WithLabelValues("lag")); got != 0 {
+		t.Errorf("app_chaos_guard_aborts_total of the second replica = %v, want 0", got)
+	}
+	if names := chaosNames(t, client); len(names) != 0 {
+		t.Errorf("chaos applied after the abort was kept: %v", names)
+	}
+
+	// A reset through one replica re-arms the other
+	first.breached = false
+	if err := second.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	first.evaluate(ctx)
+	if first.Tripped() || second.Tripped() {
+		t.Errorf("tripped after reset: first %v, second %v", first.Tripped(), second.Tripped())
+	}
+}
+
+func TestSecondTripAdoptsTheFirstAbort(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	first, second := newTestGuard(t, client), newTestGuard(t, client)
+	first.breached, second.breached = true, true
+
+	first.evaluate(ctx)
+	second.evaluate(ctx)
+	if got := testutil.ToFloat64(second.metrics.ChaosGuardAbortsTotal.WithLabelValues("lag")); got != 0 {
+		t.Errorf("app_chaos_guard_aborts_total of the second replica = %v, want 0", got)
+	}
+	if got, want := second.status().Abort.At, first.status().Abort.At; !got.Equal(want) {
+		t.Errorf("second replica abort at %s, want the first abort at %s", got, want)
+	}
+}
+
+func TestResetAPI(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	g := newTestGuard(t, client)
+	g.breached = true
+	g.evaluate(ctx)
+	g.breached = false
+
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/control/guard/reset", nil))
+	var s status
+	if err := json.NewDecoder(w.Body).Decode(&s); err != nil || w.Code != http.StatusOK || s.Tripped {
+		t.Fatalf("POST /control/guard/reset = %d %+v %v", w.Code, s, err)
+	}
+	if abort, err := g.state.load(ctx); abort != nil || err != nil {
+		t.Errorf("shared state after reset = %+v, %v", abort, err)
+	}
+}
@@ -0,0 +1,101 @@
+package guard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryTimeout bounds one evaluation of a condition.
+const queryTimeout = 10 * time.Second
+
+// promAPI queries the instant query API of Prometheus or VictoriaMetrics.
+type promAPI struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newPromAPI(baseURL string) *promAPI {
+	return &promAPI{baseURL: strings.TrimRight(baseURL, "/"), client: &http.Client{Timeout: queryTimeout}}
+}
+
+// queryResponse is the part of the /api/v1/query response the guard needs: a vector
+// (result is a list of series) or a scalar (result is one [time, value] pair).
+type queryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// query returns the values of the series of an instant query.
+func (p *promAPI) query(ctx context.Context, q string) ([]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		p.baseURL+"/api/v1/query?"+url.Values{"query": {q}}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	var r queryResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("query: HTTP %d: %w", resp.StatusCode, err)
+	}
+	if r.Status != "success" {
+		return nil, fmt.Errorf("query: HTTP %d: %s", resp.StatusCode, r.Error)
+	}
+
+	switch r.Data.ResultType {
+	case "vector":
+		var series []struct {
+			Value [2]interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(r.Data.Result, &series); err != nil {
+			return nil, fmt.Errorf("query: %w", err)
+		}
+		values := make([]float64, 0, len(series))
+		for _, s := range series {
+			v, err := sampleValue(s.Value)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case "scalar":
+		var sample [2]interface{}
+		if err := json.Unmarshal(r.Data.Result, &sample); err != nil {
+			return nil, fmt.Errorf("query: %w", err)
+		}
+		v, err := sampleValue(sample)
+		if err != nil {
+			return nil, err
+		}
+		return []float64{v}, nil
+	}
+	return nil, fmt.Errorf("query: unsupported result type %q, use an instant vector", r.Data.ResultType)
+}
+
+// sampleValue parses the value of a [time, "value"] pair.
+func sampleValue(sample [2]interface{}) (float64, error) {
+	s, ok := sample[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("query: sample value %v is not a string", sample[1])
+	}
+	return strconv.ParseFloat(s, 64)
+}
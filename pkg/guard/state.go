@@ -0,0 +1,106 @@
+package guard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// StateConfigMap is the ConfigMap, in the first watched namespace, that holds the abort of
+// a tripped guard. Every guard, in any replica or chart, trips while it exists and is reset
+// when it is deleted.
+const StateConfigMap = "chaos-abort-guard"
+
+// stateKey is the data key of the abort JSON in StateConfigMap.
+const stateKey = "abort"
+
+var configMaps = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+// sharedState stores the abort in StateConfigMap.
+type sharedState struct {
+	client    dynamic.Interface
+	namespace string
+}
+
+func (s sharedState) resource() dynamic.ResourceInterface {
+	return s.client.Resource(configMaps).Namespace(s.namespace)
+}
+
+// load returns the stored abort, nil when the guard is not tripped.
+func (s sharedState) load(ctx context.Context) (*Abort, error) {
+	obj, err := s.resource().Get(ctx, StateConfigMap, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := unstructured.NestedString(obj.Object, "data", stateKey)
+	if err != nil {
+		return nil, err
+	}
+	var abort Abort
+	if err := json.Unmarshal([]byte(data), &abort); err != nil {
+		return nil, fmt.Errorf("configmap %s/%s: %w", s.namespace, StateConfigMap, err)
+	}
+	return &abort, nil
+}
+
+// create stores abort unless the guard is already tripped; it returns the abort stored
+// first, which is abort when created is true.
+func (s sharedState) create(ctx context.Context, abort *Abort) (stored *Abort, created bool, err error) {
+	obj, err := configMap(abort)
+	if err != nil {
+		return nil, false, err
+	}
+	_, err = s.resource().Create(ctx, obj, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		stored, err = s.load(ctx)
+		if err == nil && stored == nil {
+			err = fmt.Errorf("configmap %s/%s: deleted while creating it", s.namespace, StateConfigMap)
+		}
+		return stored, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return abort, true, nil
+}
+
+// update replaces the stored abort, e.g. to add the deleted resources.
+func (s sharedState) update(ctx context.Context, abort *Abort) error {
+	obj, err := configMap(abort)
+	if err != nil {
+		return err
+	}
+	_, err = s.resource().Update(ctx, obj, metav1.UpdateOptions{})
+	return err
+}
+
+// clear resets the guard.
+func (s sharedState) clear(ctx context.Context) error {
+	err := s.resource().Delete(ctx, StateConfigMap, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func configMap(abort *Abort) (*unstructured.Unstructured, error) {
+	data, err := json.Marshal(abort)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": StateConfigMap},
+		"data":       map[string]interface{}{stateKey: string(data)},
+	}}, nil
+}
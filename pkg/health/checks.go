@@ -0,0 +1,222 @@
+package health
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+)
+
+// Check probes one dependency. Checks of the same Group (e.g. every Kafka broker) count
+// as one dependency for readiness: the group is down only when all of its checks are.
+type Check struct {
+	// Name identifies the check, e.g. "kafka/broker/0".
+	Name string
+	// Group is the dependency named in the readiness policy (config.Dependency*).
+	Group string
+	Probe func(ctx context.Context) error
+}
+
+// DependencyState is the last known state of a check, as served by /healthz.
+type DependencyState struct {
+	Name                string     `json:"name"`
+	Group               string     `json:"group"`
+	Critical            bool       `json:"critical"`
+	Up                  bool       `json:"up"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastCheck           *time.Time `json:"last_check,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+}
+
+type dependency struct {
+	check Check
+	state DependencyState
+	// checked is false until the first probe has completed.
+	checked bool
+	// removed is set when the check is unregistered while a probe may be running.
+	removed bool
+}
+
+// checker runs the registered checks periodically and evaluates the readiness policy.
+type checker struct {
+	policy  config.HealthPolicy
+	metrics *metrics.Metrics
+
+	mu    sync.RWMutex
+	deps  []*dependency
+	added chan struct{}
+}
+
+func newChecker(policy config.HealthPolicy, m *metrics.Metrics) *checker {
+	return &checker{policy: policy, metrics: m, added: make(chan struct{}, 1)}
+}
+
+func (c *checker) critical(group string) bool {
+	return slices.Contains(c.policy.Critical, group)
+}
+
+func (c *checker) add(check Check) {
+	c.mu.Lock()
+	c.deps = append(c.deps, &dependency{check: check, state: DependencyState{
+		Name: check.Name, Group: check.Group, Critical: c.critical(check.Group),
+	}})
+	c.mu.Unlock()
+	// Probe new checks right away instead of waiting for the next interval.
+	select {
+	case c.added <- struct{}{}:
+	default:
+	}
+}
+
+func (c *checker) remove(name string) {
+	c.mu.Lock()
+	var removed []*dependency
+	c.deps = slices.DeleteFunc(c.deps, func(d *dependency) bool {
+		if d.check.Name != name {
+			return false
+		}
+		d.removed = true
+		removed = append(removed, d)
+		return true
+	})
+	for _, d := range removed {
+		c.metrics.DependencyUp.DeleteLabelValues(d.check.Name, strconv.FormatBool(d.state.Critical))
+		c.metrics.DependencyCheckDuration.DeleteLabelValues(d.check.Name)
+	}
+	c.mu.Unlock()
+}
+
+// run probes every check each interval until ctx is cancelled.
+func (c *checker) run(ctx context.Context) {
+	ticker := time.NewTicker(c.policy.Interval)
+	defer ticker.Stop()
+	for {
+		c.probeAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.added:
+		}
+	}
+}
+
+func (c *checker) probeAll(ctx context.Context) {
+	c.mu.RLock()
+	deps := slices.Clone(c.deps)
+	c.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, d := range deps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.probe(ctx, d)
+		}()
+	}
+	wg.Wait()
+}
+
+func (c *checker) probe(ctx context.Context, d *dependency) {
+	probeCtx, cancel := context.WithTimeout(ctx, c.policy.Timeout)
+	defer cancel()
+	start := time.Now()
+	err := d.check.Probe(probeCtx)
+	if ctx.Err() != nil {
+		return
+	}
+	duration := time.Since(start)
+
+	now := time.Now()
+	// The metrics are updated under the lock so that a removed check leaves no series behind
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d.removed {
+		return
+	}
+	d.checked = true
+	d.state.LastCheck = &now
+	d.state.Up = err == nil
+	if err == nil {
+		d.state.LastSuccess = &now
+		d.state.LastError = ""
+		d.state.ConsecutiveFailures = 0
+	} else {
+		d.state.LastError = err.Error()
+		d.state.ConsecutiveFailures++
+	}
+
+	c.metrics.DependencyCheckDuration.WithLabelValues(d.check.Name).Observe(duration.Seconds())
+	value := 0.0
+	if d.state.Up {
+		value = 1
+	}
+	c.metrics.DependencyUp.WithLabelValues(d.check.Name, strconv.FormatBool(d.state.Critical)).Set(value)
+}
+
+// states returns a snapshot of every dependency state.
+func (c *checker) states() []DependencyState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	states := make([]DependencyState, 0, len(c.deps))
+	for _, d := range c.deps {
+		states = append(states, d.state)
+	}
+	return states
+}
+
+// down returns the groups that are down: no check of the group has succeeded yet, or every
+// check failed at least FailureThreshold times in a row. With critical set only critical
+// groups are returned.
+func (c *checker) down(critical bool) []string {
+	threshold := max(c.policy.FailureThreshold, 1)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	upGroups := make(map[string]bool)
+	var groups []string
+	for _, d := range c.deps {
+		if _, seen := upGroups[d.check.Group]; !seen {
+			upGroups[d.check.Group] = false
+			groups = append(groups, d.check.Group)
+		}
+		if d.state.LastSuccess != nil && d.state.ConsecutiveFailures < threshold {
+			upGroups[d.check.Group] = true
+		}
+	}
+	var down []string
+	for _, group := range groups {
+		if !upGroups[group] && (!critical || c.critical(group)) {
+			down = append(down, group)
+		}
+	}
+	return down
+}
+
+// waitUp blocks until the last probe of a check of group succeeded, ctx is cancelled or
+// timeout elapses. It reports whether the group is up.
+func (c *checker) waitUp(ctx context.Context, group string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		c.mu.RLock()
+		up := slices.ContainsFunc(c.deps, func(d *dependency) bool {
+			return d.check.Group == group && d.checked && d.state.Up
+		})
+		c.mu.RUnlock()
+		if up {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Package health exposes liveness, readiness and dependency checks over HTTP.
+package health
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/httpjson"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+)
+
+// Status holds the health and readiness flags reported by the probes. Components mark
+// themselves healthy when started and ready once connected to their dependencies. With
+// dependency checks (NewStatus) the pod is ready only while every critical dependency is up.
+type Status struct {
+	ready   atomic.Bool
+	healthy atomic.Bool
+	checks  *checker
+}
+
+// NewStatus creates a Status whose readiness also follows the dependency checks added with
+// AddCheck, according to policy. RunChecks must be running for the checks to be probed.
+func NewStatus(policy config.HealthPolicy, m *metrics.Metrics) *Status {
+	return &Status{checks: newChecker(policy, m)}
+}
+
+func (s *Status) SetReady(ready bool)     { s.ready.Store(ready) }
+func (s *Status) SetHealthy(healthy bool) { s.healthy.Store(healthy) }
+func (s *Status) Healthy() bool           { return s.healthy.Load() }
+
+// Ready reports whether the component is ready and no critical dependency is down.
+func (s *Status) Ready() bool {
+	return s.ready.Load() && len(s.criticalDown()) == 0
+}
+
+// AddCheck registers a dependency check. It is a no-op on a Status without checks.
+func (s *Status) AddCheck(check Check) {
+	if s.checks != nil {
+		s.checks.add(check)
+	}
+}
+
+// RemoveCheck unregisters the check named name, e.g. of a broker that left the cluster.
+func (s *Status) RemoveCheck(name string) {
+	if s.checks != nil {
+		s.checks.remove(name)
+	}
+}
+
+// RunChecks probes the dependencies until ctx is cancelled.
+func (s *Status) RunChecks(ctx context.Context) {
+	if s.checks != nil {
+		s.checks.run(ctx)
+	}
+}
+
+// WaitUp waits up to timeout for a check of group to succeed and reports whether one did.
+// Without checks it waits the full timeout, as a fixed startup delay.
+func (s *Status) WaitUp(ctx context.Context, group string, timeout time.Duration) bool {
+	if s.checks == nil {
+		select {
+		case <-ctx.Done():
+		case <-time.After(timeout):
+		}
+		return false
+	}
+	return s.checks.waitUp(ctx, group, timeout)
+}
+
+func (s *Status) criticalDown() []string {
+	if s.checks == nil {
+		return nil
+	}
+	return s.checks.down(true)
+}
+
+// healthJSON is the /healthz response.
+type healthJSON struct {
+	// Status is "ok", "degraded" (a non-critical dependency is down) or "unhealthy" (the
+	// process is shutting down or a critical dependency is down).
+	Status       string            `json:"status"`
+	Healthy      bool              `json:"healthy"`
+	Ready        bool              `json:"ready"`
+	Down         []string          `json:"down,omitempty"`
+	Dependencies []DependencyState `json:"dependencies"`
+}
+
+func (s *Status) report() healthJSON {
+	r := healthJSON{Status: "ok", Healthy: s.Healthy(), Ready: s.Ready(), Dependencies: []DependencyState{}}
+	if s.checks != nil {
+		r.Down = s.checks.down(false)
+		r.Dependencies = s.checks.states()
+	}
+	switch {
+	case !r.Healthy || len(s.criticalDown()) > 0:
+		r.Status = "unhealthy"
+	case len(r.Down) > 0:
+		r.Status = "degraded"
+	}
+	return r
+}
+
+// Register installs /healthz, /readyz and /livez on mux. /healthz answers with the state
+// of every dependency as JSON; its status code follows the process health only.
+func (s *Status) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		report := s.report()
+		code := http.StatusOK
+		if !report.Healthy {
+			code = http.StatusServiceUnavailable
+		}
+		httpjson.Write(w, code, report)
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if s.Ready() {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("ok"))
+		} else if down := s.criticalDown(); len(down) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready: " + strings.Join(down, ", ") + " down"))
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready"))
+		}
+	})
+
+	mux.HandleFunc("/livez", func(w http.ResponseWriter, r *http.Request) {
+		// Liveness is always ok if server is running
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+}
+
+// Server serves the health, metrics and fault control endpoints.
+type Server struct {
+	server *http.Server
+	logger *slog.Logger
+}
+
+// NewServer creates a server for handler listening on addr (e.g. ":8080").
+func NewServer(addr string, handler http.Handler, logger *slog.Logger) *Server {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Server{server: &http.Server{Addr: addr, Handler: handler}, logger: logger}
+}
+
+// Run serves until ctx is cancelled and then shuts the server down.
+func (s *Server) Run(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.server.Shutdown(shutdownCtx)
+	}()
+
+	s.logger.Info("Starting health server", "addr", s.server.Addr)
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		s.logger.Error("Health server error", "error", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+// Package httpjson writes the JSON responses of the runtime HTTP APIs.
+package httpjson
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Write sends v as a JSON response with status.
+func Write(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,189 @@
+// Package inspector reports the health of the Kafka cluster as the admin API sees it: live
+// brokers, the controller and, for the inspected topics, partition leaders and ISR,
+// under-replicated, offline and under-min-ISR partitions and the skew of the leader
+// distribution across brokers. It is the "inspector" mode of the binary and produces and
+// consumes nothing, so it can run next to the workload during chaos experiments.
+package inspector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/health"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/kafkaclient"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+	metadataAPI "github.com/segmentio/kafka-go/protocol/metadata"
+)
+
+// inspectTimeout bounds the requests of one inspection.
+const inspectTimeout = 10 * time.Second
+
+// minISRConfig is the topic config compared with the ISR size.
+const minISRConfig = "min.insync.replicas"
+
+// Deps holds the dependencies of an Inspector.
+type Deps struct {
+	// KafkaDial is used for broker connections (fault injection); nil keeps the default.
+	KafkaDial func(ctx context.Context, network, address string) (net.Conn, error)
+	// KafkaSecurity authenticates and encrypts broker connections; the zero value means
+	// plaintext without authentication.
+	KafkaSecurity kafkaclient.Security
+
+	// Metrics defaults to metrics registered on a private registry, Health to a status
+	// nobody reads and Logger to slog.Default().
+	Metrics *metrics.Metrics
+	Health  *health.Status
+	Logger  *slog.Logger
+}
+
+// Inspector inspects the cluster metadata every interval until its context is cancelled.
+type Inspector struct {
+	config  *config.Config
+	deps    Deps
+	topics  []string
+	metrics *metrics.Metrics
+	health  *health.Status
+	logger  *slog.Logger
+
+	// state of the previous successful inspection
+	last    *summary
+	leaders map[partitionKey]int32
+	brokers map[int32]bool
+}
+
+func New(cfg *config.Config, deps Deps) *Inspector {
+	if deps.Metrics == nil {
+		deps.Metrics = metrics.New(prometheus.NewRegistry())
+	}
+	if deps.Health == nil {
+		deps.Health = &health.Status{}
+	}
+	if deps.Logger == nil {
+		deps.Logger = slog.Default()
+	}
+	topics := cfg.Inspector.Topics
+	if len(topics) == 0 {
+		topics = []string{cfg.Topic}
+	}
+	return &Inspector{
+		config:  cfg,
+		deps:    deps,
+		topics:  topics,
+		metrics: deps.Metrics,
+		health:  deps.Health,
+		logger:  deps.Logger,
+		leaders: make(map[partitionKey]int32),
+		brokers: make(map[int32]bool),
+	}
+}
+
+// Run inspects the cluster at once and then every configured interval until ctx is
+// cancelled. Failed inspections are logged and counted; they do not end the run.
+func (i *Inspector) Run(ctx context.Context) error {
+	cfg := i.config
+	i.logger.Info("Starting cluster inspector", "brokers", cfg.Brokers, "topics", i.topics, "interval", cfg.Inspector.Interval)
+	i.health.SetHealthy(true)
+
+	// Connections of the inspections are attributed to brokers; the probes are not counted
+	tracker := kafkaclient.NewTracker(i.metrics, i.logger)
+	dialer := kafkaclient.NewDialer(tracker.Dial(i.deps.KafkaDial), i.deps.KafkaSecurity)
+	probeDialer := kafkaclient.NewDialer(i.deps.KafkaDial, i.deps.KafkaSecurity)
+	kafkaclient.AddBrokerChecks(i.health, cfg, probeDialer, tracker, i.metrics)
+	i.health.SetReady(true)
+
+	ticker := time.NewTicker(cfg.Inspector.Interval)
+	defer ticker.Stop()
+	for {
+		if err := i.inspect(ctx, dialer, tracker); err != nil && ctx.Err() == nil {
+			class := kafkaclient.Classify(err)
+			i.metrics.KafkaClusterMetadataErrorsTotal.WithLabelValues(class.Type).Inc()
+			i.logger.Warn("Cluster inspection failed", "error", err, "error_type", class.Type)
+		}
+		select {
+		case <-ctx.Done():
+			i.logger.Info("Cluster inspector stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// inspect fetches the metadata of the topics and their min.insync.replicas and reports
+// them.
+func (i *Inspector) inspect(ctx context.Context, dialer *kafka.Dialer, tracker *kafkaclient.Tracker) error {
+	ctx, cancel := context.WithTimeout(ctx, inspectTimeout)
+	defer cancel()
+
+	// A transport per inspection: a kafka.Transport answers metadata requests from a cache
+	// that keeps serving the last response once the cluster is unreachable. The raw
+	// response is used because kafka.Client.Metadata reports a missing leader as node 0.
+	transport := &kafka.Transport{
+		SASL: dialer.SASLMechanism,
+		TLS:  dialer.TLS,
+		Dial: dialer.DialFunc,
+	}
+	defer transport.CloseIdleConnections()
+
+	addr := kafka.TCP(i.config.Brokers...)
+	res, err := transport.RoundTrip(ctx, addr, &metadataAPI.Request{TopicNames: i.topics})
+	if err != nil {
+		return fmt.Errorf("metadata: %w", err)
+	}
+	meta := res.(*metadataAPI.Response)
+
+	brokers := make([]kafka.Broker, 0, len(meta.Brokers))
+	for _, b := range meta.Brokers {
+		brokers = append(brokers, kafka.Broker{Host: b.Host, Port: int(b.Port), ID: int(b.NodeID), Rack: b.Rack})
+	}
+	tracker.Learn(ctx, brokers)
+
+	client := &kafka.Client{Addr: addr, Timeout: inspectTimeout, Transport: transport}
+	minISR, err := i.minISR(ctx, client)
+	if err != nil {
+		// partitions are still reported, only under_min_isr is not
+		i.metrics.KafkaClusterMetadataErrorsTotal.WithLabelValues(kafkaclient.Classify(err).Type).Inc()
+		i.logger.Warn("Failed to describe topic configs, under-min-ISR partitions not counted", "error", err)
+	}
+
+	i.report(summarize(meta, minISR))
+	i.metrics.KafkaClusterLastInspectionTimestamp.SetToCurrentTime()
+	return nil
+}
+
+// minISR returns min.insync.replicas of the topics that could be described.
+func (i *Inspector) minISR(ctx context.Context, client *kafka.Client) (map[string]int, error) {
+	req := &kafka.DescribeConfigsRequest{}
+	for _, topic := range i.topics {
+		req.Resources = append(req.Resources, kafka.DescribeConfigRequestResource{
+			ResourceType: kafka.ResourceTypeTopic,
+			ResourceName: topic,
+			ConfigNames:  []string{minISRConfig},
+		})
+	}
+	res, err := client.DescribeConfigs(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	minISR := make(map[string]int, len(res.Resources))
+	for _, r := range res.Resources {
+		if r.Error != nil {
+			continue // reported through the metadata of the topic
+		}
+		for _, e := range r.ConfigEntries {
+			if e.ConfigName != minISRConfig {
+				continue
+			}
+			if n, err := strconv.Atoi(e.ConfigValue); err == nil {
+				minISR[r.ResourceName] = n
+			}
+		}
+	}
+	return minISR, nil
+}
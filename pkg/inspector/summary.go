@@ -0,0 +1,206 @@
+package inspector
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/kafkaclient"
+	"github.com/segmentio/kafka-go"
+	metadataAPI "github.com/segmentio/kafka-go/protocol/metadata"
+)
+
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+// partitionState is one partition of a metadata response; leader is -1 when offline.
+type partitionState struct {
+	key      partitionKey
+	leader   int32
+	replicas int
+	isr      int
+}
+
+// topicSummary counts the partitions of a topic by health.
+type topicSummary struct {
+	Partitions          int `json:"partitions"`
+	UnderReplicated     int `json:"under_replicated"`
+	Offline             int `json:"offline"`
+	UnderMinISR         int `json:"under_min_isr"`
+	NonPreferredLeaders int `json:"non_preferred_leaders"`
+	// Error is the metadata error of the topic, e.g. unknown_topic_or_partition.
+	Error string `json:"error,omitempty"`
+}
+
+// healthy reports whether every partition of the topic is led and fully replicated.
+func (t topicSummary) healthy() bool {
+	return t.Error == "" && t.UnderReplicated == 0 && t.Offline == 0 && t.UnderMinISR == 0
+}
+
+// summary is the cluster health seen by one inspection.
+type summary struct {
+	// Brokers are the node IDs of the live brokers, sorted.
+	Brokers []int32
+	// Controller is the node ID reported as controller. On KRaft clusters brokers report
+	// a random live broker instead of the active controller of the quorum.
+	Controller int32
+	Topics     map[string]topicSummary
+	// Leaders counts the inspected partitions led by each live broker.
+	Leaders    map[int32]int
+	LeaderSkew float64
+
+	partitions []partitionState
+}
+
+// summarize evaluates a metadata response; minISR holds min.insync.replicas per topic and
+// topics missing from it are not checked against it.
+func summarize(meta *metadataAPI.Response, minISR map[string]int) *summary {
+	s := &summary{
+		Controller: meta.ControllerID,
+		Topics:     make(map[string]topicSummary, len(meta.Topics)),
+		Leaders:    make(map[int32]int, len(meta.Brokers)),
+	}
+	for _, b := range meta.Brokers {
+		s.Brokers = append(s.Brokers, b.NodeID)
+		s.Leaders[b.NodeID] = 0
+	}
+	slices.Sort(s.Brokers)
+
+	led := 0
+	for _, t := range meta.Topics {
+		var ts topicSummary
+		if t.ErrorCode != 0 {
+			ts.Error = kafkaclient.Classify(kafka.Error(t.ErrorCode)).Type
+			s.Topics[t.Name] = ts
+			continue
+		}
+		for _, p := range t.Partitions {
+			ps := partitionState{
+				key:      partitionKey{t.Name, p.PartitionIndex},
+				leader:   p.LeaderID,
+				replicas: len(p.ReplicaNodes),
+				isr:      len(p.IsrNodes),
+			}
+			s.partitions = append(s.partitions, ps)
+			ts.Partitions++
+			if ps.isr < ps.replicas {
+				ts.UnderReplicated++
+			}
+			if n, ok := minISR[t.Name]; ok && ps.isr < n {
+				ts.UnderMinISR++
+			}
+			if ps.leader < 0 {
+				ts.Offline++
+				continue
+			}
+			if len(p.ReplicaNodes) > 0 && p.ReplicaNodes[0] != ps.leader {
+				ts.NonPreferredLeaders++
+			}
+			s.Leaders[ps.leader]++
+			led++
+		}
+		s.Topics[t.Name] = ts
+	}
+
+	// Skew of the busiest broker against an even share of the led partitions
+	if led > 0 && len(s.Leaders) > 0 {
+		most := 0
+		for _, n := range s.Leaders {
+			most = max(most, n)
+		}
+		even := float64(led) / float64(len(s.Leaders))
+		s.LeaderSkew = float64(most)/even - 1
+	}
+	return s
+}
+
+// healthy reports whether every inspected topic is healthy.
+func (s *summary) healthy() bool {
+	for _, t := range s.Topics {
+		if !t.healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// report exports s as metrics, counts leader changes since the previous inspection and
+// logs the summary when the health changed.
+func (i *Inspector) report(s *summary) {
+	m := i.metrics
+	m.KafkaClusterBrokers.Set(float64(len(s.Brokers)))
+	m.KafkaClusterControllerID.Set(float64(s.Controller))
+	m.KafkaClusterLeaderSkew.Set(s.LeaderSkew)
+	for topic, t := range s.Topics {
+		m.KafkaClusterPartitions.WithLabelValues(topic).Set(float64(t.Partitions))
+		m.KafkaClusterUnderReplicated.WithLabelValues(topic).Set(float64(t.UnderReplicated))
+		m.KafkaClusterOfflinePartitions.WithLabelValues(topic).Set(float64(t.Offline))
+		m.KafkaClusterUnderMinISR.WithLabelValues(topic).Set(float64(t.UnderMinISR))
+		m.KafkaClusterNonPreferredLeaders.WithLabelValues(topic).Set(float64(t.NonPreferredLeaders))
+	}
+
+	leaderChanges := make(map[string]int)
+	leaders := make(map[partitionKey]int32, len(s.partitions))
+	for _, p := range s.partitions {
+		partition := strconv.Itoa(int(p.key.partition))
+		m.KafkaClusterPartitionLeader.WithLabelValues(p.key.topic, partition).Set(float64(p.leader))
+		m.KafkaClusterPartitionReplicas.WithLabelValues(p.key.topic, partition).Set(float64(p.replicas))
+		m.KafkaClusterPartitionISR.WithLabelValues(p.key.topic, partition).Set(float64(p.isr))
+		if previous, ok := i.leaders[p.key]; ok && previous != p.leader {
+			m.KafkaClusterLeaderChangesTotal.WithLabelValues(p.key.topic).Inc()
+			leaderChanges[p.key.topic]++
+			i.logger.Debug("Partition leader changed", "topic", p.key.topic, "partition", p.key.partition,
+				"previous_leader", previous, "leader", p.leader)
+		}
+		leaders[p.key] = p.leader
+	}
+	// Partitions and brokers that are gone (deleted topic, removed broker) drop their series
+	for key := range i.leaders {
+		if _, ok := leaders[key]; !ok {
+			partition := strconv.Itoa(int(key.partition))
+			m.KafkaClusterPartitionLeader.DeleteLabelValues(key.topic, partition)
+			m.KafkaClusterPartitionReplicas.DeleteLabelValues(key.topic, partition)
+			m.KafkaClusterPartitionISR.DeleteLabelValues(key.topic, partition)
+		}
+	}
+	i.leaders = leaders
+	brokers := make(map[int32]bool, len(s.Leaders))
+	for id, n := range s.Leaders {
+		m.KafkaClusterLeaderPartitions.WithLabelValues(strconv.Itoa(int(id))).Set(float64(n))
+		brokers[id] = true
+	}
+	for id := range i.brokers {
+		if !brokers[id] {
+			m.KafkaClusterLeaderPartitions.DeleteLabelValues(strconv.Itoa(int(id)))
+		}
+	}
+	i.brokers = brokers
+
+	if len(leaderChanges) > 0 {
+		i.logger.Info("Partition leaders changed", "leader_changes", leaderChanges)
+	}
+
+	// The controller is left out of the comparison: KRaft brokers report a random one
+	changed := i.last == nil || !slices.Equal(i.last.Brokers, s.Brokers) || !maps.Equal(i.last.Topics, s.Topics)
+	i.last = s
+	level := slog.LevelDebug
+	switch {
+	case changed && s.healthy():
+		level = slog.LevelInfo
+	case changed:
+		level = slog.LevelWarn
+	}
+	i.logger.Log(context.Background(), level, "Cluster health",
+		"healthy", s.healthy(),
+		"brokers", s.Brokers,
+		"controller_id", s.Controller,
+		"topics", s.Topics,
+		"leaders", s.Leaders,
+		"leader_skew", math.Round(s.LeaderSkew*100)/100,
+	)
+}
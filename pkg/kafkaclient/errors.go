@@ -0,0 +1,97 @@
+package kafkaclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Error types of client errors that are not Kafka protocol errors.
+const (
+	ErrorTypeCanceled          = "canceled"
+	ErrorTypeTimeout           = "timeout"
+	ErrorTypeConnectionRefused = "connection_refused"
+	ErrorTypeConnectionReset   = "connection_reset"
+	ErrorTypeConnectionClosed  = "connection_closed"
+	ErrorTypeNetwork           = "network"
+	ErrorTypeUnknown           = "unknown"
+)
+
+// ErrorClass is the classification of a Kafka client error for metrics and logs.
+type ErrorClass struct {
+	// Type is a stable snake_case name: the protocol error title for Kafka errors (e.g.
+	// "not_enough_replicas", "leader_not_available", "request_timed_out",
+	// "message_size_too_large") or one of the ErrorType* constants.
+	Type string
+	// Code is the Kafka protocol error code, 0 for other errors.
+	Code int
+	// Retriable reports whether the operation may succeed if retried.
+	Retriable bool
+}
+
+// CodeLabel returns Code as a metric label value.
+func (c ErrorClass) CodeLabel() string {
+	return strconv.Itoa(c.Code)
+}
+
+// RetriableLabel returns Retriable as a metric label value.
+func (c ErrorClass) RetriableLabel() string {
+	return strconv.FormatBool(c.Retriable)
+}
+
+// Classify maps err to an ErrorClass. kafka.WriteErrors are classified by their first
+// error; wrapped Kafka protocol errors (including kafka.MessageTooLargeError) by their code.
+func Classify(err error) ErrorClass {
+	var writeErrors kafka.WriteErrors
+	if errors.As(err, &writeErrors) {
+		for _, e := range writeErrors {
+			if e != nil {
+				return Classify(e)
+			}
+		}
+	}
+
+	var kafkaErr kafka.Error
+	if errors.As(err, &kafkaErr) {
+		return ErrorClass{Type: protocolErrorType(kafkaErr), Code: int(kafkaErr), Retriable: kafkaErr.Temporary()}
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorClass{Type: ErrorTypeCanceled}
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorClass{Type: ErrorTypeTimeout, Retriable: true}
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorClass{Type: ErrorTypeConnectionRefused, Retriable: true}
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, net.ErrClosed):
+		return ErrorClass{Type: ErrorTypeConnectionReset, Retriable: true}
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorClass{Type: ErrorTypeConnectionClosed, Retriable: true}
+	case errors.As(err, &netErr):
+		return ErrorClass{Type: ErrorTypeNetwork, Retriable: true}
+	default:
+		return ErrorClass{Type: ErrorTypeUnknown}
+	}
+}
+
+// protocolErrorType turns the title of e ("Not Enough Replicas") into snake_case.
+func protocolErrorType(e kafka.Error) string {
+	title := e.Title()
+	if title == "" {
+		return "kafka_error_" + strconv.Itoa(int(e))
+	}
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "_")
+}
@@ -0,0 +1,138 @@
+// Package kafkaclient builds the Kafka connections shared by the producer, the consumer
+// and the health checks: SASL authentication, TLS with certificate reloading, dialers,
+// per-broker checks and attribution of connections to brokers.
+package kafkaclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/health"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+)
+
+// DialFunc dials a broker connection; the fault injector provides one.
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Security is the authentication and encryption of broker connections, created once per
+// process so that every client shares the OAuth token and the reloaded certificates. The
+// zero value connects in plaintext without authentication.
+type Security struct {
+	// SASL is built by NewSASL, TLS by (*TLS).Config.
+	SASL sasl.Mechanism
+	TLS  *tls.Config
+}
+
+// NewDialer creates a kafka.Dialer with security. dial replaces the network dialer when
+// not nil.
+func NewDialer(dial DialFunc, security Security) *kafka.Dialer {
+	return &kafka.Dialer{
+		Timeout:       10 * time.Second,
+		DualStack:     true,
+		DialFunc:      dial,
+		SASLMechanism: security.SASL,
+		TLS:           security.TLS,
+	}
+}
+
+// AddBrokerChecks adds the Kafka health checks to status: one per bootstrap address in
+// cfg.Brokers and one per broker of the cluster, all in the kafka group. Each check opens
+// a connection (including SASL authentication) and sends a metadata request. The result
+// of a bootstrap check drives kafka_bootstrap_status, and the brokers in its response are
+// passed to tracker. Every broker tracker learns about, from these checks or from the
+// metadata of the clients, is checked at its advertised address until it leaves the
+// metadata; the result drives kafka_connection_status of the broker.
+func AddBrokerChecks(status *health.Status, cfg *config.Config, dialer *kafka.Dialer, tracker *Tracker, m *metrics.Metrics) {
+	for _, bootstrap := range cfg.Brokers {
+		status.AddCheck(health.Check{
+			Name:  config.DependencyKafka + "/bootstrap/" + bootstrap,
+			Group: config.DependencyKafka,
+			Probe: func(ctx context.Context) error {
+				brokers, err := probeBroker(ctx, dialer, bootstrap, m)
+				if err != nil {
+					m.KafkaBootstrapStatus.WithLabelValues(bootstrap).Set(0)
+					return err
+				}
+				m.KafkaBootstrapStatus.WithLabelValues(bootstrap).Set(1)
+				tracker.Learn(ctx, brokers)
+				return nil
+			},
+		})
+	}
+
+	var mu sync.Mutex
+	checked := make(map[int]string) // advertised address by node ID
+	tracker.OnChange(func(brokers []kafka.Broker) {
+		mu.Lock()
+		defer mu.Unlock()
+		current := make(map[int]string, len(brokers))
+		for _, b := range brokers {
+			current[b.ID] = brokerAddr(b)
+		}
+		for id, addr := range checked {
+			if current[id] != addr {
+				status.RemoveCheck(brokerCheckName(id))
+				m.KafkaConnectionStatus.DeleteLabelValues(strconv.Itoa(id), addr)
+				delete(checked, id)
+			}
+		}
+		for id, addr := range current {
+			if _, ok := checked[id]; ok {
+				continue
+			}
+			checked[id] = addr
+			status.AddCheck(health.Check{
+				Name:  brokerCheckName(id),
+				Group: config.DependencyKafka,
+				Probe: func(ctx context.Context) error {
+					_, err := probeBroker(ctx, dialer, addr, m)
+					value := 0.0
+					if err == nil {
+						value = 1
+					}
+					m.KafkaConnectionStatus.WithLabelValues(strconv.Itoa(id), addr).Set(value)
+					return err
+				},
+			})
+		}
+	})
+}
+
+// brokerCheckName names the health check of the broker with node ID id.
+func brokerCheckName(id int) string {
+	return config.DependencyKafka + "/broker/" + strconv.Itoa(id)
+}
+
+// probeBroker sends a metadata request to address and returns the brokers of the response.
+func probeBroker(ctx context.Context, dialer *kafka.Dialer, address string, m *metrics.Metrics) ([]kafka.Broker, error) {
+	brokers, err := requestBrokers(ctx, dialer, address)
+	if err != nil {
+		countRejected(m, dialer.SASLMechanism, err)
+	}
+	return brokers, err
+}
+
+func requestBrokers(ctx context.Context, dialer *kafka.Dialer, address string) ([]kafka.Broker, error) {
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// A metadata request without topics: cheap and never auto-creates a topic.
+	brokers, err := conn.Brokers()
+	if err != nil {
+		return nil, fmt.Errorf("metadata: %w", err)
+	}
+	return brokers, nil
+}
@@ -0,0 +1,116 @@
+package kafkaclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/health"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+)
+
+func newTestTracker(t *testing.T) (*Tracker, *metrics.Metrics) {
+	t.Helper()
+	m := metrics.New(prometheus.NewRegistry())
+	return NewTracker(m, slog.New(slog.NewTextHandler(io.Discard, nil))), m
+}
+
+// checkNames returns the dependency checks reported by /healthz.
+func checkNames(t *testing.T, status *health.Status) []string {
+	t.Helper()
+	mux := http.NewServeMux()
+	status.Register(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	var report struct {
+		Dependencies []health.DependencyState `json:"dependencies"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, d := range report.Dependencies {
+		names = append(names, d.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestBrokerChecksFollowMetadata(t *testing.T) {
+	ctx := context.Background()
+	tracker, m := newTestTracker(t)
+	cfg := config.Default()
+	cfg.Brokers = []string{"bootstrap:9092"}
+	status := health.NewStatus(cfg.Health, m)
+	AddBrokerChecks(status, cfg, NewDialer(nil, Security{}), tracker, m)
+
+	if got, want := checkNames(t, status), []string{"kafka/bootstrap/bootstrap:9092"}; !slices.Equal(got, want) {
+		t.Fatalf("checks before discovery = %q, want %q", got, want)
+	}
+
+	tracker.Learn(ctx, []kafka.Broker{{ID: 0, Host: "127.0.0.1", Port: 9092}, {ID: 1, Host: "127.0.0.2", Port: 9092}})
+	want := []string{"kafka/bootstrap/bootstrap:9092", "kafka/broker/0", "kafka/broker/1"}
+	if got := checkNames(t, status); !slices.Equal(got, want) {
+		t.Errorf("checks after discovery = %q, want %q", got, want)
+	}
+
+	m.KafkaConnectionStatus.WithLabelValues("1", "127.0.0.2:9092").Set(1)
+	tracker.Learn(ctx, []kafka.Broker{{ID: 0, Host: "127.0.0.1", Port: 9092}, {ID: 2, Host: "127.0.0.3", Port: 9092}})
+	want = []string{"kafka/bootstrap/bootstrap:9092", "kafka/broker/0", "kafka/broker/2"}
+	if got := checkNames(t, status); !slices.Equal(got, want) {
+		t.Errorf("checks after broker 1 left = %q, want %q", got, want)
+	}
+	if m.KafkaConnectionStatus.DeleteLabelValues("1", "127.0.0.2:9092") {
+		t.Error("kafka_connection_status of the removed broker was kept")
+	}
+}
+
+func TestConnectionStatusOnlyForKnownBrokers(t *testing.T) {
+	ctx := context.Background()
+	tracker, m := newTestTracker(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	dial := tracker.Dial(nil)
+	addr := ln.Addr().(*net.TCPAddr)
+
+	conn, err := dial(ctx, "tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if n := testutil.CollectAndCount(m.KafkaConnectionStatus); n != 0 {
+		t.Errorf("kafka_connection_status series before discovery = %d, want 0", n)
+	}
+
+	tracker.Learn(ctx, []kafka.Broker{{ID: 3, Host: "127.0.0.1", Port: addr.Port}})
+	conn, err = dial(ctx, "tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if got := testutil.ToFloat64(m.KafkaConnectionStatus.WithLabelValues("3", addr.String())); got != 1 {
+		t.Errorf("kafka_connection_status of broker 3 = %v, want 1", got)
+	}
+}
@@ -0,0 +1,183 @@
+package kafkaclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/segmentio/kafka-go/sasl"
+)
+
+// tokenRetryInterval spaces the refresh attempts while the token endpoint fails and the
+// current token is still valid.
+const tokenRetryInterval = 5 * time.Second
+
+// oauthBearer is the SASL OAUTHBEARER mechanism (RFC 7628) as used by Kafka (KIP-255).
+type oauthBearer struct {
+	tokens *tokenSource
+}
+
+func (*oauthBearer) Name() string {
+	return config.SASLOAuthBearer
+}
+
+func (o *oauthBearer) Start(ctx context.Context) (sasl.StateMachine, []byte, error) {
+	token, err := o.tokens.Token(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return o, []byte("n,,\x01auth=Bearer " + token + "\x01\x01"), nil
+}
+
+// Next accepts the empty response of a successful authentication. A rejected token is
+// answered with an error JSON document (RFC 7628 section 3.2.2).
+func (o *oauthBearer) Next(ctx context.Context, challenge []byte) (bool, []byte, error) {
+	if len(challenge) > 0 {
+		return false, nil, fmt.Errorf("%w: %s", errRejected, challenge)
+	}
+	return true, nil, nil
+}
+
+// tokenSource requests access tokens with the client credentials grant and caches them
+// until RefreshRatio of their lifetime has passed.
+type tokenSource struct {
+	cfg     config.OAuth
+	client  *http.Client
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+	// now is time.Now, replaced in tests
+	now func() time.Time
+
+	// mu is held during requests so that concurrent connections share one
+	mu        sync.Mutex
+	token     string
+	expires   time.Time
+	refreshAt time.Time
+}
+
+func newTokenSource(cfg config.OAuth, m *metrics.Metrics, logger *slog.Logger) *tokenSource {
+	return &tokenSource{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		metrics: m,
+		logger:  logger,
+		now:     time.Now,
+	}
+}
+
+// Token returns a valid access token, requesting a new one when the current one is due
+// for refresh. While the endpoint fails the current token is used until it expires.
+func (s *tokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if s.token != "" && now.Before(s.refreshAt) && now.Before(s.expires) {
+		return s.token, nil
+	}
+
+	token, expires, err := s.request(ctx)
+	if err != nil {
+		s.metrics.KafkaOAuthTokenRequestsTotal.WithLabelValues("failed").Inc()
+		if s.token != "" && now.Before(s.expires) {
+			s.refreshAt = now.Add(tokenRetryInterval)
+			s.logger.Warn("Failed to refresh OAuth token, using the current one", "expires", s.expires, "error", err)
+			return s.token, nil
+		}
+		return "", fmt.Errorf("%w: %w", errTokenUnavailable, err)
+	}
+	s.metrics.KafkaOAuthTokenRequestsTotal.WithLabelValues("success").Inc()
+	s.metrics.KafkaOAuthTokenExpiry.Set(float64(expires.Unix()))
+	s.token, s.expires = token, expires
+	s.refreshAt = now.Add(time.Duration(float64(expires.Sub(now)) * s.cfg.RefreshRatio))
+	s.logger.Info("OAuth token obtained", "token_url", s.cfg.TokenURL, "expires", expires)
+	return token, nil
+}
+
+// request performs the client credentials grant.
+func (s *tokenSource) request(ctx context.Context) (string, time.Time, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {s.cfg.ClientID},
+		"client_secret": {s.cfg.ClientSecret},
+	}
+	if s.cfg.Scope != "" {
+		form.Set("scope", s.cfg.Scope)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	sent := s.now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	var res struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	jsonErr := json.Unmarshal(body, &res)
+	if resp.StatusCode != http.StatusOK {
+		if res.Error != "" {
+			return "", time.Time{}, fmt.Errorf("token endpoint: %s: %s %s", resp.Status, res.Error, res.ErrorDescription)
+		}
+		return "", time.Time{}, fmt.Errorf("token endpoint: %s", resp.Status)
+	}
+	if jsonErr != nil {
+		return "", time.Time{}, fmt.Errorf("token endpoint response: %w", jsonErr)
+	}
+	if res.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("token endpoint response: no access_token")
+	}
+
+	// The lifetime counts from the request; without expires_in the exp claim of a JWT is used
+	expires := sent.Add(time.Duration(res.ExpiresIn) * time.Second)
+	if res.ExpiresIn <= 0 {
+		exp, ok := jwtExpiry(res.AccessToken)
+		if !ok {
+			return "", time.Time{}, fmt.Errorf("token endpoint response: no expires_in and no exp claim")
+		}
+		expires = exp
+	}
+	return res.AccessToken, expires, nil
+}
+
+// jwtExpiry returns the exp claim of a JWT without verifying it.
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
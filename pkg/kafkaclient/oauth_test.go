@@ -0,0 +1,115 @@
+package kafkaclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// tokenServer is a client credentials endpoint issuing "token-<n>" for 100 seconds.
+type tokenServer struct {
+	requests atomic.Int32
+	fail     atomic.Bool
+}
+
+func (s *tokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := s.requests.Add(1)
+	if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_id") != "chaos" {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+	if s.fail.Load() {
+		http.Error(w, `{"error":"temporarily_unavailable"}`, http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":100}`, n)
+}
+
+func TestOAuthTokenRefreshAndOutage(t *testing.T) {
+	server := &tokenServer{}
+	endpoint := httptest.NewServer(server)
+	defer endpoint.Close()
+
+	cfg := config.Default()
+	cfg.SASLMechanism = config.SASLOAuthBearer
+	cfg.OAuth.TokenURL, cfg.OAuth.ClientID, cfg.OAuth.ClientSecret = endpoint.URL, "chaos", "secret"
+	m := metrics.New(prometheus.NewRegistry())
+	mechanism, err := NewSASL(cfg, m, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	now := start
+	mechanism.(*instrumentedSASL).Mechanism.(*oauthBearer).tokens.now = func() time.Time { return now }
+
+	// authenticate returns the token sent at time offset after the first request
+	authenticate := func(offset time.Duration) (string, error) {
+		t.Helper()
+		now = start.Add(offset)
+		_, ir, err := mechanism.Start(context.Background())
+		if err != nil {
+			return "", err
+		}
+		token, _, _ := strings.Cut(strings.TrimPrefix(string(ir), "n,,\x01auth=Bearer "), "\x01")
+		return token, nil
+	}
+	steps := []struct {
+		offset   time.Duration
+		fail     bool
+		token    string
+		requests int32
+	}{
+		{0, false, "token-1", 1},
+		// Reused until RefreshRatio (0.8) of the 100s lifetime has passed
+		{79 * time.Second, false, "token-1", 1},
+		{80 * time.Second, false, "token-2", 2},
+		// The endpoint fails: the current token is used while it is valid, retried after
+		// tokenRetryInterval
+		{160 * time.Second, true, "token-2", 3},
+		{162 * time.Second, true, "token-2", 3},
+		{166 * time.Second, true, "token-2", 4},
+	}
+	for _, step := range steps {
+		server.fail.Store(step.fail)
+		token, err := authenticate(step.offset)
+		if err != nil {
+			t.Fatalf("at %v: %v", step.offset, err)
+		}
+		if token != step.token {
+			t.Errorf("at %v: token = %q, want %q", step.offset, token, step.token)
+		}
+		if got := server.requests.Load(); got != step.requests {
+			t.Errorf("at %v: %d token requests, want %d", step.offset, got, step.requests)
+		}
+	}
+	if got := testutil.ToFloat64(m.KafkaOAuthTokenRequestsTotal.WithLabelValues("failed")); got != 2 {
+		t.Errorf("kafka_oauth_token_requests_total{result=failed} = %v, want 2", got)
+	}
+
+	// Once the token has expired the authentication fails
+	if _, err := authenticate(180 * time.Second); !errors.Is(err, errTokenUnavailable) {
+		t.Fatalf("authentication after expiry: %v, want %v", err, errTokenUnavailable)
+	}
+	if got := testutil.ToFloat64(m.KafkaSASLAuthenticationsTotal.WithLabelValues(config.SASLOAuthBearer, authTokenUnavailable)); got != 1 {
+		t.Errorf("kafka_sasl_authentications_total{result=token_unavailable} = %v, want 1", got)
+	}
+
+	// The endpoint recovers
+	server.fail.Store(false)
+	if token, err := authenticate(181 * time.Second); err != nil || token != "token-6" {
+		t.Errorf("authentication after recovery: %q, %v, want token-6", token, err)
+	}
+}
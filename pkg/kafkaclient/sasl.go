@@ -0,0 +1,159 @@
+package kafkaclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Results of SASL authentications in kafka_sasl_authentications_total.
+const (
+	authSuccess          = "success"
+	authTokenUnavailable = "token_unavailable"
+	authRejected         = "rejected"
+	authFailed           = "failed"
+)
+
+var (
+	// errTokenUnavailable wraps failures to obtain an OAuth token.
+	errTokenUnavailable = errors.New("oauth token unavailable")
+	// errRejected wraps authentications refused by the broker.
+	errRejected = errors.New("authentication rejected by the broker")
+)
+
+// NewSASL returns the SASL mechanism selected by cfg.SASLMechanism, or nil when SCRAM or
+// PLAIN have no credentials. Authentications through it are counted in the metrics; a
+// successful one with a broker that had authenticated the client before (a new connection
+// after the broker closed an expired session or after a failure) is logged at debug
+// level and counted as a re-authentication.
+func NewSASL(cfg *config.Config, m *metrics.Metrics, logger *slog.Logger) (sasl.Mechanism, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	var mechanism sasl.Mechanism
+	switch cfg.SASLMechanism {
+	case config.SASLOAuthBearer:
+		mechanism = &oauthBearer{tokens: newTokenSource(cfg.OAuth, m, logger)}
+	default:
+		if cfg.Username == "" || cfg.Password == "" {
+			return nil, nil
+		}
+		switch cfg.SASLMechanism {
+		case config.SASLPlain:
+			mechanism = plain.Mechanism{Username: cfg.Username, Password: cfg.Password}
+		case config.SASLScramSHA256, config.SASLScramSHA512:
+			algorithm := scram.SHA512
+			if cfg.SASLMechanism == config.SASLScramSHA256 {
+				algorithm = scram.SHA256
+			}
+			var err error
+			if mechanism, err = scram.Mechanism(algorithm, cfg.Username, cfg.Password); err != nil {
+				return nil, fmt.Errorf("failed to create SCRAM mechanism: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown SASL mechanism %q", cfg.SASLMechanism)
+		}
+	}
+	return &instrumentedSASL{
+		Mechanism:     mechanism,
+		metrics:       m,
+		logger:        logger,
+		authenticated: make(map[string]bool),
+	}, nil
+}
+
+// instrumentedSASL counts the authentications of a mechanism. Rejections by the broker
+// end the authentication without a call to the mechanism for SCRAM and PLAIN; they are
+// counted by the broker health checks (countRejected).
+type instrumentedSASL struct {
+	sasl.Mechanism
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+
+	mu sync.Mutex
+	// authenticated holds the brokers ("host:port") the client has authenticated with
+	authenticated map[string]bool
+}
+
+func (s *instrumentedSASL) Start(ctx context.Context) (sasl.StateMachine, []byte, error) {
+	sess, ir, err := s.Mechanism.Start(ctx)
+	if err != nil {
+		s.failed(err)
+		return nil, nil, err
+	}
+	return &instrumentedSession{StateMachine: sess, sasl: s}, ir, nil
+}
+
+type instrumentedSession struct {
+	sasl.StateMachine
+	sasl *instrumentedSASL
+}
+
+func (s *instrumentedSession) Next(ctx context.Context, challenge []byte) (bool, []byte, error) {
+	done, response, err := s.StateMachine.Next(ctx, challenge)
+	switch {
+	case err != nil:
+		s.sasl.failed(err)
+	case done:
+		s.sasl.succeeded(ctx)
+	}
+	return done, response, err
+}
+
+func (s *instrumentedSASL) succeeded(ctx context.Context) {
+	name := s.Name()
+	s.metrics.KafkaSASLAuthenticationsTotal.WithLabelValues(name, authSuccess).Inc()
+	meta := sasl.MetadataFromContext(ctx)
+	if meta == nil {
+		return
+	}
+	broker := net.JoinHostPort(meta.Host, strconv.Itoa(meta.Port))
+	s.mu.Lock()
+	again := s.authenticated[broker]
+	s.authenticated[broker] = true
+	s.mu.Unlock()
+	if again {
+		s.metrics.KafkaSASLReauthenticationsTotal.WithLabelValues(name, broker).Inc()
+		s.logger.Debug("Kafka SASL re-authentication", "mechanism", name, "broker", broker)
+	}
+}
+
+func (s *instrumentedSASL) failed(err error) {
+	result := authFailed
+	switch {
+	case errors.Is(err, errTokenUnavailable):
+		result = authTokenUnavailable
+	case errors.Is(err, errRejected):
+		result = authRejected
+	}
+	s.metrics.KafkaSASLAuthenticationsTotal.WithLabelValues(s.Name(), result).Inc()
+	s.logger.Warn("Kafka SASL authentication failed", "mechanism", s.Name(), "result", result, "error", err)
+}
+
+// countRejected counts err as an authentication rejected by the broker if it is a SASL
+// protocol error; kafka-go reports a connection closed during authentication as
+// SASLAuthenticationFailed.
+func countRejected(m *metrics.Metrics, mechanism sasl.Mechanism, err error) {
+	if mechanism == nil {
+		return
+	}
+	var kafkaErr kafka.Error
+	if !errors.As(err, &kafkaErr) {
+		return
+	}
+	switch kafkaErr {
+	case kafka.SASLAuthenticationFailed, kafka.IllegalSASLState, kafka.UnsupportedSASLMechanism:
+		m.KafkaSASLAuthenticationsTotal.WithLabelValues(mechanism.Name(), authRejected).Inc()
+	}
+}
@@ -0,0 +1,250 @@
+package kafkaclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+)
+
+// Certificates reported in the TLS metrics and logs.
+const (
+	certificateCA     = "ca"
+	certificateClient = "client"
+)
+
+// TLS is the TLS configuration of broker connections. Its certificates follow the files
+// they were loaded from: Run re-reads the files, and connections opened after a change
+// trust the new CA bundle and present the new client certificate. A nil *TLS means
+// plaintext connections.
+type TLS struct {
+	cfg     config.KafkaTLS
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+
+	mu sync.RWMutex
+	// roots is nil for the system roots
+	roots *x509.CertPool
+	cert  *tls.Certificate
+	// contents of the files last loaded successfully
+	caData, certData []byte
+}
+
+// NewTLS loads the certificates configured by cfg; it returns nil when TLS is disabled.
+func NewTLS(cfg config.KafkaTLS, m *metrics.Metrics, logger *slog.Logger) (*TLS, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	t := &TLS{cfg: cfg, metrics: m, logger: logger}
+	if err := t.reloadCA(); err != nil {
+		return nil, err
+	}
+	if err := t.reloadClient(); err != nil {
+		return nil, err
+	}
+	if cfg.InsecureSkipVerify {
+		logger.Warn("TLS verification of broker certificates is disabled")
+	}
+	return t, nil
+}
+
+// Config returns the tls.Config for kafka.Dialer and kafka.Transport, nil for a nil t.
+// Broker certificates are verified in VerifyConnection against the current CA bundle,
+// because RootCAs cannot change once kafka-go has cloned the config.
+func (t *TLS) Config() *tls.Config {
+	if t == nil {
+		return nil
+	}
+	c := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.cfg.ServerName,
+		InsecureSkipVerify: true,
+	}
+	if !t.cfg.InsecureSkipVerify {
+		c.VerifyConnection = t.verify
+	}
+	if t.cfg.CertFile != "" {
+		c.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			t.mu.RLock()
+			defer t.mu.RUnlock()
+			return t.cert, nil
+		}
+	}
+	return c
+}
+
+// verify checks the certificate chain of a broker and its host name, like the standard
+// verification does with RootCAs.
+func (t *TLS) verify(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: broker presented no certificate")
+	}
+	t.mu.RLock()
+	roots := t.roots
+	t.mu.RUnlock()
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       cs.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	if err != nil {
+		t.metrics.KafkaTLSVerifyErrorsTotal.WithLabelValues(verifyErrorReason(err)).Inc()
+	}
+	return err
+}
+
+func verifyErrorReason(err error) string {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return "unknown_authority"
+	case errors.As(err, &hostname):
+		return "hostname"
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return "expired"
+	}
+	return "invalid"
+}
+
+// Run re-reads the certificate files every reload interval until ctx is done. A file
+// that fails to load keeps the previous certificates in use and is retried.
+func (t *TLS) Run(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	ticker := time.NewTicker(t.cfg.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.reload(certificateCA, t.reloadCA)
+			t.reload(certificateClient, t.reloadClient)
+		}
+	}
+}
+
+func (t *TLS) reload(certificate string, load func() error) {
+	if err := load(); err != nil {
+		t.metrics.KafkaTLSReloadsTotal.WithLabelValues(certificate, "failed").Inc()
+		t.logger.Warn("Failed to reload TLS certificate, keeping the previous one", "certificate", certificate, "error", err)
+	}
+}
+
+// reloadCA loads the CA bundle if it changed since the last load.
+func (t *TLS) reloadCA() error {
+	if t.cfg.CAFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(t.cfg.CAFile)
+	if err != nil {
+		return fmt.Errorf("read TLS CA file: %w", err)
+	}
+	t.mu.RLock()
+	unchanged := bytes.Equal(data, t.caData)
+	t.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	roots := x509.NewCertPool()
+	var expiry time.Time
+	var subjects []string
+	for rest := data; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("parse TLS CA file %s: %w", t.cfg.CAFile, err)
+		}
+		roots.AddCert(cert)
+		subjects = append(subjects, cert.Subject.String())
+		if expiry.IsZero() || cert.NotAfter.Before(expiry) {
+			expiry = cert.NotAfter
+		}
+	}
+	if len(subjects) == 0 {
+		return fmt.Errorf("TLS CA file %s: no PEM certificates", t.cfg.CAFile)
+	}
+
+	t.mu.Lock()
+	first := t.caData == nil
+	t.roots, t.caData = roots, data
+	t.mu.Unlock()
+	t.loaded(certificateCA, first, expiry, "file", t.cfg.CAFile, "subjects", subjects)
+	return nil
+}
+
+// reloadClient loads the client certificate and key if either changed since the last
+// load.
+func (t *TLS) reloadClient() error {
+	if t.cfg.CertFile == "" {
+		return nil
+	}
+	certPEM, err := os.ReadFile(t.cfg.CertFile)
+	if err != nil {
+		return fmt.Errorf("read TLS certificate file: %w", err)
+	}
+	keyPEM, err := os.ReadFile(t.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("read TLS key file: %w", err)
+	}
+	data := append(append([]byte(nil), certPEM...), keyPEM...)
+	t.mu.RLock()
+	unchanged := bytes.Equal(data, t.certData)
+	t.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	// A pair read in the middle of a rotation does not match and is retried
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("load TLS client certificate %s: %w", t.cfg.CertFile, err)
+	}
+
+	t.mu.Lock()
+	first := t.certData == nil
+	t.cert, t.certData = &cert, data
+	t.mu.Unlock()
+	t.loaded(certificateClient, first, cert.Leaf.NotAfter, "file", t.cfg.CertFile, "subject", cert.Leaf.Subject.String())
+	return nil
+}
+
+// loaded records a loaded certificate; reloads after the first load are logged as
+// rotations.
+func (t *TLS) loaded(certificate string, first bool, expiry time.Time, attrs ...interface{}) {
+	t.metrics.KafkaTLSCertificateExpiry.WithLabelValues(certificate).Set(float64(expiry.Unix()))
+	attrs = append([]interface{}{"certificate", certificate, "not_after", expiry}, attrs...)
+	if first {
+		t.logger.Info("TLS certificate loaded", attrs...)
+		return
+	}
+	t.metrics.KafkaTLSReloadsTotal.WithLabelValues(certificate, "success").Inc()
+	t.logger.Info("TLS certificate rotated", attrs...)
+}
@@ -0,0 +1,159 @@
+package kafkaclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// testCert is a certificate with its key, parsed and as PEM.
+type testCert struct {
+	cert            *x509.Certificate
+	key             *ecdsa.PrivateKey
+	certPEM, keyPEM []byte
+}
+
+// newTestCert creates a certificate for name signed by parent, self-signed when parent is
+// nil; a CA when isCA is set.
+func newTestCert(t *testing.T, name string, isCA bool, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSReloadsRotatedCA(t *testing.T) {
+	oldCA, newCA := newTestCert(t, "old-ca", true, nil), newTestCert(t, "new-ca", true, nil)
+	broker := newTestCert(t, "broker-0", false, newCA)
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	writeFile(t, caFile, oldCA.certPEM)
+	_, m := newTestTracker(t)
+	cfg := config.Default().TLS
+	cfg.Enabled, cfg.CAFile = true, caFile
+	kafkaTLS, err := NewTLS(cfg, m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := tls.ConnectionState{ServerName: "broker-0", PeerCertificates: []*x509.Certificate{broker.cert}}
+
+	if err := kafkaTLS.Config().VerifyConnection(state); err == nil {
+		t.Fatal("broker certificate of an unknown CA accepted")
+	}
+	if got := testutil.ToFloat64(m.KafkaTLSVerifyErrorsTotal.WithLabelValues("unknown_authority")); got != 1 {
+		t.Errorf("kafka_tls_verify_errors_total{reason=unknown_authority} = %v, want 1", got)
+	}
+
+	// The config handed to kafka-go before the rotation trusts the new CA after it
+	config := kafkaTLS.Config()
+	writeFile(t, caFile, append(oldCA.certPEM, newCA.certPEM...))
+	kafkaTLS.reload(certificateCA, kafkaTLS.reloadCA)
+	if err := config.VerifyConnection(state); err != nil {
+		t.Errorf("broker certificate after the CA rotation: %v", err)
+	}
+	if got := testutil.ToFloat64(m.KafkaTLSReloadsTotal.WithLabelValues(certificateCA, "success")); got != 1 {
+		t.Errorf("kafka_tls_reloads_total{result=success} = %v, want 1", got)
+	}
+
+	// A broken file keeps the previous bundle
+	writeFile(t, caFile, []byte("not a certificate"))
+	kafkaTLS.reload(certificateCA, kafkaTLS.reloadCA)
+	if got := testutil.ToFloat64(m.KafkaTLSReloadsTotal.WithLabelValues(certificateCA, "failed")); got != 1 {
+		t.Errorf("kafka_tls_reloads_total{result=failed} = %v, want 1", got)
+	}
+	if err := config.VerifyConnection(state); err != nil {
+		t.Errorf("broker certificate after a failed reload: %v", err)
+	}
+}
+
+func TestTLSReloadsRotatedClientCertificate(t *testing.T) {
+	ca := newTestCert(t, "clients-ca", true, nil)
+	first, second := newTestCert(t, "chaos-user", false, ca), newTestCert(t, "chaos-user", false, ca)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "user.crt"), filepath.Join(dir, "user.key")
+	writeFile(t, certFile, first.certPEM)
+	writeFile(t, keyFile, first.keyPEM)
+	_, m := newTestTracker(t)
+	cfg := config.Default().TLS
+	cfg.Enabled, cfg.CertFile, cfg.KeyFile = true, certFile, keyFile
+	kafkaTLS, err := NewTLS(cfg, m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := kafkaTLS.Config()
+	presented := func() *x509.Certificate {
+		cert, err := config.GetClientCertificate(&tls.CertificateRequestInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert.Leaf
+	}
+	if !presented().Equal(first.cert) {
+		t.Fatal("first client certificate not presented")
+	}
+
+	// A certificate written before its key does not match and is retried
+	writeFile(t, certFile, second.certPEM)
+	kafkaTLS.reload(certificateClient, kafkaTLS.reloadClient)
+	if !presented().Equal(first.cert) {
+		t.Error("half-rotated pair replaced the client certificate")
+	}
+	writeFile(t, keyFile, second.keyPEM)
+	kafkaTLS.reload(certificateClient, kafkaTLS.reloadClient)
+	if !presented().Equal(second.cert) {
+		t.Error("rotated client certificate not presented")
+	}
+	if got := testutil.ToFloat64(m.KafkaTLSCertificateExpiry.WithLabelValues(certificateClient)); got != float64(second.cert.NotAfter.Unix()) {
+		t.Errorf("kafka_tls_certificate_expiry_timestamp_seconds = %v, want %d", got, second.cert.NotAfter.Unix())
+	}
+}
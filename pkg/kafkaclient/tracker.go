@@ -0,0 +1,318 @@
+package kafkaclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/segmentio/kafka-go"
+)
+
+// unknownBrokerID labels connections whose broker has not been discovered yet.
+const unknownBrokerID = "unknown"
+
+// brokerRef identifies the broker a connection is attributed to.
+type brokerRef struct {
+	id   string // node ID, or unknownBrokerID
+	addr string // advertised host:port, or the dialed address when unknown
+}
+
+// key identifies the broker across addresses: the node ID once discovered.
+func (b brokerRef) key() string {
+	if b.id != unknownBrokerID {
+		return b.id
+	}
+	return b.addr
+}
+
+// Tracker attributes client connections to brokers. Connections dialed through Dial are
+// counted per broker node ID and advertised address: opens, closes, dial failures and
+// I/O errors. Brokers are learned from metadata responses (see Learn); a connection to a
+// bootstrap address is attributed to the broker behind it by its remote IP.
+type Tracker struct {
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+
+	mu     sync.Mutex
+	byAddr map[string]kafka.Broker // advertised host:port
+	byIP   map[string]kafka.Broker // resolved ip:port
+	// lost holds brokers whose connection failed since their last successful open.
+	lost map[string]bool
+	// bootstrap holds the broker last reached through each bootstrap address.
+	bootstrap map[string]brokerRef
+	onChange  []func(brokers []kafka.Broker)
+}
+
+// NewTracker creates a Tracker that reports to m.
+func NewTracker(m *metrics.Metrics, logger *slog.Logger) *Tracker {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Tracker{
+		metrics:   m,
+		logger:    logger,
+		byAddr:    make(map[string]kafka.Broker),
+		byIP:      make(map[string]kafka.Broker),
+		lost:      make(map[string]bool),
+		bootstrap: make(map[string]brokerRef),
+	}
+}
+
+// Dial wraps dial (nil = plain TCP) so that its connections are tracked.
+func (t *Tracker) Dial(dial DialFunc) DialFunc {
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 10 * time.Second}).DialContext
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			// A cancelled dial is a shutdown or an abandoned request, not a broker failure.
+			if !errors.Is(ctx.Err(), context.Canceled) {
+				t.failed(t.lookup(address, ""), err)
+			}
+			return nil, err
+		}
+		broker := t.lookup(address, conn.RemoteAddr().String())
+		t.opened(broker)
+		return &trackedConn{Conn: conn, tracker: t, broker: broker}, nil
+	}
+}
+
+// Learn records the brokers of a metadata response and resolves their hosts, so that
+// later connections, including those to a bootstrap address, are attributed to them.
+func (t *Tracker) Learn(ctx context.Context, brokers []kafka.Broker) {
+	byIP := make(map[string]kafka.Broker)
+	unresolved := make(map[kafka.Broker]bool)
+	for _, b := range brokers {
+		port := strconv.Itoa(b.Port)
+		ips, err := net.DefaultResolver.LookupHost(ctx, b.Host)
+		if err != nil {
+			t.logger.Debug("Failed to resolve Kafka broker", "broker_id", b.ID, "host", b.Host, "error", err)
+			unresolved[b] = true
+			continue
+		}
+		for _, ip := range ips {
+			byIP[net.JoinHostPort(ip, port)] = b
+		}
+	}
+
+	t.mu.Lock()
+	changed := len(brokers) != len(t.byAddr)
+	for _, b := range brokers {
+		addr := brokerAddr(b)
+		if known, ok := t.byAddr[addr]; !ok || known != b {
+			changed = true
+		}
+	}
+	if changed {
+		for addr, b := range t.byAddr {
+			t.metrics.KafkaBrokerInfo.DeleteLabelValues(strconv.Itoa(b.ID), addr, b.Rack)
+		}
+		t.byAddr = make(map[string]kafka.Broker, len(brokers))
+		for _, b := range brokers {
+			t.byAddr[brokerAddr(b)] = b
+		}
+	}
+	// Keep the previous addresses of brokers whose host did not resolve this time.
+	for ip, b := range t.byIP {
+		if unresolved[b] {
+			byIP[ip] = b
+		}
+	}
+	t.byIP = byIP
+	t.mu.Unlock()
+
+	if changed {
+		for _, b := range brokers {
+			t.metrics.KafkaBrokerInfo.WithLabelValues(strconv.Itoa(b.ID), brokerAddr(b), b.Rack).Set(1)
+		}
+		t.logger.Info("Discovered Kafka brokers", "brokers", brokerAddrs(brokers))
+		t.mu.Lock()
+		onChange := t.onChange
+		t.mu.Unlock()
+		// Concurrent calls may run the callbacks out of order, so they get the brokers known
+		// now rather than those of this response
+		for _, f := range onChange {
+			f(t.brokers())
+		}
+	}
+}
+
+// OnChange registers f to be called synchronously with the known brokers whenever Learn
+// finds that they changed.
+func (t *Tracker) OnChange(f func(brokers []kafka.Broker)) {
+	t.mu.Lock()
+	t.onChange = append(t.onChange, f)
+	t.mu.Unlock()
+}
+
+// brokers returns the known brokers ordered by node ID.
+func (t *Tracker) brokers() []kafka.Broker {
+	t.mu.Lock()
+	brokers := make([]kafka.Broker, 0, len(t.byAddr))
+	for _, b := range t.byAddr {
+		brokers = append(brokers, b)
+	}
+	t.mu.Unlock()
+	slices.SortFunc(brokers, func(a, b kafka.Broker) int { return a.ID - b.ID })
+	return brokers
+}
+
+// lookup attributes a connection to address (remote is its remote ip:port, empty when the
+// dial failed). A bootstrap address that is not an advertised broker address is mapped by
+// remote; the mapping is exported as kafka_bootstrap_broker.
+func (t *Tracker) lookup(address, remote string) brokerRef {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if b, ok := t.byAddr[address]; ok {
+		return brokerRef{id: strconv.Itoa(b.ID), addr: address}
+	}
+	b, ok := t.byIP[remote]
+	if !ok {
+		return brokerRef{id: unknownBrokerID, addr: address}
+	}
+	ref := brokerRef{id: strconv.Itoa(b.ID), addr: brokerAddr(b)}
+	if previous, ok := t.bootstrap[address]; !ok || previous != ref {
+		if ok {
+			t.metrics.KafkaBootstrapBroker.DeleteLabelValues(address, previous.id, previous.addr)
+		}
+		t.bootstrap[address] = ref
+		t.metrics.KafkaBootstrapBroker.WithLabelValues(address, ref.id, ref.addr).Set(1)
+	}
+	return ref
+}
+
+func (t *Tracker) opened(b brokerRef) {
+	t.metrics.KafkaBrokerConnectionsOpened.WithLabelValues(b.id, b.addr).Inc()
+	t.metrics.KafkaBrokerActiveConnections.WithLabelValues(b.id, b.addr).Inc()
+	t.setStatus(b, 1)
+	t.mu.Lock()
+	reconnected := t.lost[b.key()]
+	delete(t.lost, b.key())
+	t.mu.Unlock()
+	if reconnected {
+		t.metrics.KafkaReconnectionsTotal.WithLabelValues(b.id, b.addr).Inc()
+		t.logger.Info("Kafka broker connection restored", "broker_id", b.id, "broker", b.addr)
+	}
+}
+
+// failed records a failed dial.
+func (t *Tracker) failed(b brokerRef, err error) {
+	t.metrics.KafkaBrokerConnectionFailures.WithLabelValues(b.id, b.addr).Inc()
+	t.markLost(b, "Kafka broker connection failed", err)
+}
+
+// requestError records an I/O error on an open connection.
+func (t *Tracker) requestError(b brokerRef, reason string, err error) {
+	t.metrics.KafkaBrokerRequestErrors.WithLabelValues(b.id, b.addr, reason).Inc()
+	t.markLost(b, "Kafka broker connection lost", err)
+}
+
+func (t *Tracker) markLost(b brokerRef, msg string, err error) {
+	t.setStatus(b, 0)
+	t.mu.Lock()
+	first := !t.lost[b.key()]
+	t.lost[b.key()] = true
+	t.mu.Unlock()
+	// Log the first failure only; the client retries and would repeat it many times.
+	if first {
+		t.logger.Warn(msg, "broker_id", b.id, "broker", b.addr, "error", err)
+	}
+}
+
+// setStatus sets kafka_connection_status of b. Connections to a bootstrap address not yet
+// attributed to a broker are left out: the bootstrap checks report those addresses.
+func (t *Tracker) setStatus(b brokerRef, value float64) {
+	if b.id != unknownBrokerID {
+		t.metrics.KafkaConnectionStatus.WithLabelValues(b.id, b.addr).Set(value)
+	}
+}
+
+func (t *Tracker) closed(b brokerRef, failed bool) {
+	reason := "normal"
+	if failed {
+		reason = "error"
+	}
+	t.metrics.KafkaBrokerConnectionsClosed.WithLabelValues(b.id, b.addr, reason).Inc()
+	t.metrics.KafkaBrokerActiveConnections.WithLabelValues(b.id, b.addr).Dec()
+}
+
+// trackedConn reports I/O errors and the close of a broker connection to its Tracker.
+type trackedConn struct {
+	net.Conn
+	tracker *Tracker
+	broker  brokerRef
+
+	closing   atomic.Bool
+	failed    atomic.Bool
+	closeOnce sync.Once
+}
+
+func (c *trackedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if err != nil {
+		c.ioError(err)
+	}
+	return n, err
+}
+
+func (c *trackedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if err != nil {
+		c.ioError(err)
+	}
+	return n, err
+}
+
+func (c *trackedConn) Close() error {
+	c.closing.Store(true)
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() { c.tracker.closed(c.broker, c.failed.Load()) })
+	return err
+}
+
+func (c *trackedConn) ioError(err error) {
+	// Reads that fail because the client closed the connection itself are not errors.
+	if c.closing.Load() {
+		return
+	}
+	c.failed.Store(true)
+	c.tracker.requestError(c.broker, errorReason(err), err)
+}
+
+// errorReason classifies a connection I/O error for kafka_broker_request_errors_total.
+func errorReason(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "eof"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, net.ErrClosed):
+		return "reset"
+	default:
+		return "other"
+	}
+}
+
+func brokerAddr(b kafka.Broker) string {
+	return net.JoinHostPort(b.Host, strconv.Itoa(b.Port))
+}
+
+func brokerAddrs(brokers []kafka.Broker) []string {
+	addrs := make([]string, 0, len(brokers))
+	for _, b := range brokers {
+		addrs = append(addrs, strconv.Itoa(b.ID)+"="+brokerAddr(b))
+	}
+	return addrs
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// exemplarMaxRunes is the OpenMetrics limit for the combined length of the label names and
+// values of an exemplar; client_golang panics beyond it.
+const exemplarMaxRunes = 128
+
+// Exemplar identifies the message behind a histogram observation. Empty fields are omitted.
+type Exemplar struct {
+	TraceID   string
+	Key       string
+	Partition string
+	Offset    string
+}
+
+// labels returns the exemplar labels in order of importance, leaving out those that would
+// exceed the length limit or are not valid UTF-8 (Kafka keys are arbitrary bytes).
+func (e Exemplar) labels() prometheus.Labels {
+	labels := prometheus.Labels{}
+	budget := exemplarMaxRunes
+	add := func(name, value string) {
+		if value == "" || !utf8.ValidString(value) {
+			return
+		}
+		n := utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+		if n > budget {
+			return
+		}
+		labels[name] = value
+		budget -= n
+	}
+	add("trace_id", e.TraceID)
+	add("key", e.Key)
+	add("partition", e.Partition)
+	add("offset", e.Offset)
+	return labels
+}
+
+// ObserveWithExemplar observes value on o and attaches e as its exemplar, so that a latency
+// spike can be followed to the message (and trace) that caused it.
+func ObserveWithExemplar(o prometheus.Observer, value float64, e Exemplar) {
+	if eo, ok := o.(prometheus.ExemplarObserver); ok {
+		if labels := e.labels(); len(labels) > 0 {
+			eo.ObserveWithExemplar(value, labels)
+			return
+		}
+	}
+	o.Observe(value)
+}
+
+// Handler serves the default registry like promhttp.Handler, with OpenMetrics negotiated
+// for scrapers that accept it: only the OpenMetrics format carries exemplars.
+func Handler() http.Handler {
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+}
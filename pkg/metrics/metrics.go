@@ -0,0 +1,1070 @@
+// Package metrics defines the Prometheus metrics of the producer, consumer, delivery
+// verification and fault injectors.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics holds every collector. Components receive it through their constructors, so an
+// embedding test suite can register the metrics on its own registry.
+type Metrics struct {
+	// Producer metrics
+	ProducerMessagesSentTotal     *prometheus.CounterVec
+	ProducerMessagesSentBytes     *prometheus.CounterVec
+	ProducerMessageSendDuration   *prometheus.HistogramVec
+	ProducerMessageEncodeDuration *prometheus.HistogramVec
+	ProducerErrorsTotal           *prometheus.CounterVec
+	ProducerSendErrorsTotal       *prometheus.CounterVec
+
+	// Consumer metrics
+	ConsumerMessagesReceivedTotal        *prometheus.CounterVec
+	ConsumerMessagesReceivedBytes        *prometheus.CounterVec
+	ConsumerMessageProcessingDuration    *prometheus.HistogramVec
+	ConsumerMessageDecodeDuration        *prometheus.HistogramVec
+	ConsumerEndToEndLatency              *prometheus.HistogramVec
+	ConsumerProduceToBrokerLatency       *prometheus.HistogramVec
+	ConsumerBrokerToConsumeLatency       *prometheus.HistogramVec
+	ConsumerBrokerClockOffset            *prometheus.GaugeVec
+	ConsumerBrokerClockOffsetUncertainty *prometheus.GaugeVec
+	ConsumerErrorsTotal                  *prometheus.CounterVec
+	ConsumerReadErrorsTotal              *prometheus.CounterVec
+	ConsumerWorkerQueueDepth             *prometheus.GaugeVec
+	ConsumerWorkerBusySeconds            *prometheus.CounterVec
+	ConsumerPanicsRecoveredTotal         *prometheus.CounterVec
+
+	// Simulated processing behaviour (slow/flaky downstream)
+	ConsumerSimulatedDelay          *prometheus.HistogramVec
+	ConsumerProcessingRetriesTotal  *prometheus.CounterVec
+	ConsumerProcessingFailuresTotal *prometheus.CounterVec
+	ConsumerSimulatedPausesTotal    *prometheus.CounterVec
+	ConsumerCommittedOffset         *prometheus.GaugeVec
+	ConsumerLag                     *prometheus.GaugeVec
+	ConsumerRebalancesTotal         *prometheus.CounterVec
+	ConsumerRebalanceDuration       *prometheus.HistogramVec
+	ConsumerGroupGeneration         *prometheus.GaugeVec
+	ConsumerAssignedPartitions      *prometheus.GaugeVec
+
+	// Schema Registry metrics
+	SchemaRegistryRequestsTotal       *prometheus.CounterVec
+	SchemaRegistryRequestDuration     *prometheus.HistogramVec
+	SchemaRegistryErrorsTotal         *prometheus.CounterVec
+	SchemaRegistryHTTPRequestsTotal   *prometheus.CounterVec
+	SchemaRegistryHTTPRetriesTotal    *prometheus.CounterVec
+	SchemaRegistryFaultActive         prometheus.Gauge
+	SchemaRegistryFaultsInjectedTotal *prometheus.CounterVec
+
+	// Connection metrics
+	KafkaConnectionStatus   *prometheus.GaugeVec
+	KafkaBootstrapStatus    *prometheus.GaugeVec
+	KafkaReconnectionsTotal *prometheus.CounterVec
+
+	// Per-broker client connections, attributed to node ID and advertised address
+	KafkaBrokerConnectionsOpened  *prometheus.CounterVec
+	KafkaBrokerConnectionsClosed  *prometheus.CounterVec
+	KafkaBrokerConnectionFailures *prometheus.CounterVec
+	KafkaBrokerRequestErrors      *prometheus.CounterVec
+	KafkaBrokerActiveConnections  *prometheus.GaugeVec
+	KafkaBrokerInfo               *prometheus.GaugeVec
+	KafkaBootstrapBroker          *prometheus.GaugeVec
+
+	// TLS certificates of broker connections (KAFKA_TLS_*)
+	KafkaTLSCertificateExpiry *prometheus.GaugeVec
+	KafkaTLSReloadsTotal      *prometheus.CounterVec
+	KafkaTLSVerifyErrorsTotal *prometheus.CounterVec
+
+	// SASL authentication and OAUTHBEARER tokens (KAFKA_SASL_MECHANISM, KAFKA_OAUTH_*)
+	KafkaSASLAuthenticationsTotal   *prometheus.CounterVec
+	KafkaSASLReauthenticationsTotal *prometheus.CounterVec
+	KafkaOAuthTokenRequestsTotal    *prometheus.CounterVec
+	KafkaOAuthTokenExpiry           prometheus.Gauge
+
+	// Cluster health seen through the admin API (inspector mode)
+	KafkaClusterBrokers                 prometheus.Gauge
+	KafkaClusterControllerID            prometheus.Gauge
+	KafkaClusterPartitions              *prometheus.GaugeVec
+	KafkaClusterUnderReplicated         *prometheus.GaugeVec
+	KafkaClusterOfflinePartitions       *prometheus.GaugeVec
+	KafkaClusterUnderMinISR             *prometheus.GaugeVec
+	KafkaClusterNonPreferredLeaders     *prometheus.GaugeVec
+	KafkaClusterLeaderChangesTotal      *prometheus.CounterVec
+	KafkaClusterPartitionLeader         *prometheus.GaugeVec
+	KafkaClusterPartitionReplicas       *prometheus.GaugeVec
+	KafkaClusterPartitionISR            *prometheus.GaugeVec
+	KafkaClusterLeaderPartitions        *prometheus.GaugeVec
+	KafkaClusterLeaderSkew              prometheus.Gauge
+	KafkaClusterMetadataErrorsTotal     *prometheus.CounterVec
+	KafkaClusterLastInspectionTimestamp prometheus.Gauge
+
+	// Client-side fault injection (KAFKA_FAULT_INJECTION)
+	KafkaFaultActive               *prometheus.GaugeVec
+	KafkaFaultsInjectedTotal       *prometheus.CounterVec
+	SchemaRegistryConnectionStatus prometheus.Gauge
+
+	// Redis delivery verification and SLO (body = id+data; timestamp difference is not counted as mismatch)
+	ConsumerRedisHashMismatchTotal *prometheus.CounterVec
+	RedisVerificationSpooledTotal  *prometheus.CounterVec
+	RedisVerificationReplayedTotal *prometheus.CounterVec
+	RedisVerificationDroppedTotal  *prometheus.CounterVec
+	RedisVerificationSpoolSize     prometheus.Gauge
+	RedisFaultActive               prometheus.Gauge
+	RedisFaultsInjectedTotal       *prometheus.CounterVec
+	RedisPendingMessages           prometheus.Gauge
+	RedisPendingOldMessages        prometheus.Gauge
+
+	// Clock offset from the reference clock (Redis server time)
+	ClockOffset          prometheus.Gauge
+	ClockSyncUncertainty prometheus.Gauge
+	ClockSyncErrorsTotal prometheus.Counter
+
+	// Delivery SLOs: events, SLI, error budget and burn rates per objective
+	SLOEventsTotal               *prometheus.CounterVec
+	SLOTarget                    *prometheus.GaugeVec
+	SLOIndicator                 *prometheus.GaugeVec
+	SLOBurnRate                  *prometheus.GaugeVec
+	SLOErrorBudgetRemaining      *prometheus.GaugeVec
+	SLOAlertFiring               *prometheus.GaugeVec
+	SLOWebhookNotificationsTotal *prometheus.CounterVec
+
+	// Chaos experiments active during the run
+	ChaosExperimentInfo   *prometheus.GaugeVec
+	ChaosAnnotationsTotal *prometheus.CounterVec
+	// Chaos Mesh resource watcher
+	ChaosMeshEventsTotal      *prometheus.CounterVec
+	ChaosMeshWatchErrorsTotal *prometheus.CounterVec
+	// Chaos abort guard
+	ChaosGuardTripped           prometheus.Gauge
+	ChaosGuardConditionBreached *prometheus.GaugeVec
+	ChaosGuardAbortsTotal       *prometheus.CounterVec
+	ChaosGuardQueryErrorsTotal  *prometheus.CounterVec
+	ChaosGuardDeletedTotal      *prometheus.CounterVec
+	// Time to recover after experiments end
+	RecoveryTime          *prometheus.GaugeVec
+	RecoveryDuration      *prometheus.HistogramVec
+	RecoveryTimeoutsTotal *prometheus.CounterVec
+	RecoveryMeasuring     prometheus.Gauge
+
+	// Dependency health checks
+	DependencyUp            *prometheus.GaugeVec
+	DependencyCheckDuration *prometheus.HistogramVec
+
+	// Runtime control API (CONTROL_TOKEN)
+	ControlChangesTotal    *prometheus.CounterVec
+	ControlPaused          prometheus.Gauge
+	ControlActiveTopic     *prometheus.GaugeVec
+	ProducerTargetRate     prometheus.Gauge
+	ProducerPayloadProfile *prometheus.GaugeVec
+}
+
+// New creates the metrics and registers them on reg (prometheus.DefaultRegisterer for the
+// binary). A nil reg creates unregistered collectors.
+func New(reg prometheus.Registerer) *Metrics {
+	f := promauto.With(reg)
+	return &Metrics{
+		// Producer metrics
+		ProducerMessagesSentTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_producer_messages_sent_total",
+				Help: "Total number of messages sent by producer",
+			},
+			[]string{"topic"},
+		),
+
+		ProducerMessagesSentBytes: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_producer_messages_sent_bytes_total",
+				Help: "Total bytes sent by producer",
+			},
+			[]string{"topic"},
+		),
+
+		ProducerMessageSendDuration: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "kafka_producer_message_send_duration_seconds",
+				Help:    "Duration of sending a message (from creation to Kafka acknowledgment)",
+				Buckets: prometheus.ExponentialBuckets(0.001, 2, 10), // 1ms to ~1s
+			},
+			[]string{"topic"},
+		),
+
+		ProducerMessageEncodeDuration: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "kafka_producer_message_encode_duration_seconds",
+				Help:    "Duration of encoding a message to Avro format",
+				Buckets: prometheus.ExponentialBuckets(0.0001, 2, 10), // 0.1ms to ~100ms
+			},
+			[]string{"topic"},
+		),
+
+		ProducerErrorsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_producer_errors_total",
+				Help: "Total number of producer errors",
+			},
+			// error_type: encode, send, connection; experiment: active chaos experiments ("none")
+			[]string{"topic", "error_type", "experiment"},
+		),
+
+		ProducerSendErrorsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_producer_send_errors_total",
+				Help: "Total number of failed message writes by Kafka error",
+			},
+			// error: Kafka error title in snake_case (not_enough_replicas, ...) or timeout, network, ...;
+			// code: Kafka error code (0 = not a protocol error); partition: "unknown" when not assigned
+			[]string{"topic", "partition", "error", "code", "retriable", "experiment"},
+		),
+
+		// Consumer metrics
+		ConsumerMessagesReceivedTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_consumer_messages_received_total",
+				Help: "Total number of messages received by consumer",
+			},
+			[]string{"topic", "partition"},
+		),
+
+		ConsumerMessagesReceivedBytes: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_consumer_messages_received_bytes_total",
+				Help: "Total bytes received by consumer",
+			},
+			[]string{"topic", "partition"},
+		),
+
+		ConsumerMessageProcessingDuration: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "kafka_consumer_message_processing_duration_seconds",
+				Help:    "Duration of processing a message (from receiving to completion)",
+				Buckets: prometheus.ExponentialBuckets(0.001, 2, 10), // 1ms to ~1s
+			},
+			[]string{"topic", "partition"},
+		),
+
+		ConsumerMessageDecodeDuration: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "kafka_consumer_message_decode_duration_seconds",
+				Help:    "Duration of decoding a message from Avro format",
+				Buckets: prometheus.ExponentialBuckets(0.0001, 2, 10), // 0.1ms to ~100ms
+			},
+			[]string{"topic", "partition"},
+		),
+
+		ConsumerEndToEndLatency: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "kafka_consumer_end_to_end_latency_seconds",
+				Help:    "End-to-end latency from message creation (timestamp) to consumption, corrected for the producer and consumer clock offsets",
+				Buckets: prometheus.ExponentialBuckets(0.01, 2, 12), // 10ms to ~40s
+			},
+			[]string{"topic", "partition"},
+		),
+
+		ConsumerProduceToBrokerLatency: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "kafka_consumer_produce_to_broker_latency_seconds",
+				Help:    "Latency from message creation to the broker append (LogAppendTime), in reference time; includes the broker clock offset",
+				Buckets: prometheus.ExponentialBuckets(0.001, 2, 16), // 1ms to ~33s
+			},
+			[]string{"topic", "partition"},
+		),
+
+		ConsumerBrokerToConsumeLatency: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "kafka_consumer_broker_to_consume_latency_seconds",
+				Help:    "Latency from the broker append (LogAppendTime) to consumption, in reference time; less the broker clock offset",
+				Buckets: prometheus.ExponentialBuckets(0.001, 2, 16), // 1ms to ~33s
+			},
+			[]string{"topic", "partition"},
+		),
+
+		ConsumerBrokerClockOffset: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_consumer_broker_clock_offset_seconds",
+				Help: "Estimated offset of the partition leader's clock from the reference clock (positive = ahead), the middle of the range the one-way delays allow",
+			},
+			[]string{"topic", "partition"},
+		),
+
+		ConsumerBrokerClockOffsetUncertainty: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_consumer_broker_clock_offset_uncertainty_seconds",
+				Help: "Largest error of kafka_consumer_broker_clock_offset_seconds: half the range of offsets the one-way delays allow",
+			},
+			[]string{"topic", "partition"},
+		),
+
+		ConsumerErrorsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_consumer_errors_total",
+				Help: "Total number of consumer errors",
+			},
+			// error_type: read, decode, commit, connection; experiment: see kafka_producer_errors_total
+			[]string{"topic", "error_type", "experiment"},
+		),
+
+		ConsumerReadErrorsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_consumer_read_errors_total",
+				Help: "Total number of failed message reads by Kafka error",
+			},
+			[]string{"topic", "error", "code", "retriable", "experiment"}, // see kafka_producer_send_errors_total
+		),
+
+		ConsumerWorkerQueueDepth: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_consumer_worker_queue_depth",
+				Help: "Number of fetched messages waiting in a consumer worker queue",
+			},
+			[]string{"topic", "worker"},
+		),
+
+		ConsumerWorkerBusySeconds: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_consumer_worker_busy_seconds_total",
+				Help: "Time a consumer worker spent processing messages; rate() gives worker utilization",
+			},
+			[]string{"topic", "worker"},
+		),
+
+		ConsumerPanicsRecoveredTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_consumer_panics_recovered_total",
+				Help: "Total number of panics recovered in consumer workers",
+			},
+			[]string{"topic", "worker"},
+		),
+
+		// Simulated processing behaviour (slow/flaky downstream)
+		ConsumerSimulatedDelay: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "kafka_consumer_simulated_delay_seconds",
+				Help:    "Artificial processing delay added to a message",
+				Buckets: prometheus.ExponentialBuckets(0.001, 2, 14), // 1ms to ~8s
+			},
+			[]string{"topic", "partition"},
+		),
+
+		ConsumerProcessingRetriesTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_consumer_processing_retries_total",
+				Help: "Total number of retried processing attempts after a simulated error",
+			},
+			[]string{"topic", "partition"},
+		),
+
+		ConsumerProcessingFailuresTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_consumer_processing_failures_total",
+				Help: "Total number of messages dropped after exhausting processing retries",
+			},
+			[]string{"topic", "partition"},
+		),
+
+		ConsumerSimulatedPausesTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_consumer_simulated_pauses_total",
+				Help: "Total number of simulated poll stalls that left the consumer group",
+			},
+			[]string{"topic"},
+		),
+
+		ConsumerCommittedOffset: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_consumer_committed_offset",
+				Help: "Last offset committed by the consumer (end of the contiguous processed range)",
+			},
+			[]string{"topic", "partition"},
+		),
+
+		ConsumerLag: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_consumer_lag",
+				Help: "Consumer lag (difference between latest offset and consumer offset)",
+			},
+			[]string{"topic", "partition", "group_id"},
+		),
+
+		ConsumerRebalancesTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_consumer_rebalances_total",
+				Help: "Total number of completed consumer group rebalances",
+			},
+			[]string{"topic", "group_id"},
+		),
+
+		ConsumerRebalanceDuration: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "kafka_consumer_rebalance_duration_seconds",
+				Help:    "Duration of a consumer group rebalance (from end of previous generation to partition assignment)",
+				Buckets: prometheus.ExponentialBuckets(0.1, 2, 10), // 100ms to ~50s
+			},
+			[]string{"topic", "group_id"},
+		),
+
+		ConsumerGroupGeneration: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_consumer_group_generation_id",
+				Help: "Current consumer group generation ID",
+			},
+			[]string{"topic", "group_id"},
+		),
+
+		ConsumerAssignedPartitions: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_consumer_assigned_partitions",
+				Help: "Partitions currently assigned to this consumer (1 = assigned)",
+			},
+			[]string{"topic", "group_id", "partition"},
+		),
+
+		// Schema Registry metrics
+		SchemaRegistryRequestsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "schema_registry_requests_total",
+				Help: "Total number of Schema Registry API requests",
+			},
+			[]string{"operation"}, // operation: get_schema, get_latest_schema, create_schema
+		),
+
+		SchemaRegistryRequestDuration: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "schema_registry_request_duration_seconds",
+				Help:    "Duration of Schema Registry API requests",
+				Buckets: prometheus.ExponentialBuckets(0.001, 2, 10), // 1ms to ~1s
+			},
+			[]string{"operation"},
+		),
+
+		SchemaRegistryErrorsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "schema_registry_errors_total",
+				Help: "Total number of Schema Registry errors",
+			},
+			[]string{"operation", "error_type"}, // error_type: timeout, not_found, invalid_schema, network
+		),
+
+		SchemaRegistryHTTPRequestsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "schema_registry_http_requests_total",
+				Help: "Total number of Schema Registry HTTP attempts by status code (error = transport error)",
+			},
+			[]string{"method", "status"},
+		),
+
+		SchemaRegistryHTTPRetriesTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "schema_registry_http_retries_total",
+				Help: "Total number of retried Schema Registry HTTP requests",
+			},
+			[]string{"method"},
+		),
+
+		SchemaRegistryFaultActive: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "schema_registry_fault_injection_active",
+				Help: "Client-side Schema Registry fault currently active (1 = active)",
+			},
+		),
+
+		SchemaRegistryFaultsInjectedTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "schema_registry_faults_injected_total",
+				Help: "Total number of faults injected into Schema Registry calls",
+			},
+			[]string{"fault"}, // fault: delay, drop, status_<code>
+		),
+
+		// Connection metrics
+		KafkaConnectionStatus: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_connection_status",
+				Help: "Kafka broker connection status (1 = connected, 0 = disconnected)",
+			},
+			[]string{"broker_id", "broker"},
+		),
+
+		KafkaBootstrapStatus: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_bootstrap_status",
+				Help: "Result of the last metadata request through a bootstrap address (1 = ok, 0 = failed)",
+			},
+			[]string{"bootstrap"},
+		),
+
+		KafkaReconnectionsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_reconnections_total",
+				Help: "Total number of connections opened to a broker after its connection was lost",
+			},
+			[]string{"broker_id", "broker"},
+		),
+
+		KafkaBrokerConnectionsOpened: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_broker_connections_opened_total",
+				Help: "Total number of client connections opened to a Kafka broker",
+			},
+			[]string{"broker_id", "broker"},
+		),
+
+		KafkaBrokerConnectionsClosed: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_broker_connections_closed_total",
+				Help: "Total number of client connections to a Kafka broker closed",
+			},
+			[]string{"broker_id", "broker", "reason"}, // reason: normal, error
+		),
+
+		KafkaBrokerConnectionFailures: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_broker_connection_failures_total",
+				Help: "Total number of failed connection attempts to a Kafka broker",
+			},
+			[]string{"broker_id", "broker"},
+		),
+
+		KafkaBrokerRequestErrors: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_broker_request_errors_total",
+				Help: "Total number of I/O errors on open connections to a Kafka broker",
+			},
+			[]string{"broker_id", "broker", "reason"}, // reason: timeout, eof, reset, other
+		),
+
+		KafkaBrokerActiveConnections: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_broker_active_connections",
+				Help: "Number of open client connections to a Kafka broker",
+			},
+			[]string{"broker_id", "broker"},
+		),
+
+		KafkaBrokerInfo: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_broker_info",
+				Help: "Kafka brokers discovered from metadata (always 1)",
+			},
+			[]string{"broker_id", "broker", "rack"},
+		),
+
+		KafkaBootstrapBroker: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_bootstrap_broker",
+				Help: "Broker last reached through a bootstrap address (always 1)",
+			},
+			[]string{"bootstrap", "broker_id", "broker"},
+		),
+
+		// TLS certificates of broker connections
+		KafkaTLSCertificateExpiry: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_tls_certificate_expiry_timestamp_seconds",
+				Help: "Expiry time of the loaded TLS certificates, the earliest of the CA bundle",
+			},
+			[]string{"certificate"}, // certificate: ca, client
+		),
+
+		KafkaTLSReloadsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_tls_reloads_total",
+				Help: "Total number of reloads of changed TLS certificate files",
+			},
+			[]string{"certificate", "result"}, // result: success, failed
+		),
+
+		KafkaTLSVerifyErrorsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_tls_verify_errors_total",
+				Help: "Total number of broker certificates rejected by the TLS verification",
+			},
+			[]string{"reason"}, // reason: unknown_authority, expired, hostname, invalid
+		),
+
+		// SASL authentication and OAUTHBEARER tokens
+		KafkaSASLAuthenticationsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_sasl_authentications_total",
+				Help: "Total number of SASL authentications with brokers by result",
+			},
+			// result: success, token_unavailable, rejected (by the broker), failed
+			[]string{"mechanism", "result"},
+		),
+
+		KafkaSASLReauthenticationsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_sasl_reauthentications_total",
+				Help: "Total number of successful SASL authentications with a broker that had authenticated the client before",
+			},
+			[]string{"mechanism", "broker"},
+		),
+
+		KafkaOAuthTokenRequestsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_oauth_token_requests_total",
+				Help: "Total number of OAuth token requests to the token endpoint",
+			},
+			[]string{"result"}, // result: success, failed
+		),
+
+		KafkaOAuthTokenExpiry: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "kafka_oauth_token_expiry_timestamp_seconds",
+				Help: "Expiry time of the current OAuth access token",
+			},
+		),
+
+		// Cluster health seen through the admin API (inspector mode)
+		KafkaClusterBrokers: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "kafka_cluster_brokers",
+				Help: "Number of live brokers in the cluster metadata",
+			},
+		),
+
+		KafkaClusterControllerID: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "kafka_cluster_controller_id",
+				Help: "Controller node ID reported by the cluster metadata (-1 = none)",
+			},
+		),
+
+		KafkaClusterPartitions: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_cluster_partitions",
+				Help: "Number of partitions of an inspected topic",
+			},
+			[]string{"topic"},
+		),
+
+		KafkaClusterUnderReplicated: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_cluster_under_replicated_partitions",
+				Help: "Number of partitions whose ISR is smaller than the replica set",
+			},
+			[]string{"topic"},
+		),
+
+		KafkaClusterOfflinePartitions: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_cluster_offline_partitions",
+				Help: "Number of partitions without a leader",
+			},
+			[]string{"topic"},
+		),
+
+		KafkaClusterUnderMinISR: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_cluster_under_min_isr_partitions",
+				Help: "Number of partitions whose ISR is smaller than min.insync.replicas of the topic (acks=all writes fail)",
+			},
+			[]string{"topic"},
+		),
+
+		KafkaClusterNonPreferredLeaders: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_cluster_non_preferred_leaders",
+				Help: "Number of partitions led by a broker other than their preferred (first) replica",
+			},
+			[]string{"topic"},
+		),
+
+		KafkaClusterLeaderChangesTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_cluster_leader_changes_total",
+				Help: "Total number of partition leader changes seen between inspections",
+			},
+			[]string{"topic"},
+		),
+
+		KafkaClusterPartitionLeader: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_cluster_partition_leader",
+				Help: "Node ID of the partition leader (-1 = offline)",
+			},
+			[]string{"topic", "partition"},
+		),
+
+		KafkaClusterPartitionReplicas: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_cluster_partition_replicas",
+				Help: "Number of replicas assigned to the partition",
+			},
+			[]string{"topic", "partition"},
+		),
+
+		KafkaClusterPartitionISR: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_cluster_partition_isr",
+				Help: "Number of in-sync replicas of the partition",
+			},
+			[]string{"topic", "partition"},
+		),
+
+		KafkaClusterLeaderPartitions: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_cluster_leader_partitions",
+				Help: "Number of inspected partitions led by a broker",
+			},
+			[]string{"broker_id"},
+		),
+
+		KafkaClusterLeaderSkew: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "kafka_cluster_leader_skew",
+				Help: "Leader distribution skew: leaders of the busiest broker divided by the even share, minus 1 (0 = balanced)",
+			},
+		),
+
+		KafkaClusterMetadataErrorsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_cluster_metadata_errors_total",
+				Help: "Total number of failed cluster inspections",
+			},
+			[]string{"error"}, // error: see kafka_producer_send_errors_total
+		),
+
+		KafkaClusterLastInspectionTimestamp: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "kafka_cluster_last_inspection_timestamp_seconds",
+				Help: "Unix time of the last successful cluster inspection",
+			},
+		),
+
+		// Client-side fault injection (KAFKA_FAULT_INJECTION)
+		KafkaFaultActive: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_fault_injection_active",
+				Help: "Client-side Kafka fault rule currently active (1 = active); broker is an address or *",
+			},
+			[]string{"broker"},
+		),
+
+		KafkaFaultsInjectedTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_faults_injected_total",
+				Help: "Total number of client-side faults applied to Kafka connections",
+			},
+			[]string{"broker", "fault"}, // fault: latency, reset, blackhole_dial, blackhole_read, blackhole_write
+		),
+
+		SchemaRegistryConnectionStatus: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "schema_registry_connection_status",
+				Help: "Schema Registry connection status (1 = connected, 0 = disconnected)",
+			},
+		),
+
+		// Redis delivery verification and SLO (body = id+data; timestamp difference is not counted as mismatch)
+		ConsumerRedisHashMismatchTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_consumer_redis_hash_mismatch_total",
+				Help: "Total number of messages where message body (id+data) did not match Redis - data integrity issue",
+			},
+			[]string{"topic", "partition"},
+		),
+
+		RedisVerificationSpooledTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "redis_verification_spooled_total",
+				Help: "Total number of verification records spooled after a Redis failure",
+			},
+			[]string{"op"}, // op: set (producer), verify (consumer)
+		),
+
+		RedisVerificationReplayedTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "redis_verification_replayed_total",
+				Help: "Total number of spooled verification records successfully replayed to Redis",
+			},
+			[]string{"op"},
+		),
+
+		RedisVerificationDroppedTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "redis_verification_dropped_total",
+				Help: "Total number of verification records lost by the side-channel (spool full or disabled) - not Kafka data loss",
+			},
+			[]string{"op"},
+		),
+
+		RedisVerificationSpoolSize: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "redis_verification_spool_size",
+				Help: "Number of verification records waiting in the spool",
+			},
+		),
+
+		RedisFaultActive: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "redis_fault_injection_active",
+				Help: "Client-side Redis fault currently active (1 = active)",
+			},
+		),
+
+		RedisFaultsInjectedTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "redis_faults_injected_total",
+				Help: "Total number of faults injected into Redis commands",
+			},
+			[]string{"fault"}, // fault: delay, error
+		),
+
+		RedisPendingMessages: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "redis_pending_messages",
+				Help: "Number of message keys still in Redis (sent but not yet consumed)",
+			},
+		),
+
+		RedisPendingOldMessages: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "redis_pending_old_messages",
+				Help: "Number of pending messages older than SLO threshold (delivery SLO breach)",
+			},
+		),
+
+		// Clock offset from the reference clock (Redis server time)
+		ClockOffset: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "app_clock_offset_seconds",
+				Help: "Offset of the local clock from the Redis server clock (positive = ahead)",
+			},
+		),
+
+		ClockSyncUncertainty: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "app_clock_sync_uncertainty_seconds",
+				Help: "Uncertainty of the clock offset measurement (half the Redis TIME round trip)",
+			},
+		),
+
+		ClockSyncErrorsTotal: f.NewCounter(
+			prometheus.CounterOpts{
+				Name: "app_clock_sync_errors_total",
+				Help: "Total number of failed clock offset measurements",
+			},
+		),
+
+		// Delivery SLOs
+		SLOEventsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "app_slo_events_total",
+				Help: "Total number of events counted towards a delivery objective",
+			},
+			[]string{"objective", "result"}, // objective: delivery_latency, zero_loss; result: good, bad, corrected
+		),
+
+		SLOTarget: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "app_slo_target_ratio",
+				Help: "Target ratio of good events of a delivery objective",
+			},
+			[]string{"objective"},
+		),
+
+		SLOIndicator: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "app_slo_sli_ratio",
+				Help: "Ratio of good events of a delivery objective over a rolling window (1 without events)",
+			},
+			[]string{"objective", "window"},
+		),
+
+		SLOBurnRate: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "app_slo_burn_rate",
+				Help: "Rate at which the error budget is spent over a rolling window (1 = spent exactly by the end of the SLO window)",
+			},
+			[]string{"objective", "window"},
+		),
+
+		SLOErrorBudgetRemaining: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "app_slo_error_budget_remaining_ratio",
+				Help: "Share of the error budget of the SLO window not yet spent (negative when exceeded)",
+			},
+			[]string{"objective"},
+		),
+
+		SLOAlertFiring: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "app_slo_alert_firing",
+				Help: "Multi-window burn-rate alert state (1 = firing)",
+			},
+			[]string{"objective", "alert", "severity"},
+		),
+
+		SLOWebhookNotificationsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "app_slo_webhook_notifications_total",
+				Help: "Total number of SLO alert notifications sent to the webhook",
+			},
+			[]string{"status", "result"}, // status: firing, resolved; result: sent, failed
+		),
+
+		// Chaos experiments
+		ChaosExperimentInfo: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "app_chaos_experiment_info",
+				Help: "Chaos experiment active while the series is present (value 1)",
+			},
+			[]string{"experiment", "kind", "namespace", "source"}, // source: env, api, chaos-mesh
+		),
+
+		ChaosAnnotationsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "app_chaos_annotations_total",
+				Help: "Total number of experiment annotations pushed",
+			},
+			[]string{"target", "result"}, // target: grafana, victoriametrics; result: sent, failed
+		),
+
+		ChaosMeshEventsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "app_chaos_mesh_events_total",
+				Help: "Total number of Chaos Mesh injection lifecycle events observed",
+			},
+			[]string{"kind", "namespace", "event"}, // event: injected, recovered, deleted
+		),
+
+		ChaosMeshWatchErrorsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "app_chaos_mesh_watch_errors_total",
+				Help: "Total number of failed list or watch requests for Chaos Mesh resources",
+			},
+			[]string{"kind", "namespace"},
+		),
+
+		// Chaos abort guard
+		ChaosGuardTripped: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "app_chaos_guard_tripped",
+				Help: "Chaos aborted by the guard and blocked until reset (1 = tripped)",
+			},
+		),
+
+		ChaosGuardConditionBreached: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "app_chaos_guard_condition_breached",
+				Help: "Abort condition holding at the last evaluation (1 = breached)",
+			},
+			[]string{"condition"},
+		),
+
+		ChaosGuardAbortsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "app_chaos_guard_aborts_total",
+				Help: "Total number of chaos aborts by the guard",
+			},
+			[]string{"condition"},
+		),
+
+		ChaosGuardQueryErrorsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "app_chaos_guard_query_errors_total",
+				Help: "Total number of failed evaluations of abort conditions",
+			},
+			[]string{"condition"},
+		),
+
+		ChaosGuardDeletedTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "app_chaos_guard_deleted_total",
+				Help: "Total number of Chaos Mesh resources deleted by the guard",
+			},
+			[]string{"kind", "result"}, // result: deleted, failed
+		),
+
+		// Time to recover after experiments end
+		RecoveryTime: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "app_recovery_time_seconds",
+				Help: "Time from the end of the last fault of an experiment until a signal recovered",
+			},
+			// signal: producer_errors, consumer_errors, consumer_lag, pending_messages, partitions or all (the slowest)
+			[]string{"experiment", "signal"},
+		),
+
+		RecoveryDuration: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "app_recovery_duration_seconds",
+				Help:    "Distribution of the time to recover after a fault ends",
+				Buckets: prometheus.ExponentialBuckets(1, 2, 13), // 1s to ~68m
+			},
+			[]string{"signal"},
+		),
+
+		RecoveryTimeoutsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "app_recovery_timeouts_total",
+				Help: "Total number of signals that did not recover within the recovery timeout",
+			},
+			[]string{"signal"},
+		),
+
+		RecoveryMeasuring: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "app_recovery_measurements_active",
+				Help: "Number of recovery measurements in progress",
+			},
+		),
+
+		// Dependency health checks
+		DependencyUp: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "app_dependency_up",
+				Help: "Result of the last health check of a dependency (1 = up, 0 = down)",
+			},
+			[]string{"dependency", "critical"},
+		),
+
+		DependencyCheckDuration: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "app_dependency_check_duration_seconds",
+				Help:    "Duration of dependency health checks",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"dependency"},
+		),
+
+		// Runtime control API (CONTROL_TOKEN)
+		ControlChangesTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "app_control_changes_total",
+				Help: "Total number of changes applied through the runtime control API",
+			},
+			[]string{"action"}, // action: pause, resume, rate, payload, topic
+		),
+
+		ControlPaused: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "app_paused",
+				Help: "Production or consumption paused through the control API (1 = paused)",
+			},
+		),
+
+		ControlActiveTopic: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "app_active_topic",
+				Help: "Topic currently produced to or consumed from (1 = active)",
+			},
+			[]string{"topic"},
+		),
+
+		ProducerTargetRate: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "kafka_producer_target_rate",
+				Help: "Target message rate of the producer in messages per second",
+			},
+		),
+
+		ProducerPayloadProfile: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_producer_payload_profile",
+				Help: "Payload profile currently used by the producer (1 = active)",
+			},
+			[]string{"profile", "size_bytes"},
+		),
+	}
+}
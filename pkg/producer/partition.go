@@ -0,0 +1,49 @@
+package producer
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// unknownPartition labels failed writes whose partition was not chosen (e.g. metadata
+// of the topic could not be fetched) or that went through an injected Writer.
+const unknownPartition = "unknown"
+
+// partitionRecorder remembers the partition the balancer chose for each message key until
+// it is taken, so that a failed write can be attributed to its partition: kafka-go sets
+// Message.Partition only for successful writes.
+type partitionRecorder struct {
+	kafka.Balancer
+
+	mu         sync.Mutex
+	partitions map[string]int
+}
+
+func newPartitionRecorder(balancer kafka.Balancer) *partitionRecorder {
+	return &partitionRecorder{Balancer: balancer, partitions: make(map[string]int)}
+}
+
+func (r *partitionRecorder) Balance(msg kafka.Message, partitions ...int) int {
+	partition := r.Balancer.Balance(msg, partitions...)
+	r.mu.Lock()
+	r.partitions[string(msg.Key)] = partition
+	r.mu.Unlock()
+	return partition
+}
+
+// take returns and forgets the partition chosen for key as a label value.
+func (r *partitionRecorder) take(key string) string {
+	if r == nil {
+		return unknownPartition
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	partition, ok := r.partitions[key]
+	if !ok {
+		return unknownPartition
+	}
+	delete(r.partitions, key)
+	return strconv.Itoa(partition)
+}
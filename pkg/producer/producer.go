@@ -0,0 +1,370 @@
+// Package producer sends Avro test messages to Kafka at a fixed rate and records their
+// content hashes for delivery verification.
+package producer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/clock"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/codec"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/control"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/experiment"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/health"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/kafkaclient"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/tracing"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/verify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/riferrei/srclient"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Writer is the part of kafka.Writer used by the producer.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Deps holds the dependencies of a Producer. Nil clients are created from the config when
+// Run starts; injected clients are not closed.
+type Deps struct {
+	Writer         Writer
+	SchemaRegistry *srclient.SchemaRegistryClient
+	Redis          *redis.Client
+
+	// KafkaDial, SchemaRegistryTransport and RedisHooks are used for created clients
+	// (fault injection); nil keeps the defaults.
+	KafkaDial               func(ctx context.Context, network, address string) (net.Conn, error)
+	SchemaRegistryTransport http.RoundTripper
+	RedisHooks              []redis.Hook
+	// KafkaSecurity authenticates and encrypts broker connections; the zero value means
+	// plaintext without authentication.
+	KafkaSecurity kafkaclient.Security
+
+	// Control carries runtime changes of rate, payload, topic and pause; it defaults to the
+	// state given by the config.
+	Control *control.Controller
+	// Experiments labels error counters with the active chaos experiments; nil means none.
+	Experiments *experiment.Tracker
+
+	// Metrics defaults to metrics registered on a private registry, Health to a status
+	// nobody reads and Logger to slog.Default().
+	Metrics *metrics.Metrics
+	Health  *health.Status
+	Logger  *slog.Logger
+}
+
+// Producer sends a message every ProducerIntervalMs until its context is cancelled.
+type Producer struct {
+	config  *config.Config
+	deps    Deps
+	control *control.Controller
+	metrics *metrics.Metrics
+	health  *health.Status
+	logger  *slog.Logger
+
+	// partitions records the partition of each write of a created writer (nil otherwise).
+	partitions *partitionRecorder
+	// clock is the offset of the local clock sent with each message (nil without Redis).
+	clock *clock.Sync
+}
+
+func New(cfg *config.Config, deps Deps) *Producer {
+	if deps.Metrics == nil {
+		deps.Metrics = metrics.New(prometheus.NewRegistry())
+	}
+	if deps.Health == nil {
+		deps.Health = &health.Status{}
+	}
+	if deps.Logger == nil {
+		deps.Logger = slog.Default()
+	}
+	if deps.Control == nil {
+		deps.Control = control.FromConfig(cfg, deps.Metrics, deps.Logger)
+	}
+	return &Producer{config: cfg, deps: deps, control: deps.Control, metrics: deps.Metrics, health: deps.Health, logger: deps.Logger}
+}
+
+// Run connects to Schema Registry and Redis and produces messages until ctx is cancelled.
+func (p *Producer) Run(ctx context.Context) error {
+	cfg := p.config
+	p.logger.Info("Starting producer", "brokers", cfg.Brokers, "topic", cfg.Topic)
+
+	// Mark as healthy (process is running)
+	p.health.SetHealthy(true)
+
+	writer := p.deps.Writer
+	if writer == nil {
+		// Connections of the writer are attributed to brokers; the probes below are not counted
+		tracker := kafkaclient.NewTracker(p.metrics, p.logger)
+		kafkaWriter := NewKafkaWriter(cfg, tracker.Dial(p.deps.KafkaDial), p.deps.KafkaSecurity)
+		defer kafkaWriter.Close()
+		p.partitions = newPartitionRecorder(kafkaWriter.Balancer)
+		kafkaWriter.Balancer = p.partitions
+		writer = kafkaWriter
+
+		// Probe the bootstrap addresses and every discovered broker with a metadata request for
+		// readiness and connection status
+		dialer := kafkaclient.NewDialer(p.deps.KafkaDial, p.deps.KafkaSecurity)
+		kafkaclient.AddBrokerChecks(p.health, cfg, dialer, tracker, p.metrics)
+	}
+	p.health.AddCheck(codec.HealthCheck(cfg.SchemaRegistryURL, p.deps.SchemaRegistryTransport, p.metrics))
+
+	// Setup Schema Registry client (timeouts and retries from config)
+	schemaRegistryClient := p.deps.SchemaRegistry
+	if schemaRegistryClient == nil {
+		schemaRegistryClient = codec.NewSchemaRegistryClient(cfg.SchemaRegistryURL, cfg.SchemaRegistryHTTP,
+			p.deps.SchemaRegistryTransport, p.metrics, p.logger)
+	}
+	registry := codec.New(schemaRegistryClient, p.metrics)
+
+	// Get or create Avro schema; the subject is the topic, so a topic switch through the
+	// control API looks up the schema of the new topic
+	schemas := newSchemaCache(registry)
+	if _, err := schemas.get(ctx, p.control.State().Topic); err != nil {
+		return err
+	}
+
+	// Wait until a broker answers a metadata request (at most ProducerStartupDelay)
+	if cfg.ProducerStartupDelay > 0 {
+		p.logger.Info("Waiting for Kafka metadata...")
+		if !p.health.WaitUp(ctx, config.DependencyKafka, cfg.ProducerStartupDelay) {
+			p.logger.Warn("No Kafka broker answered yet, starting anyway", "waited", cfg.ProducerStartupDelay)
+		}
+	}
+
+	// Redis client for delivery verification (hash + SLO)
+	rdb := p.deps.Redis
+	if rdb == nil && cfg.RedisAddr != "" {
+		rdb = verify.NewRedisClient(cfg.RedisAddr, cfg.RedisPassword, p.deps.RedisHooks...)
+		defer rdb.Close()
+	}
+	if rdb != nil {
+		p.health.AddCheck(verify.HealthCheck(rdb))
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			p.logger.Warn("Redis ping failed, hash storage disabled", "error", err)
+			rdb = nil
+		} else {
+			p.logger.Info("Redis connected for hash storage")
+		}
+	}
+	// Failed hash writes are spooled and replayed so that a Redis hiccup is not reported as lost data
+	var verifier *verify.Verifier
+	if rdb != nil {
+		verifier = verify.New(rdb, verify.Options{
+			KeyPrefix:           cfg.RedisKeyPrefix,
+			KeyTTL:              cfg.RedisKeyTTL,
+			SpoolMaxRecords:     cfg.RedisSpoolMaxRecords,
+			SpoolFile:           cfg.RedisSpoolFile,
+			SpoolReplayInterval: cfg.RedisSpoolReplayInterval,
+		}, p.metrics, p.logger)
+		go verifier.Run(ctx)
+		// The consumer corrects produce timestamps by the offset from Redis server time
+		p.clock = clock.NewSync(rdb, cfg.Clock.SyncInterval, p.metrics, p.logger)
+		go p.clock.Run(ctx)
+	}
+
+	// Mark as ready (connected to Kafka and Schema Registry)
+	p.health.SetReady(true)
+	p.logger.Info("Producer is ready")
+
+	p.produceMessages(ctx, writer, schemas, verifier)
+	return nil
+}
+
+// NewKafkaWriter creates the producer's kafka.Writer from config with security. dial
+// replaces the transport's dialer when not nil. The topic is set per message so that it
+// can be switched at runtime.
+func NewKafkaWriter(config *config.Config, dial kafkaclient.DialFunc, security kafkaclient.Security) *kafka.Writer {
+	// Create writer with simplified configuration
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(config.Brokers...),
+		Balancer:               &kafka.LeastBytes{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+		BatchSize:              config.ProducerBatchSize,
+		BatchTimeout:           config.ProducerBatchTimeout,
+		MaxAttempts:            config.ProducerMaxAttempts,
+	}
+
+	if security.SASL != nil || security.TLS != nil || dial != nil {
+		writer.Transport = &kafka.Transport{
+			SASL: security.SASL,
+			TLS:  security.TLS,
+			Dial: dial,
+		}
+	}
+	return writer
+}
+
+// produceMessages sends a message every interval of the control state until ctx is
+// cancelled. Ticks are skipped while paused; rate changes take effect immediately.
+func (p *Producer) produceMessages(ctx context.Context, writer Writer, schemas *schemaCache, verifier *verify.Verifier) {
+	config := p.config
+	messageTemplate := loadMessageTemplate(config.MessageTemplateFile, p.logger)
+	messageID := int64(0)
+	interval := p.control.State().Interval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		changed := p.control.Changed()
+		select {
+		case <-ctx.Done():
+			p.logger.Info("Producer stopped")
+			return
+		case <-changed:
+			if state := p.control.State(); state.Interval != interval {
+				interval = state.Interval
+				ticker.Reset(interval)
+			}
+			continue
+		case <-ticker.C:
+		}
+
+		state := p.control.State()
+		if state.Paused {
+			continue
+		}
+		p.produceMessage(ctx, writer, schemas, verifier, state, messageTemplate, &messageID)
+	}
+}
+
+// produceMessage encodes and writes the next message for state. The message is traced as
+// one span with the Schema Registry lookup, encoding, Kafka write and Redis calls as
+// children; its trace context travels to the consumer in the message headers.
+func (p *Producer) produceMessage(ctx context.Context, writer Writer, schemas *schemaCache, verifier *verify.Verifier,
+	state control.State, messageTemplate string, messageID *int64) {
+	topic := state.Topic
+	id := *messageID + 1
+	kafkaKey := fmt.Sprintf("key-%d", id)
+	ctx, span := tracing.StartProduce(ctx, topic, kafkaKey, id)
+	defer span.End()
+
+	schema, err := schemas.get(ctx, topic)
+	if err != nil {
+		p.logger.Error("Failed to get schema", "error", err, "topic", topic)
+		p.metrics.ProducerErrorsTotal.WithLabelValues(topic, "schema", p.deps.Experiments.Label()).Inc()
+		tracing.RecordError(span, err, "schema")
+		return
+	}
+
+	*messageID = id
+	msgStartTime := time.Now()
+	data := buildPayload(state.Payload, messageTemplate, id, span.SpanContext())
+	msg := codec.Message{
+		ID:        id,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+
+	// Convert message to Avro with Confluent wire format
+	encodeStart := time.Now()
+	_, encodeSpan := tracing.Start(ctx, "avro encode")
+	avroData, err := codec.Encode(schema.codec, schema.id, msg)
+	encodeSpan.End()
+	encodeDuration := time.Since(encodeStart).Seconds()
+	p.metrics.ProducerMessageEncodeDuration.WithLabelValues(topic).Observe(encodeDuration)
+
+	if err != nil {
+		p.logger.Error("Failed to encode message", "error", err, "message_id", id)
+		p.metrics.ProducerErrorsTotal.WithLabelValues(topic, "encode", p.deps.Experiments.Label()).Inc()
+		tracing.RecordError(span, err, "encode")
+		return
+	}
+
+	// Prepare Kafka message (schema ID is now embedded in the value)
+	// The record timestamp equals the payload timestamp, so the consumer can tell a broker
+	// LogAppendTime from it; the clock offset lets it convert both to reference time
+	kafkaMsg := kafka.Message{
+		Topic:   topic,
+		Key:     []byte(kafkaKey),
+		Value:   avroData,
+		Time:    msg.Timestamp,
+		Headers: []kafka.Header{p.clock.Header()},
+	}
+	tracing.Inject(ctx, &kafkaMsg)
+
+	_, writeSpan := tracing.Start(ctx, "kafka write")
+	err = writer.WriteMessages(ctx, kafkaMsg)
+	writeSpan.End()
+	totalDuration := time.Since(msgStartTime).Seconds()
+	partition := p.partitions.take(kafkaKey)
+	tracing.SetPartition(span, partition)
+
+	if err != nil {
+		// Classify by Kafka error code, e.g. NotEnoughReplicas during a pod failure
+		class := kafkaclient.Classify(err)
+		p.logger.Error("Failed to write message", "error", err, "message_id", id, "partition", partition,
+			"error_type", class.Type, "error_code", class.Code, "retriable", class.Retriable)
+		p.metrics.ProducerErrorsTotal.WithLabelValues(topic, "send", p.deps.Experiments.Label()).Inc()
+		p.metrics.ProducerSendErrorsTotal.WithLabelValues(topic, partition, class.Type, class.CodeLabel(), class.RetriableLabel(),
+			p.deps.Experiments.Label()).Inc()
+		tracing.RecordError(span, err, class.Type)
+		return
+	}
+
+	// Store content hash in Redis: key = same as Kafka key, value = contentHash:timestamp_ms (for SLO)
+	if verifier != nil {
+		verifier.RecordSent(ctx, topic, kafkaKey, msg.ID, msg.Data)
+	}
+
+	// Update metrics
+	p.metrics.ProducerMessagesSentTotal.WithLabelValues(topic).Inc()
+	p.metrics.ProducerMessagesSentBytes.WithLabelValues(topic).Add(float64(len(avroData)))
+	metrics.ObserveWithExemplar(p.metrics.ProducerMessageSendDuration.WithLabelValues(topic), totalDuration,
+		metrics.Exemplar{TraceID: traceID(span), Key: kafkaKey, Partition: partition})
+
+	p.logger.Info("Sent message", "message_id", id, "topic", topic)
+}
+
+// traceID returns the trace ID of a sampled span for exemplars, "" otherwise.
+func traceID(span trace.Span) string {
+	if sc := span.SpanContext(); sc.IsSampled() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+// topicSchema is the Avro codec and registered schema ID for a topic.
+type topicSchema struct {
+	codec *goavro.Codec
+	id    int
+}
+
+// schemaCache looks up the schema of each topic once. It is used by the producing
+// goroutine only.
+type schemaCache struct {
+	registry *codec.Codec
+	schemas  map[string]topicSchema
+}
+
+func newSchemaCache(registry *codec.Codec) *schemaCache {
+	return &schemaCache{registry: registry, schemas: make(map[string]topicSchema)}
+}
+
+func (c *schemaCache) get(ctx context.Context, topic string) (topicSchema, error) {
+	if s, ok := c.schemas[topic]; ok {
+		return s, nil
+	}
+	schema, err := c.registry.GetOrCreateSchema(ctx, topic)
+	if err != nil {
+		return topicSchema{}, fmt.Errorf("failed to get/create schema: %w", err)
+	}
+	avroCodec, err := goavro.NewCodec(schema.Schema())
+	if err != nil {
+		return topicSchema{}, fmt.Errorf("failed to create Avro codec: %w", err)
+	}
+	s := topicSchema{codec: avroCodec, id: schema.ID()}
+	c.schemas[topic] = s
+	return s, nil
+}
@@ -0,0 +1,75 @@
+package producer
+
+import (
+	_ "embed"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/control"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//go:embed message_template.json
+var defaultMessageTemplate []byte
+
+// Template placeholders. The trace and span IDs are those of the message's produce span,
+// empty when the message is not traced.
+const (
+	messageIDPlaceholder = "{{message_id}}"
+	traceIDPlaceholder   = "{{trace_id}}"
+	spanIDPlaceholder    = "{{span_id}}"
+)
+
+// loadMessageTemplate returns the message template (from file or embedded default).
+func loadMessageTemplate(path string, logger *slog.Logger) string {
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			logger.Warn("Failed to read MESSAGE_TEMPLATE_FILE, using embedded template", "path", path, "error", err)
+			return string(defaultMessageTemplate)
+		}
+		return string(b)
+	}
+	return string(defaultMessageTemplate)
+}
+
+// buildMessageData substitutes placeholders in the template with actual values.
+func buildMessageData(template string, messageID int64, span trace.SpanContext) string {
+	var traceID, spanID string
+	if span.IsValid() {
+		traceID, spanID = span.TraceID().String(), span.SpanID().String()
+	}
+	return strings.NewReplacer(
+		messageIDPlaceholder, strconv.FormatInt(messageID, 10),
+		traceIDPlaceholder, traceID,
+		spanIDPlaceholder, spanID,
+	).Replace(template)
+}
+
+// buildPayload builds the message data for the payload profile selected through the
+// control API.
+func buildPayload(profile control.PayloadProfile, template string, messageID int64, span trace.SpanContext) string {
+	switch profile.Name {
+	case control.ProfileMinimal:
+		return `{"id":` + strconv.FormatInt(messageID, 10) + `}`
+	case control.ProfileRandom:
+		return randomText(profile.SizeBytes)
+	default:
+		return buildMessageData(template, messageID, span)
+	}
+}
+
+const randomAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// randomText returns n random alphanumeric characters; random data defeats compression,
+// so the payload size on the wire is close to n.
+func randomText(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = randomAlphabet[rand.IntN(len(randomAlphabet))]
+	}
+	return string(b)
+}
@@ -0,0 +1,52 @@
+package recovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/httpjson"
+)
+
+// ServeHTTP implements the recovery part of the control API:
+//
+//	GET  /control/recovery  measurements in progress and the last finished ones
+//	POST /control/recovery  {"experiment":"broker-restart","fault_ended":"2025-01-01T10:00:00Z"}
+//
+// fault_ended defaults to now.
+func (t *Tracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		var req struct {
+			Experiment string    `json:"experiment"`
+			FaultEnded time.Time `json:"fault_ended"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Experiment = strings.TrimSpace(req.Experiment)
+		if req.Experiment == "" {
+			http.Error(w, "experiment must not be empty", http.StatusBadRequest)
+			return
+		}
+		if req.FaultEnded.IsZero() {
+			req.FaultEnded = time.Now()
+		}
+		if req.FaultEnded.After(time.Now()) {
+			http.Error(w, "fault_ended must not be in the future", http.StatusBadRequest)
+			return
+		}
+		t.Start(req.Experiment, req.FaultEnded)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	measuring, results := t.snapshot()
+	httpjson.Write(w, http.StatusOK, struct {
+		Measuring []Measurement `json:"measuring"`
+		Results   []Measurement `json:"results"`
+	}{measuring, results})
+}
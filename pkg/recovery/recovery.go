@@ -0,0 +1,288 @@
+// Package recovery measures the time to recover (TTR) after a chaos fault ends: how long
+// until producer errors stop, consumer lag and pending messages are back to their level
+// before the fault and every partition is fully replicated again. The signals are read
+// from the app's own metrics, so each mode measures what it sees: the producer its
+// errors, the consumer its errors, lag and pending messages, the inspector the partition
+// health. A measurement starts whenever an experiment ends (EXPERIMENT_NAME, the control
+// API or a recovered Chaos Mesh resource) or is requested with a fault end time.
+package recovery
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/experiment"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+)
+
+// maxResults bounds the finished measurements kept for the API.
+const maxResults = 50
+
+// Statuses of a signal in a measurement.
+const (
+	StatusMeasuring = "measuring"
+	StatusRecovered = "recovered"
+	StatusTimeout   = "timeout"
+)
+
+// SignalAll labels the time until the slowest signal recovered.
+const SignalAll = "all"
+
+// Measurement is the recovery after one fault.
+type Measurement struct {
+	Experiment string     `json:"experiment"`
+	FaultEnded time.Time  `json:"fault_ended"`
+	Finished   *time.Time `json:"finished,omitempty"`
+	// TTRSeconds is the time until the slowest signal recovered; unset while measuring and
+	// when a signal timed out.
+	TTRSeconds *float64       `json:"ttr_seconds,omitempty"`
+	Signals    []SignalResult `json:"signals"`
+}
+
+// SignalResult is the recovery of one signal.
+type SignalResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Baseline is the value before the fault for level signals and the value at the
+	// start of the measurement for counters.
+	Baseline   float64  `json:"baseline"`
+	Value      float64  `json:"value"`
+	TTRSeconds *float64 `json:"ttr_seconds,omitempty"`
+
+	// since is when the signal last became recovered, zero while it is not
+	since time.Time
+}
+
+// Tracker runs the recovery measurements.
+type Tracker struct {
+	cfg         config.Recovery
+	signals     []Signal
+	experiments *experiment.Tracker
+	metrics     *metrics.Metrics
+	logger      *slog.Logger
+
+	mu sync.Mutex
+	// baseline holds the level signals as last seen without active experiments
+	baseline  map[string]float64
+	measuring []*Measurement
+	results   []*Measurement
+}
+
+// New creates a Tracker of signals that starts a measurement whenever an experiment of
+// experiments ends.
+func New(cfg config.Recovery, signals []Signal, experiments *experiment.Tracker, m *metrics.Metrics, logger *slog.Logger) *Tracker {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	t := &Tracker{
+		cfg:         cfg,
+		signals:     signals,
+		experiments: experiments,
+		metrics:     m,
+		logger:      logger,
+		baseline:    make(map[string]float64),
+	}
+	experiments.OnEnd(func(e experiment.Experiment, ended time.Time) {
+		t.Start(e.Name, ended)
+	})
+	return t
+}
+
+// Start measures the recovery after a fault of experiment that ended at faultEnded.
+func (t *Tracker) Start(experiment string, faultEnded time.Time) {
+	if len(t.signals) == 0 {
+		return
+	}
+	now := time.Now()
+	m := &Measurement{Experiment: experiment, FaultEnded: faultEnded}
+	t.mu.Lock()
+	for _, s := range t.signals {
+		value := s.Value()
+		r := SignalResult{Name: s.Name, Status: StatusMeasuring, Baseline: value, Value: value}
+		if !s.Counter {
+			r.Baseline = t.baseline[s.Name]
+		}
+		if s.recovered(value, r.Baseline) {
+			r.since = now
+		}
+		m.Signals = append(m.Signals, r)
+	}
+	t.measuring = append(t.measuring, m)
+	t.metrics.RecoveryMeasuring.Set(float64(len(t.measuring)))
+	t.mu.Unlock()
+	t.logger.Info("Recovery measurement started", "experiment", experiment, "fault_ended", faultEnded)
+}
+
+// Run polls the signals until ctx is done.
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			t.poll(now)
+		}
+	}
+}
+
+// poll advances the measurements and, without active experiments, the baseline.
+func (t *Tracker) poll(now time.Time) {
+	values := make(map[string]float64, len(t.signals))
+	for _, s := range t.signals {
+		values[s.Name] = s.Value()
+	}
+
+	t.mu.Lock()
+	if len(t.measuring) == 0 && len(t.experiments.Active()) == 0 {
+		for _, s := range t.signals {
+			if !s.Counter {
+				t.baseline[s.Name] = values[s.Name]
+			}
+		}
+	}
+	var finished []*Measurement
+	measuring := t.measuring[:0]
+	for _, m := range t.measuring {
+		if t.advance(m, values, now) {
+			finished = append(finished, m)
+		} else {
+			measuring = append(measuring, m)
+		}
+	}
+	t.measuring = measuring
+	t.results = append(t.results, finished...)
+	if extra := len(t.results) - maxResults; extra > 0 {
+		t.results = t.results[extra:]
+	}
+	t.metrics.RecoveryMeasuring.Set(float64(len(t.measuring)))
+	t.mu.Unlock()
+
+	for _, m := range finished {
+		t.report(m)
+	}
+}
+
+// advance updates the signals of m and returns whether the measurement is finished;
+// t.mu must be held.
+func (t *Tracker) advance(m *Measurement, values map[string]float64, now time.Time) bool {
+	done := true
+	var slowest time.Duration
+	timedOut := false
+	for i, s := range t.signals {
+		r := &m.Signals[i]
+		switch r.Status {
+		case StatusRecovered:
+			slowest = max(slowest, time.Duration(*r.TTRSeconds*float64(time.Second)))
+			continue
+		case StatusTimeout:
+			timedOut = true
+			continue
+		}
+
+		value := values[s.Name]
+		recovered := s.recovered(value, r.Baseline)
+		if s.Counter {
+			// a counter has recovered once it stops increasing
+			r.Baseline = value
+		}
+		r.Value = value
+		switch {
+		case !recovered:
+			r.since = time.Time{}
+		case r.since.IsZero():
+			r.since = now
+		}
+
+		switch {
+		case recovered && now.Sub(r.since) >= t.cfg.StableFor:
+			ttr := max(r.since.Sub(m.FaultEnded), 0)
+			seconds := ttr.Seconds()
+			r.Status, r.TTRSeconds = StatusRecovered, &seconds
+			slowest = max(slowest, ttr)
+			t.metrics.RecoveryTime.WithLabelValues(m.Experiment, s.Name).Set(seconds)
+			t.metrics.RecoveryDuration.WithLabelValues(s.Name).Observe(seconds)
+		case now.Sub(m.FaultEnded) >= t.cfg.Timeout:
+			r.Status = StatusTimeout
+			timedOut = true
+			t.metrics.RecoveryTimeoutsTotal.WithLabelValues(s.Name).Inc()
+		default:
+			done = false
+		}
+	}
+	if !done {
+		return false
+	}
+	m.Finished = &now
+	if !timedOut {
+		seconds := slowest.Seconds()
+		m.TTRSeconds = &seconds
+		t.metrics.RecoveryTime.WithLabelValues(m.Experiment, SignalAll).Set(seconds)
+		t.metrics.RecoveryDuration.WithLabelValues(SignalAll).Observe(seconds)
+	}
+	return true
+}
+
+// report logs a finished measurement and appends it to the report file.
+func (t *Tracker) report(m *Measurement) {
+	ttrs := make(map[string]string, len(m.Signals))
+	for _, r := range m.Signals {
+		if r.TTRSeconds != nil {
+			ttrs[r.Name] = time.Duration(*r.TTRSeconds * float64(time.Second)).Round(time.Second).String()
+		} else {
+			ttrs[r.Name] = r.Status
+		}
+	}
+	if m.TTRSeconds != nil {
+		t.logger.Info("Recovered after fault", "experiment", m.Experiment, "fault_ended", m.FaultEnded,
+			"ttr", time.Duration(*m.TTRSeconds*float64(time.Second)).Round(time.Second), "signals", ttrs)
+	} else {
+		t.logger.Warn("Not recovered within the recovery timeout", "experiment", m.Experiment, "fault_ended", m.FaultEnded,
+			"timeout", t.cfg.Timeout, "signals", ttrs)
+	}
+
+	if t.cfg.ReportFile == "" {
+		return
+	}
+	if err := appendJSON(t.cfg.ReportFile, m); err != nil {
+		t.logger.Warn("Failed to write recovery report", "file", t.cfg.ReportFile, "error", err)
+	}
+}
+
+func appendJSON(path string, v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// snapshot returns copies of the measurements in progress and the finished ones.
+func (t *Tracker) snapshot() (measuring, results []Measurement) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	copyAll := func(ms []*Measurement) []Measurement {
+		out := make([]Measurement, 0, len(ms))
+		for _, m := range ms {
+			c := *m
+			c.Signals = append([]SignalResult(nil), m.Signals...)
+			out = append(out, c)
+		}
+		return out
+	}
+	return copyAll(t.measuring), copyAll(t.results)
+}
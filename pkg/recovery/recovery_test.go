@@ -0,0 +1,115 @@
+package recovery
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/experiment"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// testSignals are a consumer lag level and an error counter set by the test.
+type testSignals struct {
+	lag, errors float64
+}
+
+func newTestTracker(t *testing.T, s *testSignals) (*Tracker, *experiment.Tracker, *metrics.Metrics) {
+	t.Helper()
+	cfg := config.Default().Recovery
+	cfg.ReportFile = filepath.Join(t.TempDir(), "recovery.jsonl")
+	m := metrics.New(prometheus.NewRegistry())
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	experiments := experiment.NewTracker(m, logger)
+	signals := []Signal{
+		{Name: "consumer_lag", Value: func() float64 { return s.lag }, Tolerance: 10},
+		{Name: "consumer_errors", Value: func() float64 { return s.errors }, Counter: true},
+	}
+	return New(cfg, signals, experiments, m, logger), experiments, m
+}
+
+// runFault records the baseline, runs an experiment and returns when it ended.
+func runFault(t *testing.T, tracker *Tracker, experiments *experiment.Tracker, s *testSignals) time.Time {
+	t.Helper()
+	s.lag = 5
+	tracker.poll(time.Now())
+	experiments.Start(experiment.Experiment{Name: "pod-kill", Source: experiment.SourceAPI})
+	s.lag, s.errors = 1000, 3
+	tracker.poll(time.Now())
+	experiments.End(experiment.Experiment{Name: "pod-kill"}.Key())
+	measuring, _ := tracker.snapshot()
+	if len(measuring) != 1 {
+		t.Fatalf("measurements after the experiment ended = %d, want 1", len(measuring))
+	}
+	return measuring[0].FaultEnded
+}
+
+func TestRecoveryMeasuresSlowestSignal(t *testing.T) {
+	s := &testSignals{}
+	tracker, experiments, m := newTestTracker(t, s)
+	ended := runFault(t, tracker, experiments, s)
+
+	s.errors = 7 // still failing, lag still high
+	tracker.poll(ended.Add(time.Second))
+	s.lag = 12 // within the tolerance of the baseline 5
+	tracker.poll(ended.Add(2 * time.Second))
+	tracker.poll(ended.Add(2*time.Second + tracker.cfg.StableFor - time.Millisecond))
+	if measuring, _ := tracker.snapshot(); len(measuring) != 1 {
+		t.Fatal("measurement finished before the signals were stable")
+	}
+	tracker.poll(ended.Add(2*time.Second + tracker.cfg.StableFor))
+
+	measuring, results := tracker.snapshot()
+	if len(measuring) != 0 || len(results) != 1 {
+		t.Fatalf("measuring %d, finished %d; want 0, 1", len(measuring), len(results))
+	}
+	r := results[0]
+	if r.TTRSeconds == nil || *r.TTRSeconds != 2 {
+		t.Errorf("TTR = %v, want 2s", r.TTRSeconds)
+	}
+	for _, signal := range r.Signals {
+		if signal.Status != StatusRecovered {
+			t.Errorf("signal %s = %s, want recovered", signal.Name, signal.Status)
+		}
+	}
+	if r.Signals[0].Baseline != 5 {
+		t.Errorf("lag baseline = %v, want the value before the fault", r.Signals[0].Baseline)
+	}
+	if got := testutil.ToFloat64(m.RecoveryTime.WithLabelValues("pod-kill", SignalAll)); got != 2 {
+		t.Errorf("app_recovery_time_seconds{signal=all} = %v, want 2", got)
+	}
+
+	data, err := os.ReadFile(tracker.cfg.ReportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reported Measurement
+	if err := json.Unmarshal(data, &reported); err != nil || reported.Experiment != "pod-kill" || reported.TTRSeconds == nil {
+		t.Errorf("report line %s: %v", data, err)
+	}
+}
+
+func TestRecoveryTimesOut(t *testing.T) {
+	s := &testSignals{}
+	tracker, experiments, m := newTestTracker(t, s)
+	ended := runFault(t, tracker, experiments, s)
+
+	tracker.poll(ended.Add(tracker.cfg.Timeout))
+	_, results := tracker.snapshot()
+	if len(results) != 1 || results[0].TTRSeconds != nil {
+		t.Fatalf("results = %+v, want one measurement without TTR", results)
+	}
+	if status := results[0].Signals[0].Status; status != StatusTimeout {
+		t.Errorf("lag status = %s, want timeout", status)
+	}
+	if got := testutil.ToFloat64(m.RecoveryTimeoutsTotal.WithLabelValues("consumer_lag")); got != 1 {
+		t.Errorf("app_recovery_timeouts_total{signal=consumer_lag} = %v, want 1", got)
+	}
+}
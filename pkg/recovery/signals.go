@@ -0,0 +1,74 @@
+package recovery
+
+import (
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Signal is one recovery criterion read from the app's metrics.
+type Signal struct {
+	Name  string
+	Value func() float64
+	// Counter signals have recovered once Value stops increasing, the others once Value
+	// is back within Tolerance of its value before the fault.
+	Counter   bool
+	Tolerance float64
+}
+
+func (s Signal) recovered(value, baseline float64) bool {
+	if s.Counter {
+		return value <= baseline
+	}
+	return value <= baseline+s.Tolerance
+}
+
+// Signals returns the signals the mode of cfg can see.
+func Signals(cfg *config.Config, m *metrics.Metrics) []Signal {
+	switch cfg.Mode {
+	case config.ModeProducer:
+		return []Signal{
+			{Name: "producer_errors", Value: sum(m.ProducerErrorsTotal), Counter: true},
+		}
+	case config.ModeConsumer:
+		signals := []Signal{
+			{Name: "consumer_errors", Value: sum(m.ConsumerErrorsTotal), Counter: true},
+			// updated by the lag poller every 30s
+			{Name: "consumer_lag", Value: sum(m.ConsumerLag), Tolerance: float64(cfg.Recovery.LagTolerance)},
+		}
+		if cfg.RedisAddr != "" {
+			signals = append(signals, Signal{Name: "pending_messages", Value: sum(m.RedisPendingMessages),
+				Tolerance: float64(cfg.Recovery.PendingTolerance)})
+		}
+		return signals
+	case config.ModeInspector:
+		return []Signal{
+			// partitions without a leader or with an incomplete ISR
+			{Name: "partitions", Value: func() float64 {
+				return sum(m.KafkaClusterUnderReplicated)() + sum(m.KafkaClusterOfflinePartitions)()
+			}},
+		}
+	}
+	return nil
+}
+
+// sum returns a function reading the sum of the series of c.
+func sum(c prometheus.Collector) func() float64 {
+	return func() float64 {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			c.Collect(ch)
+			close(ch)
+		}()
+		var total float64
+		for metric := range ch {
+			var pb dto.Metric
+			if metric.Write(&pb) != nil {
+				continue
+			}
+			total += pb.GetGauge().GetValue() + pb.GetCounter().GetValue()
+		}
+		return total
+	}
+}
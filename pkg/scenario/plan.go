@@ -0,0 +1,213 @@
+package scenario
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Action types of a plan.
+const (
+	ActionWorkload = "workload"
+	ActionApply    = "apply"
+	ActionDelete   = "delete"
+	ActionWait     = "wait"
+	ActionUntil    = "until"
+	ActionAssert   = "assert"
+)
+
+// Offset is a point of the timeline relative to the start of the scenario. Min and Max
+// differ once a step has waited for a condition: Min assumes it held at once, Max that
+// it held at the timeout.
+type Offset struct {
+	Min, Max time.Duration
+}
+
+func (o Offset) add(d time.Duration) Offset {
+	return Offset{o.Min + d, o.Max + d}
+}
+
+func (o Offset) String() string {
+	if o.Min == o.Max {
+		return "+" + o.Min.String()
+	}
+	return "+" + o.Min.String() + "..+" + o.Max.String()
+}
+
+// Action is one thing the scenario does at an offset.
+type Action struct {
+	At     Offset
+	Step   string
+	Type   string
+	Detail string
+}
+
+// Plan is the dry-run timeline of a scenario.
+type Plan struct {
+	Scenario string
+	Actions  []Action
+	// End is when the last step ends.
+	End Offset
+	// Chaos counts the chaos steps.
+	Chaos int
+}
+
+// Plan builds the timeline of a validated scenario without executing anything.
+func (s *Scenario) Plan() *Plan {
+	p := &planner{scenario: s, plan: &Plan{Scenario: s.Name}}
+	var at Offset
+	if s.Workload != nil {
+		p.add(at, "setup", ActionWorkload, describeWorkload(s.Workload))
+	}
+	for i := range s.Steps {
+		at = p.step(&s.Steps[i], at)
+	}
+	p.plan.End = at
+	sort.SliceStable(p.plan.Actions, func(i, j int) bool {
+		return p.plan.Actions[i].At.Min < p.plan.Actions[j].At.Min
+	})
+	return p.plan
+}
+
+type planner struct {
+	scenario *Scenario
+	plan     *Plan
+}
+
+func (p *planner) add(at Offset, step, typ, detail string) {
+	p.plan.Actions = append(p.plan.Actions, Action{At: at, Step: step, Type: typ, Detail: detail})
+}
+
+// step adds the actions of st starting at at and returns when it ends.
+func (p *planner) step(st *Step, at Offset) Offset {
+	name := st.label()
+	if st.Workload != nil {
+		p.add(at, name, ActionWorkload, describeWorkload(st.Workload))
+	}
+	end := at
+	switch {
+	case st.Chaos != "":
+		p.plan.Chaos++
+		p.add(at, name, ActionApply, p.describeChaos(st))
+		end = at.add(st.Duration)
+		p.add(end, name, ActionDelete, p.scenario.manifestPath(st.Chaos))
+	case len(st.Parallel) > 0:
+		for i := range st.Parallel {
+			branchEnd := p.step(&st.Parallel[i], at)
+			end.Min = max(end.Min, branchEnd.Min)
+			end.Max = max(end.Max, branchEnd.Max)
+		}
+	case len(st.Sequence) > 0:
+		for i := range st.Sequence {
+			end = p.step(&st.Sequence[i], end)
+		}
+	case st.Duration > 0:
+		p.add(at, name, ActionWait, st.Duration.String())
+		end = at.add(st.Duration)
+	}
+	if c := st.Until; c != nil {
+		p.add(end, name, ActionUntil, fmt.Sprintf("%s (timeout %s)", c.Check, c.Timeout))
+		end.Max += c.Timeout
+	}
+	for _, a := range st.Assert {
+		detail := a.Check.String()
+		if a.Name != "" {
+			detail = a.Name + ": " + detail
+		}
+		p.add(end, name, ActionAssert, detail)
+	}
+	return end
+}
+
+// label names the step in the plan: its name, else the manifest without extension.
+func (st *Step) label() string {
+	switch {
+	case st.Name != "":
+		return st.Name
+	case st.Chaos != "":
+		return strings.TrimSuffix(filepath.Base(st.Chaos), filepath.Ext(st.Chaos))
+	case len(st.Parallel) > 0:
+		return "parallel"
+	case len(st.Sequence) > 0:
+		return "sequence"
+	}
+	return "step"
+}
+
+func (p *planner) describeChaos(st *Step) string {
+	resources := make([]string, 0, len(st.manifest))
+	for _, o := range st.manifest {
+		resources = append(resources, fmt.Sprintf("%s %s/%s", o.Kind, o.Namespace, o.Name))
+	}
+	detail := fmt.Sprintf("%s for %s", p.scenario.manifestPath(st.Chaos), st.Duration)
+	if len(resources) > 0 {
+		detail += " (" + strings.Join(resources, ", ") + ")"
+	}
+	return detail
+}
+
+func (c Check) String() string {
+	return fmt.Sprintf("%s %s %v", strings.TrimSpace(c.Query), c.Op, c.Value)
+}
+
+func describeWorkload(w *Workload) string {
+	var parts []string
+	if pw := w.Producer; pw != nil {
+		var changes []string
+		switch {
+		case pw.MessagesPerSecond > 0:
+			changes = append(changes, fmt.Sprintf("%v msg/s", pw.MessagesPerSecond))
+		case pw.Interval > 0:
+			changes = append(changes, "interval "+pw.Interval.String())
+		}
+		if pw.Payload != nil {
+			payload := "payload " + pw.Payload.Profile
+			if pw.Payload.SizeBytes > 0 {
+				payload += fmt.Sprintf(" %dB", pw.Payload.SizeBytes)
+			}
+			changes = append(changes, payload)
+		}
+		changes = append(changes, describePause(pw.Paused, pw.Topic)...)
+		parts = append(parts, "producer: "+strings.Join(changes, ", "))
+	}
+	if cw := w.Consumer; cw != nil {
+		parts = append(parts, "consumer: "+strings.Join(describePause(cw.Paused, cw.Topic), ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func describePause(paused *bool, topic string) []string {
+	var changes []string
+	if paused != nil {
+		if *paused {
+			changes = append(changes, "pause")
+		} else {
+			changes = append(changes, "resume")
+		}
+	}
+	if topic != "" {
+		changes = append(changes, "topic "+topic)
+	}
+	return changes
+}
+
+// Write prints the plan as an aligned table.
+func (p *Plan) Write(w io.Writer) error {
+	duration := p.End.Min.String()
+	if p.End.Max != p.End.Min {
+		duration += " (up to " + p.End.Max.String() + ")"
+	}
+	if _, err := fmt.Fprintf(w, "Scenario %s: %d chaos steps, duration %s\n\n", p.Scenario, p.Chaos, duration); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, a := range p.Actions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", a.At, a.Type, a.Step, a.Detail)
+	}
+	fmt.Fprintf(tw, "%s\tend\t\t\n", p.End)
+	return tw.Flush()
+}
@@ -0,0 +1,199 @@
+// Package scenario defines a declarative format for chaos test plans: an ordered list of
+// steps that apply Chaos Mesh manifests from chaos-experiments/, change the workload
+// through the control API, wait for conditions and assert on metrics, run in sequence or
+// in parallel. Scenarios are YAML files versioned next to the manifests; this package
+// parses and validates them and builds a dry-run plan with the expected timeline.
+package scenario
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultManifestDir is where the manifests of a scenario are looked up, relative to
+// the scenario file, when it sets no manifest_dir: scenarios/ next to chaos-experiments/.
+const DefaultManifestDir = "../chaos-experiments"
+
+// Scenario is one chaos test plan.
+type Scenario struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// ManifestDir resolves the chaos manifests of the steps, relative to the scenario file.
+	ManifestDir string `yaml:"manifest_dir"`
+	// Workload is applied before the first step.
+	Workload *Workload `yaml:"workload"`
+	Steps    []Step    `yaml:"steps"`
+
+	// path of the scenario file, manifest paths are resolved against its directory
+	path string
+}
+
+// Step is one phase of a scenario. It is exactly one of: a chaos step (Chaos with the
+// Duration it stays applied), a group of steps run in Parallel or in Sequence, or a
+// plain step that changes the workload and/or waits for Duration. Any step may end by
+// waiting Until a condition holds and checking its assertions.
+type Step struct {
+	Name string `yaml:"name"`
+	// Description states the expected behaviour, for reviewers and reports.
+	Description string `yaml:"description"`
+	// Chaos is a manifest in the manifest directory, applied at the start of the step and
+	// deleted after Duration.
+	Chaos    string        `yaml:"chaos"`
+	Duration time.Duration `yaml:"duration"`
+	// Workload is applied at the start of the step and stays in effect afterwards.
+	Workload *Workload `yaml:"workload"`
+	Parallel []Step    `yaml:"parallel"`
+	Sequence []Step    `yaml:"sequence"`
+	// Until is waited for after the chaos is deleted or Duration has passed, e.g. the
+	// recovery of the cluster.
+	Until *Condition `yaml:"until"`
+	// Assert is checked when the step ends.
+	Assert []Assertion `yaml:"assert"`
+
+	// objects of the Chaos manifest, read by Validate
+	manifest []Object
+}
+
+// Workload changes the producer and consumer through their control API; unset fields
+// are left as they are.
+type Workload struct {
+	Producer *ProducerWorkload `yaml:"producer"`
+	Consumer *ConsumerWorkload `yaml:"consumer"`
+}
+
+// ProducerWorkload sets the rate (MessagesPerSecond or Interval), payload, pause and
+// topic of the producers.
+type ProducerWorkload struct {
+	MessagesPerSecond float64       `yaml:"messages_per_second"`
+	Interval          time.Duration `yaml:"interval"`
+	Payload           *Payload      `yaml:"payload"`
+	Paused            *bool         `yaml:"paused"`
+	Topic             string        `yaml:"topic"`
+}
+
+// Payload is a payload profile of the control API (template, minimal or random).
+type Payload struct {
+	Profile   string `yaml:"profile"`
+	SizeBytes int    `yaml:"size_bytes"`
+}
+
+// ConsumerWorkload pauses or resumes the consumers or switches their topic.
+type ConsumerWorkload struct {
+	Paused *bool  `yaml:"paused"`
+	Topic  string `yaml:"topic"`
+}
+
+// Check compares the result of a PromQL query with Value; it holds when every returned
+// series satisfies Op (one of <, <=, ==, !=, >=, >).
+type Check struct {
+	Query string  `yaml:"query"`
+	Op    string  `yaml:"op"`
+	Value float64 `yaml:"value"`
+}
+
+// Condition is a Check waited for up to Timeout.
+type Condition struct {
+	Check   `yaml:",inline"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Assertion is a named Check that must hold when its step ends.
+type Assertion struct {
+	Name  string `yaml:"name"`
+	Check `yaml:",inline"`
+}
+
+// Object is a resource of a chaos manifest.
+type Object struct {
+	APIVersion string
+	Kind       string
+	Name       string
+	Namespace  string
+}
+
+// Load reads, parses and validates the scenario at path.
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario: %w", err)
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse scenario %s: %w", path, err)
+	}
+	s.path = path
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s:\n%w", path, err)
+	}
+	return s, nil
+}
+
+// Parse decodes a scenario; unknown keys are rejected. Durations are Go duration strings
+// such as "2m". Manifests are resolved relative to the working directory until the
+// scenario is loaded from a file.
+func Parse(data []byte) (*Scenario, error) {
+	var s Scenario
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty scenario")
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// manifestPath returns the path of a manifest referenced by a step.
+func (s *Scenario) manifestPath(name string) string {
+	dir := s.ManifestDir
+	if dir == "" {
+		dir = DefaultManifestDir
+	}
+	if !filepath.IsAbs(dir) && s.path != "" {
+		dir = filepath.Join(filepath.Dir(s.path), dir)
+	}
+	return filepath.Join(dir, name)
+}
+
+// readManifest returns the objects of a multi-document manifest.
+func readManifest(path string) ([]Object, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var objects []Object
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc struct {
+			APIVersion string `yaml:"apiVersion"`
+			Kind       string `yaml:"kind"`
+			Metadata   struct {
+				Name      string `yaml:"name"`
+				Namespace string `yaml:"namespace"`
+			} `yaml:"metadata"`
+		}
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, err
+		}
+		if doc.Kind == "" {
+			continue // empty document
+		}
+		objects = append(objects, Object{
+			APIVersion: doc.APIVersion,
+			Kind:       doc.Kind,
+			Name:       doc.Metadata.Name,
+			Namespace:  doc.Metadata.Namespace,
+		})
+	}
+}
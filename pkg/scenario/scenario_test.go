@@ -0,0 +1,149 @@
+package scenario
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+const testManifest = `apiVersion: chaos-mesh.org/v1alpha1
+kind: PodChaos
+metadata:
+  name: kafka-pod-kill
+  namespace: kafka-cluster
+`
+
+// loadScenario writes scenario next to a chaos-experiments/ directory holding
+// pod-kill.yaml, network-delay.yaml and deployment.yaml, and loads it.
+func loadScenario(t *testing.T, scenario string) (*Scenario, error) {
+	t.Helper()
+	dir := t.TempDir()
+	manifests := filepath.Join(dir, "chaos-experiments")
+	files := map[string]string{
+		"pod-kill.yaml":      testManifest,
+		"network-delay.yaml": strings.Replace(testManifest, "PodChaos", "NetworkChaos", 1),
+		"deployment.yaml":    "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: producer\n",
+	}
+	if err := os.Mkdir(manifests, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(manifests, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "scenarios", "test.yaml")
+	if err := os.Mkdir(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(scenario), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestLoadRejectsInvalidScenarios(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenario string
+		want     string
+	}{
+		{"empty", "", "empty scenario"},
+		{"unknown key", "name: s\nstep: []\n", "field step not found"},
+		{"no steps", "name: s\n", "steps: must not be empty"},
+		{"unknown experiment file", "name: s\nsteps:\n  - chaos: pod-failure.yaml\n    duration: 1m\n",
+			"steps[0]: chaos pod-failure.yaml: open"},
+		{"manifest outside the directory", "name: s\nsteps:\n  - chaos: ../scenarios/test.yaml\n    duration: 1m\n",
+			"must be a path inside the manifest directory"},
+		{"not a Chaos Mesh resource", "name: s\nsteps:\n  - chaos: deployment.yaml\n    duration: 1m\n",
+			"Deployment producer is not a Chaos Mesh resource"},
+		{"duration without unit", "name: s\nsteps:\n  - duration: 5\n", "time.Duration"},
+		{"unparsable duration", "name: s\nsteps:\n  - duration: 5 minutes\n", "time.Duration"},
+		{"negative duration", "name: s\nsteps:\n  - duration: -1m\n", "steps[0]: duration -1m0s: must not be negative"},
+		{"chaos without duration", "name: s\nsteps:\n  - chaos: pod-kill.yaml\n",
+			"steps[0]: chaos pod-kill.yaml: duration must be positive"},
+		{"negative until timeout",
+			"name: s\nsteps:\n  - until:\n      query: up\n      op: \"==\"\n      value: 1\n      timeout: -30s\n",
+			"steps[0].until: timeout -30s: must be positive"},
+		{"duration on a group",
+			"name: s\nsteps:\n  - duration: 1m\n    sequence:\n      - duration: 1m\n",
+			"steps[0]: duration only applies to chaos and plain steps"},
+		{"two kinds", "name: s\nsteps:\n  - chaos: pod-kill.yaml\n    duration: 1m\n    sequence:\n      - duration: 1m\n",
+			"set only one of chaos, parallel and sequence"},
+		{"same manifest in parallel branches",
+			"name: s\nsteps:\n  - parallel:\n      - chaos: pod-kill.yaml\n        duration: 1m\n      - chaos: ./pod-kill.yaml\n        duration: 2m\n",
+			"chaos pod-kill.yaml is applied by parallel branches 0 and 1"},
+		{"workload inside parallel",
+			"name: s\nsteps:\n  - parallel:\n      - duration: 1m\n        workload:\n          consumer:\n            paused: true\n",
+			"workload cannot change inside parallel branches"},
+		{"duplicate step name", "name: s\nsteps:\n  - name: a\n    duration: 1m\n  - name: a\n    duration: 1m\n",
+			"steps[1]: name \"a\" already used by steps[0]"},
+		{"unknown operator", "name: s\nsteps:\n  - assert:\n      - query: up\n        op: \"=\"\n",
+			"steps[0].assert[0]: op \"=\""},
+		{"rate out of range", "name: s\nsteps:\n  - workload:\n      producer:\n        messages_per_second: -1\n",
+			"messages_per_second -1: must be within (0, 1000]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadScenario(t, tt.scenario)
+			if err == nil {
+				t.Fatalf("Load() = nil, want error containing %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	_, err := loadScenario(t, "steps:\n  - duration: -1m\n  - chaos: missing.yaml\n")
+	if err == nil {
+		t.Fatal("Load() = nil, want error")
+	}
+	for _, want := range []string{"name: must not be empty", "steps[0]: duration", "steps[1]: chaos missing.yaml"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Load() = %v, want error containing %q", err, want)
+		}
+	}
+}
+
+// TestPlanGolden compares the plans of the scenarios shipped in scenarios/ with
+// testdata/<scenario>.golden; run with -update after changing a scenario.
+func TestPlanGolden(t *testing.T) {
+	paths, err := filepath.Glob("../../scenarios/*.yaml")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no scenarios found: %v", err)
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		t.Run(name, func(t *testing.T) {
+			s, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := s.Plan().Write(&out); err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("plan of %s differs from %s:\n%s", path, golden, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/control"
+)
+
+// Comparison operators of a Check.
+var checkOps = map[string]bool{"<": true, "<=": true, "==": true, "!=": true, ">=": true, ">": true}
+
+// Validate checks the whole scenario and reads the chaos manifests it references. The
+// returned error joins every problem found, each prefixed with the path of its step.
+func (s *Scenario) Validate() error {
+	v := &validator{scenario: s, names: make(map[string]string)}
+	if strings.TrimSpace(s.Name) == "" {
+		v.errorf("name", "must not be empty")
+	}
+	if len(s.Steps) == 0 {
+		v.errorf("steps", "must not be empty")
+	}
+	if s.Workload != nil {
+		v.workload("workload", s.Workload)
+	}
+	v.steps("steps", s.Steps, false)
+	return errors.Join(v.errs...)
+}
+
+type validator struct {
+	scenario *Scenario
+	names    map[string]string // step name -> path of the step that declared it
+	errs     []error
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf(path+": "+format, args...))
+}
+
+func (v *validator) steps(path string, steps []Step, inParallel bool) {
+	for i := range steps {
+		v.step(fmt.Sprintf("%s[%d]", path, i), &steps[i], inParallel)
+	}
+}
+
+func (v *validator) step(path string, st *Step, inParallel bool) {
+	if st.Name != "" {
+		if first, ok := v.names[st.Name]; ok {
+			v.errorf(path, "name %q already used by %s", st.Name, first)
+		} else {
+			v.names[st.Name] = path
+		}
+	}
+	if st.Duration < 0 {
+		v.errorf(path, "duration %s: must not be negative", st.Duration)
+	}
+
+	kinds := 0
+	for _, set := range []bool{st.Chaos != "", len(st.Parallel) > 0, len(st.Sequence) > 0} {
+		if set {
+			kinds++
+		}
+	}
+	switch {
+	case kinds > 1:
+		v.errorf(path, "set only one of chaos, parallel and sequence")
+	case st.Chaos != "":
+		v.chaos(path, st)
+	case len(st.Parallel) > 0:
+		v.group(path, st)
+		v.steps(path+".parallel", st.Parallel, true)
+		v.parallelManifests(path, st.Parallel)
+	case len(st.Sequence) > 0:
+		v.group(path, st)
+		v.steps(path+".sequence", st.Sequence, inParallel)
+	case st.Duration == 0 && st.Workload == nil && st.Until == nil && len(st.Assert) == 0:
+		v.errorf(path, "empty step: set chaos, parallel, sequence, duration, workload, until or assert")
+	}
+
+	if st.Workload != nil {
+		if inParallel {
+			// the branches would race to set the same producer and consumer state
+			v.errorf(path, "workload cannot change inside parallel branches; set it on the parallel step")
+		}
+		v.workload(path+".workload", st.Workload)
+	}
+	if st.Until != nil {
+		v.check(path+".until", st.Until.Check)
+		if st.Until.Timeout <= 0 {
+			v.errorf(path+".until", "timeout %s: must be positive", st.Until.Timeout)
+		}
+	}
+	for i, a := range st.Assert {
+		v.check(fmt.Sprintf("%s.assert[%d]", path, i), a.Check)
+	}
+}
+
+// chaos checks a chaos step and reads its manifest.
+func (v *validator) chaos(path string, st *Step) {
+	if st.Duration <= 0 {
+		v.errorf(path, "chaos %s: duration must be positive", st.Chaos)
+	}
+	if filepath.IsAbs(st.Chaos) || strings.HasPrefix(filepath.Clean(st.Chaos), "..") {
+		v.errorf(path, "chaos %s: must be a path inside the manifest directory", st.Chaos)
+		return
+	}
+	objects, err := readManifest(v.scenario.manifestPath(st.Chaos))
+	if err != nil {
+		v.errorf(path, "chaos %s: %v", st.Chaos, err)
+		return
+	}
+	if len(objects) == 0 {
+		v.errorf(path, "chaos %s: manifest has no resources", st.Chaos)
+	}
+	for _, o := range objects {
+		if !strings.HasPrefix(o.APIVersion, "chaos-mesh.org/") {
+			v.errorf(path, "chaos %s: %s %s is not a Chaos Mesh resource", st.Chaos, o.Kind, o.Name)
+		}
+	}
+	st.manifest = objects
+}
+
+func (v *validator) group(path string, st *Step) {
+	if st.Duration != 0 {
+		v.errorf(path, "duration only applies to chaos and plain steps")
+	}
+}
+
+// parallelManifests rejects a manifest applied by two parallel branches: the first
+// branch to end would delete the resources of the other.
+func (v *validator) parallelManifests(path string, branches []Step) {
+	owner := make(map[string]int)
+	for i := range branches {
+		for _, m := range manifests(&branches[i]) {
+			if first, ok := owner[m]; ok && first != i {
+				v.errorf(path, "chaos %s is applied by parallel branches %d and %d", m, first, i)
+			}
+			owner[m] = i
+		}
+	}
+}
+
+// manifests returns the chaos manifests applied by st and its children.
+func manifests(st *Step) []string {
+	var names []string
+	if st.Chaos != "" {
+		names = append(names, filepath.Clean(st.Chaos))
+	}
+	for _, children := range [][]Step{st.Parallel, st.Sequence} {
+		for i := range children {
+			names = append(names, manifests(&children[i])...)
+		}
+	}
+	return names
+}
+
+func (v *validator) check(path string, c Check) {
+	if strings.TrimSpace(c.Query) == "" {
+		v.errorf(path, "query must not be empty")
+	}
+	if !checkOps[c.Op] {
+		v.errorf(path, "op %q: must be one of <, <=, ==, !=, >=, >", c.Op)
+	}
+}
+
+func (v *validator) workload(path string, w *Workload) {
+	if w.Producer == nil && w.Consumer == nil {
+		v.errorf(path, "set producer or consumer")
+	}
+	if p := w.Producer; p != nil {
+		if p.MessagesPerSecond == 0 && p.Interval == 0 && p.Payload == nil && p.Paused == nil && p.Topic == "" {
+			v.errorf(path+".producer", "sets nothing")
+		}
+		switch {
+		case p.MessagesPerSecond != 0 && p.Interval != 0:
+			v.errorf(path+".producer", "set only one of messages_per_second and interval")
+		case p.MessagesPerSecond < 0 || p.MessagesPerSecond > 1000:
+			v.errorf(path+".producer", "messages_per_second %v: must be within (0, 1000]", p.MessagesPerSecond)
+		case p.Interval != 0 && p.Interval < time.Millisecond:
+			v.errorf(path+".producer", "interval %s: must be at least 1ms", p.Interval)
+		}
+		if p.Payload != nil {
+			if err := (control.PayloadProfile{Name: p.Payload.Profile, SizeBytes: p.Payload.SizeBytes}).Validate(); err != nil {
+				v.errorf(path+".producer.payload", "%v", err)
+			}
+		}
+		if p.Topic != strings.TrimSpace(p.Topic) {
+			v.errorf(path+".producer", "topic %q: must not have surrounding spaces", p.Topic)
+		}
+	}
+	if c := w.Consumer; c != nil {
+		if c.Paused == nil && c.Topic == "" {
+			v.errorf(path+".consumer", "sets nothing")
+		}
+		if c.Topic != strings.TrimSpace(c.Topic) {
+			v.errorf(path+".consumer", "topic %q: must not have surrounding spaces", c.Topic)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Package slo evaluates the consumer's delivery objectives over rolling windows: the SLI,
+// the remaining error budget of the SLO window and multi-window burn rates, with
+// burn-rate alerts that can be sent to a webhook. Chaos results can then be stated as the
+// share of the budget an experiment burned.
+//
+// Events are kept in memory, so the windows cover at most the time since the consumer
+// started; app_slo_events_total allows the same computation across restarts in PromQL.
+package slo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/verify"
+)
+
+// Objectives.
+const (
+	// ObjectiveDeliveryLatency counts consumed messages: good when delivered within the
+	// threshold (end-to-end latency corrected for clock offsets).
+	ObjectiveDeliveryLatency = "delivery_latency"
+	// ObjectiveZeroLoss counts verified messages: bad when the body does not match what
+	// was sent or the message is still undelivered past the threshold. A message that
+	// arrives after it was counted as undelivered is counted once, as good.
+	ObjectiveZeroLoss = "zero_loss"
+)
+
+// windows over which SLI and burn rate are reported besides the SLO window itself; those
+// not shorter than the SLO window are skipped.
+var windows = []time.Duration{
+	5 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour, 6 * time.Hour, 24 * time.Hour, 3 * 24 * time.Hour,
+}
+
+// alertRule is a multi-window burn-rate alert: it fires when both windows burn faster than
+// spending budgetShare of the error budget within the long window would require. The
+// short window (1/12 of the long one) makes the alert resolve soon after the burn stops.
+type alertRule struct {
+	name        string
+	severity    string
+	long, short time.Duration
+	budgetShare float64
+}
+
+// alertRules follow the multi-window, multi-burn-rate alerts of the Google SRE workbook,
+// with thresholds scaled to the configured SLO window.
+var alertRules = []alertRule{
+	{name: "fast_burn", severity: "page", long: time.Hour, short: 5 * time.Minute, budgetShare: 0.02},
+	{name: "slow_burn", severity: "page", long: 6 * time.Hour, short: 30 * time.Minute, budgetShare: 0.05},
+	{name: "budget_drain", severity: "ticket", long: 24 * time.Hour, short: 2 * time.Hour, budgetShare: 0.10},
+	{name: "slow_budget_drain", severity: "ticket", long: 3 * 24 * time.Hour, short: 6 * time.Hour, budgetShare: 0.10},
+}
+
+// threshold returns the burn rate at which r spends its budget share of window.
+func (r alertRule) threshold(window time.Duration) float64 {
+	return r.budgetShare * window.Hours() / r.long.Hours()
+}
+
+// objective is the state of one objective.
+type objective struct {
+	name   string
+	target float64
+	events *series
+	firing map[string]bool // by alert rule name
+}
+
+// Engine counts delivery events and evaluates the objectives. It implements
+// verify.Observer for the zero-loss objective.
+type Engine struct {
+	cfg       config.SLO
+	threshold time.Duration
+	metrics   *metrics.Metrics
+	logger    *slog.Logger
+	client    *http.Client
+
+	start   time.Time       // monotonic reference of the event buckets
+	windows []time.Duration // reported windows, ending with the SLO window
+
+	mu         sync.Mutex
+	objectives []*objective
+}
+
+var _ verify.Observer = (*Engine)(nil)
+
+// New creates an Engine for cfg; threshold is the delivery time objective (redis_slo_seconds).
+func New(cfg config.SLO, threshold time.Duration, m *metrics.Metrics, logger *slog.Logger) *Engine {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	e := &Engine{
+		cfg:       cfg,
+		threshold: threshold,
+		metrics:   m,
+		logger:    logger,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		start:     time.Now(),
+		objectives: []*objective{
+			{name: ObjectiveDeliveryLatency, target: cfg.DeliveryTarget},
+			{name: ObjectiveZeroLoss, target: cfg.LossTarget},
+		},
+	}
+	for _, w := range windows {
+		if w < cfg.Window {
+			e.windows = append(e.windows, w)
+		}
+	}
+	e.windows = append(e.windows, cfg.Window)
+	for _, o := range e.objectives {
+		o.events = newSeries(cfg.Window)
+		o.firing = make(map[string]bool)
+		m.SLOTarget.WithLabelValues(o.name).Set(o.target)
+	}
+	return e
+}
+
+// Delivered records a consumed message with its end-to-end latency.
+func (e *Engine) Delivered(latency time.Duration) {
+	if latency <= e.threshold {
+		e.record(ObjectiveDeliveryLatency, 1, 0)
+	} else {
+		e.record(ObjectiveDeliveryLatency, 0, 1)
+	}
+}
+
+// Verified records the outcome of a delivery verification. Missing keys are duplicates or
+// messages sent without Redis and do not count. A late match withdraws the bad event
+// recorded when the message became overdue.
+func (e *Engine) Verified(outcome string) {
+	switch outcome {
+	case verify.Matched:
+		e.record(ObjectiveZeroLoss, 1, 0)
+	case verify.Late:
+		e.record(ObjectiveZeroLoss, 1, -1)
+	case verify.Mismatch:
+		e.record(ObjectiveZeroLoss, 0, 1)
+	}
+}
+
+// Overdue records n messages that are still undelivered past the threshold.
+func (e *Engine) Overdue(n int) {
+	if n > 0 {
+		e.record(ObjectiveZeroLoss, 0, float64(n))
+	}
+}
+
+func (e *Engine) record(name string, good, bad float64) {
+	e.mu.Lock()
+	for _, o := range e.objectives {
+		if o.name == name {
+			o.events.add(time.Since(e.start), good, bad)
+		}
+	}
+	e.mu.Unlock()
+	if good > 0 {
+		e.metrics.SLOEventsTotal.WithLabelValues(name, "good").Add(good)
+	}
+	if bad > 0 {
+		e.metrics.SLOEventsTotal.WithLabelValues(name, "bad").Add(bad)
+	}
+	if bad < 0 {
+		e.metrics.SLOEventsTotal.WithLabelValues(name, "corrected").Add(-bad)
+	}
+}
+
+// Run evaluates the objectives every evaluation interval until ctx is cancelled.
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.EvaluationInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, n := range e.evaluate() {
+				e.notify(ctx, n)
+			}
+		}
+	}
+}
+
+// evaluate updates the SLO metrics and returns notifications for alerts that changed state.
+func (e *Engine) evaluate() []Notification {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	elapsed := time.Since(e.start)
+	var changed []Notification
+	for _, o := range e.objectives {
+		budget := 1 - o.target
+		burnRates := make(map[time.Duration]float64)
+		for _, w := range e.windows {
+			good, bad := o.events.sum(elapsed, w)
+			sli := 1.0
+			if good+bad > 0 {
+				sli = good / (good + bad)
+			}
+			burnRates[w] = (1 - sli) / budget
+			label := formatWindow(w)
+			e.metrics.SLOIndicator.WithLabelValues(o.name, label).Set(sli)
+			e.metrics.SLOBurnRate.WithLabelValues(o.name, label).Set(burnRates[w])
+		}
+
+		// The budget spent is the burn rate over the window times the share of the window
+		// covered: until the consumer has run a whole window, the traffic of the rest of
+		// the window is assumed to be like the traffic seen so far
+		covered := min(elapsed, e.cfg.Window)
+		remaining := 1 - burnRates[e.cfg.Window]*covered.Seconds()/e.cfg.Window.Seconds()
+		e.metrics.SLOErrorBudgetRemaining.WithLabelValues(o.name).Set(remaining)
+
+		for _, r := range alertRules {
+			if r.long > e.cfg.Window {
+				continue
+			}
+			threshold := r.threshold(e.cfg.Window)
+			firing := burnRates[r.long] > threshold && burnRates[r.short] > threshold
+			e.metrics.SLOAlertFiring.WithLabelValues(o.name, r.name, r.severity).Set(boolToFloat(firing))
+			if firing == o.firing[r.name] {
+				continue
+			}
+			o.firing[r.name] = firing
+			n := Notification{
+				Status:                StatusResolved,
+				Objective:             o.name,
+				Alert:                 r.name,
+				Severity:              r.severity,
+				Target:                o.target,
+				Threshold:             threshold,
+				BurnRate:              map[string]float64{formatWindow(r.long): burnRates[r.long], formatWindow(r.short): burnRates[r.short]},
+				ErrorBudgetRemaining:  remaining,
+				DeliveryThresholdSecs: e.threshold.Seconds(),
+				Time:                  time.Now(),
+			}
+			if firing {
+				n.Status = StatusFiring
+				e.logger.Warn("SLO burn-rate alert firing", "objective", o.name, "alert", r.name, "severity", r.severity,
+					"burn_rate", n.BurnRate, "threshold", threshold, "error_budget_remaining", remaining)
+			} else {
+				e.logger.Info("SLO burn-rate alert resolved", "objective", o.name, "alert", r.name, "severity", r.severity)
+			}
+			changed = append(changed, n)
+		}
+	}
+	return changed
+}
+
+// formatWindow returns a window label such as "5m", "6h" or "7d".
+func formatWindow(w time.Duration) string {
+	switch {
+	case w%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", int(w/(24*time.Hour)))
+	case w%time.Hour == 0:
+		return fmt.Sprintf("%dh", int(w/time.Hour))
+	default:
+		return fmt.Sprintf("%dm", int(math.Round(w.Minutes())))
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
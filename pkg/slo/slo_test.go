@@ -0,0 +1,88 @@
+package slo
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/verify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestEngine(t *testing.T) (*Engine, *metrics.Metrics) {
+	t.Helper()
+	m := metrics.New(prometheus.NewRegistry())
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(config.Default().SLO, 5*time.Second, m, logger), m
+}
+
+func TestLateMatchCountsOnce(t *testing.T) {
+	e, m := newTestEngine(t)
+	e.Overdue(1)
+	e.Verified(verify.Late)
+	e.Verified(verify.Matched)
+	e.Verified(verify.Missing)
+	e.evaluate()
+
+	if sli := testutil.ToFloat64(m.SLOIndicator.WithLabelValues(ObjectiveZeroLoss, "5m")); sli != 1 {
+		t.Errorf("zero_loss SLI = %v, want 1 once the overdue message arrived", sli)
+	}
+	for result, want := range map[string]float64{"good": 2, "bad": 1, "corrected": 1} {
+		if got := testutil.ToFloat64(m.SLOEventsTotal.WithLabelValues(ObjectiveZeroLoss, result)); got != want {
+			t.Errorf("app_slo_events_total{result=%q} = %v, want %v", result, got, want)
+		}
+	}
+}
+
+func TestLossCountsBadEvents(t *testing.T) {
+	e, m := newTestEngine(t)
+	e.Verified(verify.Matched)
+	e.Verified(verify.Mismatch)
+	e.Overdue(2)
+	e.evaluate()
+
+	if sli := testutil.ToFloat64(m.SLOIndicator.WithLabelValues(ObjectiveZeroLoss, "5m")); sli != 0.25 {
+		t.Errorf("zero_loss SLI = %v, want 0.25", sli)
+	}
+}
+
+func TestCorrectionBeforeWindowDoesNotMakeBadNegative(t *testing.T) {
+	s := newSeries(time.Hour)
+	s.add(0, 0, 1)
+	s.add(10*time.Minute, 1, -1)
+
+	if good, bad := s.sum(10*time.Minute, 5*time.Minute); good != 1 || bad != 0 {
+		t.Errorf("5m window = %v good, %v bad; want 1, 0", good, bad)
+	}
+	if good, bad := s.sum(10*time.Minute, time.Hour); good != 1 || bad != 0 {
+		t.Errorf("1h window = %v good, %v bad; want 1, 0", good, bad)
+	}
+}
+
+func TestEvaluateNotifiesOnStateChange(t *testing.T) {
+	e, m := newTestEngine(t)
+	for range 10 {
+		e.Delivered(time.Minute)
+	}
+
+	fired := e.evaluate()
+	var fastBurn *Notification
+	for i, n := range fired {
+		if n.Objective == ObjectiveDeliveryLatency && n.Alert == "fast_burn" {
+			fastBurn = &fired[i]
+		}
+	}
+	if fastBurn == nil || fastBurn.Status != StatusFiring || fastBurn.Severity != "page" {
+		t.Fatalf("notifications after slow deliveries = %+v, want fast_burn firing", fired)
+	}
+	if got := testutil.ToFloat64(m.SLOAlertFiring.WithLabelValues(ObjectiveDeliveryLatency, "fast_burn", "page")); got != 1 {
+		t.Errorf("app_slo_alert_firing = %v, want 1", got)
+	}
+	if again := e.evaluate(); len(again) != 0 {
+		t.Errorf("notifications without a state change = %+v", again)
+	}
+}
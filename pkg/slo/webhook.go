@@ -0,0 +1,67 @@
+package slo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Alert statuses of a Notification.
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Notification is the JSON body posted to the webhook when a burn-rate alert changes state.
+type Notification struct {
+	Status    string  `json:"status"`
+	Objective string  `json:"objective"`
+	Alert     string  `json:"alert"`
+	Severity  string  `json:"severity"`
+	Target    float64 `json:"target"`
+	// Threshold is the burn rate both windows exceed while the alert fires; BurnRate holds
+	// the burn rate of each window keyed by its label ("1h", "5m").
+	Threshold             float64            `json:"threshold"`
+	BurnRate              map[string]float64 `json:"burn_rate"`
+	ErrorBudgetRemaining  float64            `json:"error_budget_remaining"`
+	DeliveryThresholdSecs float64            `json:"delivery_threshold_seconds"`
+	Time                  time.Time          `json:"time"`
+}
+
+// notify posts n to the webhook, if one is configured. Failures are logged and counted;
+// the next state change is sent regardless.
+func (e *Engine) notify(ctx context.Context, n Notification) {
+	if e.cfg.WebhookURL == "" {
+		return
+	}
+	if err := e.post(ctx, n); err != nil {
+		e.metrics.SLOWebhookNotificationsTotal.WithLabelValues(n.Status, "failed").Inc()
+		e.logger.Warn("Failed to send SLO alert to webhook", "objective", n.Objective, "alert", n.Alert, "status", n.Status, "error", err)
+		return
+	}
+	e.metrics.SLOWebhookNotificationsTotal.WithLabelValues(n.Status, "sent").Inc()
+}
+
+func (e *Engine) post(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package slo
+
+import "time"
+
+// bucketWidth is the resolution of the rolling windows.
+const bucketWidth = 10 * time.Second
+
+// counts holds the good and bad events of one bucket.
+type counts struct {
+	slot      int64 // bucket number since the engine started; stale when it differs
+	good, bad float64
+}
+
+// series counts events in fixed-width buckets covering the SLO window, as a ring. Time is
+// the elapsed monotonic time since the engine started, so a shifted wall clock does not
+// move events between windows.
+type series struct {
+	buckets []counts
+}
+
+func newSeries(window time.Duration) *series {
+	n := int(window / bucketWidth)
+	if window%bucketWidth != 0 {
+		n++
+	}
+	return &series{buckets: make([]counts, n)}
+}
+
+func slotAt(elapsed time.Duration) int64 {
+	return int64(elapsed / bucketWidth)
+}
+
+func (s *series) add(elapsed time.Duration, good, bad float64) {
+	slot := slotAt(elapsed)
+	b := &s.buckets[slot%int64(len(s.buckets))]
+	if b.slot != slot {
+		*b = counts{slot: slot}
+	}
+	b.good += good
+	b.bad += bad
+}
+
+// sum returns the events of the window ending at elapsed, limited to the SLO window. A
+// correction whose bad event lies before the window does not make bad negative.
+func (s *series) sum(elapsed, window time.Duration) (good, bad float64) {
+	last := slotAt(elapsed)
+	n := min(int64(window/bucketWidth), int64(len(s.buckets)))
+	for i := int64(0); i < n && i <= last; i++ {
+		slot := last - i
+		if b := s.buckets[slot%int64(len(s.buckets))]; b.slot == slot {
+			good += b.good
+			bad += b.bad
+		}
+	}
+	return good, max(bad, 0)
+}
@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RedisHook records Redis commands issued within a traced message as child spans. Commands
+// without a span in their context (SLO scans, health pings) are not traced.
+func RedisHook() redis.Hook {
+	return redisHook{}
+}
+
+type redisHook struct{}
+
+func (redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return next(ctx, cmd)
+		}
+		ctx, span := tracer().Start(ctx, "redis "+cmd.Name(),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String(attrDBSystem, "redis"),
+				attribute.String(attrDBOperation, cmd.Name()),
+			))
+		defer span.End()
+		err := next(ctx, cmd)
+		if err != nil && err != redis.Nil {
+			RecordError(span, err, "")
+		}
+		return err
+	}
+}
+
+func (redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return next(ctx, cmds)
+		}
+		ctx, span := tracer().Start(ctx, "redis pipeline",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String(attrDBSystem, "redis"),
+				attribute.String(attrDBOperation, "pipeline"),
+				attribute.Int(attrDBOperationBatchCount, len(cmds)),
+			))
+		defer span.End()
+		err := next(ctx, cmds)
+		if err != nil && err != redis.Nil {
+			RecordError(span, err, "")
+		}
+		return err
+	}
+}
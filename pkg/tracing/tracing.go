@@ -0,0 +1,203 @@
+// Package tracing sets up OpenTelemetry tracing and carries the W3C trace context of a
+// message in its Kafka headers, so that a consumed message continues the producer's trace.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/patsevanton/strimzi-kafka-chaos-testing"
+
+// Attribute keys from the OpenTelemetry messaging and database semantic conventions.
+const (
+	attrMessagingSystem       = "messaging.system"
+	attrMessagingOperation    = "messaging.operation.type"
+	attrMessagingDestination  = "messaging.destination.name"
+	attrMessagingPartition    = "messaging.destination.partition.id"
+	attrMessagingMessageID    = "messaging.message.id"
+	attrMessagingKafkaKey     = "messaging.kafka.message.key"
+	attrMessagingKafkaOffset  = "messaging.kafka.offset"
+	attrMessagingConsumerGrp  = "messaging.consumer.group.name"
+	attrErrorType             = "error.type"
+	attrDBSystem              = "db.system.name"
+	attrDBOperation           = "db.operation.name"
+	attrDBOperationBatchCount = "db.operation.batch.size"
+)
+
+// Setup installs the global tracer provider and W3C trace context propagator from cfg.
+// With the "none" exporter spans are not recorded. The returned function flushes pending
+// spans and must be called before exit.
+func Setup(ctx context.Context, cfg *config.Config, logger *slog.Logger) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	tc := cfg.Tracing
+	if tc.Exporter == config.TracingNone {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var closeFile func() error
+	switch tc.Exporter {
+	case config.TracingOTLP:
+		var opts []otlptracehttp.Option
+		if tc.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(tc.OTLPEndpoint))
+		}
+		e, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+		}
+		exporter = e
+	case config.TracingFile:
+		f, err := os.OpenFile(tc.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		e, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to create file trace exporter: %w", err)
+		}
+		exporter, closeFile = e, f.Close
+	}
+
+	serviceName := tc.ServiceName
+	if serviceName == "" {
+		serviceName = "kafka-" + cfg.Mode
+	}
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithHost(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+	)
+	if err != nil {
+		logger.Warn("Incomplete trace resource", "error", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// The consumer follows the producer's decision; only new traces are sampled by ratio.
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(tc.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	logger.Info("Tracing enabled", "exporter", tc.Exporter, "service_name", serviceName, "sample_ratio", tc.SampleRatio)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeFile != nil {
+			if cerr := closeFile(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}, nil
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts an internal child span, e.g. for encoding or a Schema Registry call.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartProduce starts the span of producing one message with key and id to topic. It is a
+// root span: every produced message starts a trace.
+func StartProduce(ctx context.Context, topic, key string, id int64) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "send "+topic,
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String(attrMessagingSystem, "kafka"),
+			attribute.String(attrMessagingOperation, "send"),
+			attribute.String(attrMessagingDestination, topic),
+			attribute.String(attrMessagingKafkaKey, key),
+			attribute.String(attrMessagingMessageID, strconv.FormatInt(id, 10)),
+		))
+}
+
+// StartConsume starts the span of processing msg, continuing the trace carried in its
+// headers.
+func StartConsume(ctx context.Context, groupID string, msg kafka.Message) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier{Headers: &msg.Headers})
+	return tracer().Start(ctx, "process "+msg.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String(attrMessagingSystem, "kafka"),
+			attribute.String(attrMessagingOperation, "process"),
+			attribute.String(attrMessagingDestination, msg.Topic),
+			attribute.String(attrMessagingPartition, strconv.Itoa(msg.Partition)),
+			attribute.Int64(attrMessagingKafkaOffset, msg.Offset),
+			attribute.String(attrMessagingKafkaKey, string(msg.Key)),
+			attribute.String(attrMessagingConsumerGrp, groupID),
+		))
+}
+
+// Inject writes the trace context of ctx into the headers of msg.
+func Inject(ctx context.Context, msg *kafka.Message) {
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier{Headers: &msg.Headers})
+}
+
+// SetPartition records the partition a produced message was written to.
+func SetPartition(span trace.Span, partition string) {
+	span.SetAttributes(attribute.String(attrMessagingPartition, partition))
+}
+
+// RecordError marks span as failed with err, classified as errorType.
+func RecordError(span trace.Span, err error, errorType string) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	if errorType != "" {
+		span.SetAttributes(attribute.String(attrErrorType, errorType))
+	}
+}
+
+// HeaderCarrier adapts Kafka message headers to a propagation.TextMapCarrier.
+type HeaderCarrier struct {
+	Headers *[]kafka.Header
+}
+
+func (c HeaderCarrier) Get(key string) string {
+	for _, h := range *c.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	for i, h := range *c.Headers {
+		if h.Key == key {
+			(*c.Headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.Headers = append(*c.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.Headers))
+	for _, h := range *c.Headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
@@ -0,0 +1,201 @@
+package verify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+// Spool operations: a producer SET of the sent hash, or a consumer verification whose
+// Redis round-trip failed.
+const (
+	spoolOpSet    = "set"
+	spoolOpVerify = "verify"
+)
+
+// record is a verification side-channel write that failed and waits for replay.
+type record struct {
+	Op        string `json:"op"`
+	Key       string `json:"key"`
+	Value     string `json:"value,omitempty"`     // set: contentHash:timestamp_ms
+	Hash      string `json:"hash,omitempty"`      // verify: content hash of the received message
+	FullHash  string `json:"full_hash,omitempty"` // verify: hash of the raw value (old schema fallback)
+	Topic     string `json:"topic"`
+	Partition string `json:"partition,omitempty"`
+}
+
+// spool buffers failed Redis verification writes in memory and, when a file is
+// configured, on disk so that they survive a restart. Records are replayed in order; when
+// the spool is full new records are dropped and counted, which is the only way the
+// verification side-channel itself loses data.
+type spool struct {
+	maxRecords int
+	file       string
+	keyTTL     time.Duration // of replayed SETs
+	markerTTL  time.Duration // of verified markers left by replayed verifications
+	metrics    *metrics.Metrics
+	logger     *slog.Logger
+	observer   Observer // nil: outcomes of replayed verifications are not reported
+
+	mu      sync.Mutex
+	records []record
+}
+
+func newSpool(maxRecords int, file string, keyTTL, markerTTL time.Duration, m *metrics.Metrics, logger *slog.Logger) *spool {
+	s := &spool{maxRecords: maxRecords, file: file, keyTTL: keyTTL, markerTTL: markerTTL, metrics: m, logger: logger}
+	if file != "" {
+		if err := s.load(); err != nil {
+			s.logger.Warn("Failed to load Redis spool file", "path", file, "error", err)
+		}
+	}
+	return s
+}
+
+// add spools rec, returning false when the spool is disabled or full.
+func (s *spool) add(rec record) bool {
+	if s.maxRecords <= 0 {
+		s.metrics.RedisVerificationDroppedTotal.WithLabelValues(rec.Op).Inc()
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.records) >= s.maxRecords {
+		s.metrics.RedisVerificationDroppedTotal.WithLabelValues(rec.Op).Inc()
+		return false
+	}
+	s.records = append(s.records, rec)
+	if s.file != "" {
+		if err := s.appendFile(rec); err != nil {
+			s.logger.Warn("Failed to persist Redis spool record", "path", s.file, "error", err)
+		}
+	}
+	s.metrics.RedisVerificationSpooledTotal.WithLabelValues(rec.Op).Inc()
+	s.metrics.RedisVerificationSpoolSize.Set(float64(len(s.records)))
+	return true
+}
+
+// replay retries spooled records every interval until ctx is cancelled.
+func (s *spool) replay(ctx context.Context, rdb *redis.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.replayOnce(ctx, rdb)
+		}
+	}
+}
+
+// replayOnce applies spooled records in order and stops at the first failure, leaving the
+// rest for the next round.
+func (s *spool) replayOnce(ctx context.Context, rdb *redis.Client) {
+	s.mu.Lock()
+	pending := append([]record(nil), s.records...)
+	s.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	done := 0
+	for _, rec := range pending {
+		if err := s.apply(ctx, rdb, rec); err != nil {
+			s.logger.Debug("Redis spool replay stopped", "error", err, "remaining", len(pending)-done)
+			break
+		}
+		s.metrics.RedisVerificationReplayedTotal.WithLabelValues(rec.Op).Inc()
+		done++
+	}
+	if done == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	s.records = s.records[done:]
+	remaining := len(s.records)
+	if s.file != "" {
+		if err := s.rewriteFile(); err != nil {
+			s.logger.Warn("Failed to rewrite Redis spool file", "path", s.file, "error", err)
+		}
+	}
+	s.mu.Unlock()
+	s.metrics.RedisVerificationSpoolSize.Set(float64(remaining))
+	s.logger.Info("Replayed spooled Redis verification records", "replayed", done, "remaining", remaining)
+}
+
+func (s *spool) apply(ctx context.Context, rdb *redis.Client, rec record) error {
+	switch rec.Op {
+	case spoolOpSet:
+		return replaySentHash(ctx, rdb, rec, s.keyTTL)
+	case spoolOpVerify:
+		outcome, err := verifyDelivery(ctx, rdb, rec, s.markerTTL, s.metrics, s.logger)
+		if err == nil && s.observer != nil {
+			s.observer.Verified(outcome)
+		}
+		return err
+	default:
+		s.logger.Warn("Unknown Redis spool record, discarding", "op", rec.Op, "key", rec.Key)
+		return nil
+	}
+}
+
+func (s *spool) load() error {
+	f, err := os.Open(s.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		s.records = append(s.records, rec)
+	}
+	s.metrics.RedisVerificationSpoolSize.Set(float64(len(s.records)))
+	if len(s.records) > 0 {
+		s.logger.Info("Loaded spooled Redis verification records", "path", s.file, "records", len(s.records))
+	}
+	return scanner.Err()
+}
+
+func (s *spool) appendFile(rec record) error {
+	f, err := os.OpenFile(s.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(rec)
+}
+
+func (s *spool) rewriteFile() error {
+	tmp := s.file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, rec := range s.records {
+		if err := enc.Encode(rec); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
@@ -0,0 +1,181 @@
+package verify
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+)
+
+const testKeyTTL = time.Hour
+
+func newTestVerifier(t *testing.T) (*Verifier, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	v := New(rdb, Options{KeyPrefix: "kafka-msg:", KeyTTL: testKeyTTL, SpoolMaxRecords: 10},
+		metrics.New(prometheus.NewRegistry()), logger)
+	return v, mr
+}
+
+// spoolSent spools the producer's SET of message key as if Redis had failed.
+func spoolSent(v *Verifier, key string, id int64, data string) {
+	value := hashContent(id, data) + ":" + strconv.FormatInt(time.Now().UnixMilli(), 10)
+	v.spool.add(record{Op: spoolOpSet, Key: v.opts.KeyPrefix + key, Value: value, Topic: "test-topic"})
+}
+
+func receive(ctx context.Context, v *Verifier, key string, id int64, data string) string {
+	msg := kafka.Message{Topic: "test-topic", Partition: 0, Key: []byte(key)}
+	return v.VerifyReceived(ctx, msg, map[string]interface{}{"id": id, "data": data})
+}
+
+type recordingObserver struct {
+	outcomes []string
+}
+
+func (o *recordingObserver) Verified(outcome string) { o.outcomes = append(o.outcomes, outcome) }
+func (o *recordingObserver) Overdue(int)             {}
+
+func counter(t *testing.T, mr *miniredis.Miniredis, key string) string {
+	t.Helper()
+	value, err := mr.Get(key)
+	if err != nil {
+		return "0"
+	}
+	return value
+}
+
+func TestVerifyBeforeReplayLeavesNoPendingKey(t *testing.T) {
+	ctx := context.Background()
+	v, mr := newTestVerifier(t)
+	spoolSent(v, "msg-1", 1, "payload")
+
+	if outcome := receive(ctx, v, "msg-1", 1, "payload"); outcome != Missing {
+		t.Fatalf("VerifyReceived before replay = %q, want %q", outcome, Missing)
+	}
+	// 120 rounds of the default 5s replay interval, shorter than the key TTL
+	if ttl := mr.TTL(redisKeyVerifiedPrefix + "kafka-msg:msg-1"); ttl != 10*time.Minute {
+		t.Errorf("verified marker TTL = %s, want %s", ttl, 10*time.Minute)
+	}
+
+	v.spool.replayOnce(ctx, v.rdb)
+	if mr.Exists("kafka-msg:msg-1") {
+		t.Error("replayed SET stored a key for a message already received")
+	}
+	if mr.Exists(redisKeyVerifiedPrefix + "kafka-msg:msg-1") {
+		t.Error("verified marker left after the replay")
+	}
+	if sent, received := counter(t, mr, redisKeySentTotal), counter(t, mr, redisKeyReceivedTotal); sent != "1" || received != "1" {
+		t.Errorf("sent_total, received_total = %s, %s; want 1, 1", sent, received)
+	}
+}
+
+func TestReplayBeforeVerifyMatches(t *testing.T) {
+	ctx := context.Background()
+	v, mr := newTestVerifier(t)
+	spoolSent(v, "msg-1", 1, "payload")
+
+	v.spool.replayOnce(ctx, v.rdb)
+	if ttl := mr.TTL("kafka-msg:msg-1"); ttl != testKeyTTL {
+		t.Errorf("replayed key TTL = %s, want %s", ttl, testKeyTTL)
+	}
+	if outcome := receive(ctx, v, "msg-1", 1, "payload"); outcome != Matched {
+		t.Fatalf("VerifyReceived after replay = %q, want %q", outcome, Matched)
+	}
+	if mr.Exists("kafka-msg:msg-1") || mr.Exists(redisKeyVerifiedPrefix+"kafka-msg:msg-1") {
+		t.Error("keys left after a matched verification")
+	}
+}
+
+func TestReplayAfterMismatchingVerifyDoesNotCountReceived(t *testing.T) {
+	ctx := context.Background()
+	v, mr := newTestVerifier(t)
+	spoolSent(v, "msg-1", 1, "payload")
+
+	receive(ctx, v, "msg-1", 1, "changed")
+	v.spool.replayOnce(ctx, v.rdb)
+	if mr.Exists("kafka-msg:msg-1") {
+		t.Error("replayed SET stored a key for a message already received")
+	}
+	if received := counter(t, mr, redisKeyReceivedTotal); received != "0" {
+		t.Errorf("received_total = %s, want 0 for a changed body", received)
+	}
+
+	observer := &recordingObserver{}
+	v.opts.Observer = observer
+	v.collectReplayed(ctx)
+	if got := testutil.ToFloat64(v.metrics.ConsumerRedisHashMismatchTotal.WithLabelValues("test-topic", "0")); got != 1 {
+		t.Errorf("consumer_redis_hash_mismatch_total = %v, want 1", got)
+	}
+	if !slices.Equal(observer.outcomes, []string{Mismatch}) {
+		t.Errorf("observed outcomes = %v, want [%s]", observer.outcomes, Mismatch)
+	}
+}
+
+func TestReplayAfterMatchingVerifyReachesObserver(t *testing.T) {
+	ctx := context.Background()
+	v, mr := newTestVerifier(t)
+	observer := &recordingObserver{}
+	v.opts.Observer = observer
+	spoolSent(v, "msg-1", 1, "payload")
+
+	receive(ctx, v, "msg-1", 1, "payload")
+	v.spool.replayOnce(ctx, v.rdb)
+	v.collectReplayed(ctx)
+	if !slices.Equal(observer.outcomes, []string{Missing, Matched}) {
+		t.Errorf("observed outcomes = %v, want [%s %s]", observer.outcomes, Missing, Matched)
+	}
+	if got := testutil.ToFloat64(v.metrics.ConsumerRedisHashMismatchTotal.WithLabelValues("test-topic", "0")); got != 0 {
+		t.Errorf("consumer_redis_hash_mismatch_total = %v, want 0", got)
+	}
+	if mr.Exists(redisKeyReplayed) {
+		t.Error("replayed verifications left after they were collected")
+	}
+}
+
+func TestMatchAfterOverdueIsLate(t *testing.T) {
+	ctx := context.Background()
+	v, mr := newTestVerifier(t)
+	spoolSent(v, "msg-1", 1, "payload")
+	spoolSent(v, "msg-2", 2, "payload")
+	v.spool.replayOnce(ctx, v.rdb)
+	mr.SAdd(redisKeyOverdue, "kafka-msg:msg-1")
+
+	if outcome := receive(ctx, v, "msg-1", 1, "payload"); outcome != Late {
+		t.Errorf("VerifyReceived of an overdue message = %q, want %q", outcome, Late)
+	}
+	if outcome := receive(ctx, v, "msg-2", 2, "payload"); outcome != Matched {
+		t.Errorf("VerifyReceived of a pending message = %q, want %q", outcome, Matched)
+	}
+	if ok, _ := mr.SIsMember(redisKeyOverdue, "kafka-msg:msg-1"); ok {
+		t.Error("late message left in the overdue set")
+	}
+}
+
+func TestSpooledSetKeepsTheTopicItWasSentTo(t *testing.T) {
+	ctx := context.Background()
+	v, mr := newTestVerifier(t)
+	mr.SetError("LOADING")
+	v.RecordSent(ctx, "other-topic", "msg-1", 1, "payload")
+	mr.SetError("")
+
+	msg := kafka.Message{Topic: "other-topic", Partition: 3, Key: []byte("msg-1")}
+	v.VerifyReceived(ctx, msg, map[string]interface{}{"id": int64(1), "data": "changed"})
+	v.spool.replayOnce(ctx, v.rdb)
+	v.collectReplayed(ctx)
+	if got := testutil.ToFloat64(v.metrics.ConsumerRedisHashMismatchTotal.WithLabelValues("other-topic", "3")); got != 1 {
+		t.Errorf("consumer_redis_hash_mismatch_total{topic=other-topic,partition=3} = %v, want 1", got)
+	}
+}
@@ -0,0 +1,440 @@
+// Package verify checks end-to-end delivery through a Redis side channel: the producer
+// stores a content hash per message and the consumer compares and deletes it. Keys left
+// behind are undelivered messages; their age is tracked against the delivery SLO.
+package verify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/config"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/health"
+	"github.com/patsevanton/strimzi-kafka-chaos-testing/pkg/metrics"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	redisKeySentTotal     = "metrics:sent_total"
+	redisKeyReceivedTotal = "metrics:received_total"
+	// redisKeyOverdue is the set of pending keys already reported as overdue, shared by
+	// the consumer replicas so that each message is reported once.
+	redisKeyOverdue = "metrics:overdue"
+	// redisKeyVerifiedPrefix prefixes the marker a consumer leaves for a message whose key
+	// it did not find: the producer's SET may still wait in its spool, and its replay must
+	// not store a key that no consumer will delete.
+	redisKeyVerifiedPrefix = "verified:"
+	// redisKeyReplayed is the list of verifications completed by a producer's replay (see
+	// replaySentHash). The consumer replicas pop them, so each one reaches the metrics and
+	// the SLO once; the list is trimmed to maxReplayed entries if no consumer drains it.
+	redisKeyReplayed = "metrics:replayed"
+	maxReplayed      = 10000
+	// markerReplayRounds is the number of spool replay rounds a verified marker waits for
+	// the producer's spooled SET; after that no replay is expected to be pending any more.
+	markerReplayRounds = 120
+)
+
+// Observer receives delivery verification results, e.g. for SLO evaluation.
+type Observer interface {
+	// Verified is called with the outcome of every completed verification, including
+	// replayed ones: Matched, Late, Mismatch or Missing.
+	Verified(outcome string)
+	// Overdue is called with the number of pending messages that passed the SLO
+	// threshold undelivered since the last call.
+	Overdue(n int)
+}
+
+// Options configures a Verifier.
+type Options struct {
+	// KeyPrefix is prepended to the Kafka message key to form the Redis key.
+	KeyPrefix string
+	// KeyTTL is the expiry of the keys of sent messages. Verified markers expire after
+	// markerReplayRounds rounds of SpoolReplayInterval, or KeyTTL when that is shorter.
+	KeyTTL time.Duration
+	// Failed Redis writes are spooled (in SpoolFile when set) and replayed every
+	// SpoolReplayInterval; SpoolMaxRecords 0 disables spooling.
+	SpoolMaxRecords     int
+	SpoolFile           string
+	SpoolReplayInterval time.Duration
+	// SLOThreshold enables the pending messages gauges on the consumer side; 0 disables them.
+	SLOThreshold time.Duration
+	// Observer, when set, receives verification outcomes and overdue messages.
+	Observer Observer
+}
+
+// Verifier records sent messages and verifies received ones against Redis.
+type Verifier struct {
+	rdb       *redis.Client
+	opts      Options
+	markerTTL time.Duration
+	spool     *spool
+	metrics   *metrics.Metrics
+	logger    *slog.Logger
+}
+
+// New creates a Verifier on rdb. Run must be running for spooled records to be replayed.
+func New(rdb *redis.Client, opts Options, m *metrics.Metrics, logger *slog.Logger) *Verifier {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if opts.SpoolReplayInterval <= 0 {
+		opts.SpoolReplayInterval = 5 * time.Second
+	}
+	markerTTL := opts.SpoolReplayInterval * markerReplayRounds
+	if opts.KeyTTL > 0 {
+		markerTTL = min(markerTTL, opts.KeyTTL)
+	}
+	spool := newSpool(opts.SpoolMaxRecords, opts.SpoolFile, opts.KeyTTL, markerTTL, m, logger)
+	spool.observer = opts.Observer
+	return &Verifier{
+		rdb:       rdb,
+		opts:      opts,
+		markerTTL: markerTTL,
+		spool:     spool,
+		metrics:   m,
+		logger:    logger,
+	}
+}
+
+// Run replays spooled records and updates the SLO metrics until ctx is cancelled.
+func (v *Verifier) Run(ctx context.Context) error {
+	if v.opts.SLOThreshold > 0 {
+		go v.updateSLOMetrics(ctx)
+	}
+	v.spool.replay(ctx, v.rdb, v.opts.SpoolReplayInterval)
+	return nil
+}
+
+// RecordSent stores the content hash of a message sent to topic (id+data only, so timestamp
+// retries don't cause mismatch) under the Redis key for kafkaKey. Failed writes are spooled.
+func (v *Verifier) RecordSent(ctx context.Context, topic, kafkaKey string, id int64, data string) {
+	redisKey := v.opts.KeyPrefix + kafkaKey
+	redisVal := hashContent(id, data) + ":" + strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if err := storeSentHash(ctx, v.rdb, redisKey, redisVal, v.opts.KeyTTL); err != nil {
+		spooled := v.spool.add(record{Op: spoolOpSet, Key: redisKey, Value: redisVal, Topic: topic})
+		v.logger.Warn("Redis hash write failed", "key", redisKey, "error", err, "spooled", spooled)
+	}
+}
+
+// VerifyReceived compares a consumed message with the hash stored by the producer; decoded
+// is the decoded Avro value of msg. It returns Matched, Late, Mismatch or Missing, or "" when
+// Redis failed and the verification was spooled for replay.
+func (v *Verifier) VerifyReceived(ctx context.Context, msg kafka.Message, decoded interface{}) string {
+	rec := record{
+		Op:        spoolOpVerify,
+		Key:       v.opts.KeyPrefix + string(msg.Key),
+		Topic:     msg.Topic,
+		Partition: strconv.Itoa(msg.Partition),
+	}
+	if id, data := extractIDAndData(decoded); id != nil && data != "" {
+		rec.Hash = hashContent(*id, data)
+	} else {
+		rec.FullHash = hashValue(msg.Value)
+	}
+	outcome, err := verifyDelivery(ctx, v.rdb, rec, v.markerTTL, v.metrics, v.logger)
+	if err != nil {
+		spooled := v.spool.add(rec)
+		v.logger.Warn("Redis verification failed", "key", rec.Key, "error", err, "spooled", spooled)
+		return ""
+	}
+	if v.opts.Observer != nil {
+		v.opts.Observer.Verified(outcome)
+	}
+	return outcome
+}
+
+// storeSentHash records a sent message for delivery verification: key = same as Kafka key,
+// value = contentHash:timestamp_ms (for SLO). SET and the sent_total increment run in one
+// transaction so that a replay never double-counts.
+func storeSentHash(ctx context.Context, rdb *redis.Client, key, value string, ttl time.Duration) error {
+	_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, value, ttl)
+		pipe.Incr(ctx, redisKeySentTotal)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis SET: %w", err)
+	}
+	return nil
+}
+
+// replayedVerification is a verification completed by a producer's replay, queued in
+// redisKeyReplayed for the consumers.
+type replayedVerification struct {
+	Outcome   string `json:"outcome"` // Matched or Mismatch
+	Key       string `json:"key"`
+	Topic     string `json:"topic"`
+	Partition string `json:"partition"`
+	Expected  string `json:"expected,omitempty"`
+	Got       string `json:"got,omitempty"`
+}
+
+// replaySentHash stores a spooled SET like storeSentHash, unless a consumer has already
+// received the message and left a verified marker: then the message counts as sent, and
+// as received when the hashes match, the marker is removed and the outcome is queued for
+// the consumers (see Verifier.collectReplayed). WATCH on the marker keeps a consumer from
+// leaving one between the check and the SET.
+func replaySentHash(ctx context.Context, rdb *redis.Client, rec record, ttl time.Duration) error {
+	marker := redisKeyVerifiedPrefix + rec.Key
+	err := rdb.Watch(ctx, func(tx *redis.Tx) error {
+		received, err := tx.Get(ctx, marker).Result()
+		if err == redis.Nil {
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, rec.Key, rec.Value, ttl)
+				pipe.Incr(ctx, redisKeySentTotal)
+				return nil
+			})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		sentHash, _, _ := strings.Cut(rec.Value, ":")
+		receivedHash, partition, _ := strings.Cut(received, ":")
+		replayed := replayedVerification{Outcome: Matched, Key: rec.Key, Topic: rec.Topic, Partition: partition}
+		if receivedHash != sentHash {
+			replayed.Outcome, replayed.Expected, replayed.Got = Mismatch, sentHash, receivedHash
+		}
+		entry, err := json.Marshal(replayed)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, marker)
+			pipe.Incr(ctx, redisKeySentTotal)
+			if replayed.Outcome == Matched {
+				pipe.Incr(ctx, redisKeyReceivedTotal)
+			}
+			pipe.RPush(ctx, redisKeyReplayed, entry)
+			pipe.LTrim(ctx, redisKeyReplayed, -maxReplayed, -1)
+			return nil
+		})
+		return err
+	}, marker)
+	if err != nil {
+		return fmt.Errorf("redis SET: %w", err)
+	}
+	return nil
+}
+
+// Verification outcomes returned by Verifier.VerifyReceived.
+const (
+	Matched = "matched"
+	// Late is a match of a message that had already been reported overdue.
+	Late     = "late"
+	Mismatch = "mismatch"
+	Missing  = "missing"
+)
+
+// verifyDelivery compares the received content hash (id+data only) with the hash stored by
+// the producer. Match → same message (timestamp difference OK): the key is deleted and
+// received_total incremented. Mismatch → body changed, data integrity issue. Missing → a
+// verified marker (hash:partition) expiring after markerTTL is left for a SET still
+// spooled by the producer (see replaySentHash). A non-nil error means the verification itself failed and should be
+// retried.
+func verifyDelivery(ctx context.Context, rdb *redis.Client, rec record, markerTTL time.Duration, m *metrics.Metrics, logger *slog.Logger) (string, error) {
+	marker := redisKeyVerifiedPrefix + rec.Key
+	stored, err := rdb.Get(ctx, rec.Key).Result()
+	if err == redis.Nil {
+		// Key not in Redis: a duplicate, a producer without Redis or a SET still spooled.
+		// The replay may have stored the key before it saw the marker, so look once more.
+		hash := rec.Hash
+		if hash == "" {
+			hash = rec.FullHash
+		}
+		if err := rdb.Set(ctx, marker, hash+":"+rec.Partition, markerTTL).Err(); err != nil {
+			return "", fmt.Errorf("redis SET: %w", err)
+		}
+		stored, err = rdb.Get(ctx, rec.Key).Result()
+		if err == redis.Nil {
+			return Missing, nil
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("redis GET: %w", err)
+	}
+	expectedStoredHash, _, _ := strings.Cut(stored, ":")
+
+	matched := rec.Hash != "" && rec.Hash == expectedStoredHash
+	if rec.Hash == "" {
+		// Fallback: no id/data in decoded (e.g. old schema); compare full value hash for backward compat
+		matched = rec.FullHash == expectedStoredHash
+	}
+	if !matched {
+		if rec.Hash == "" {
+			return Missing, nil
+		}
+		logger.Error("Body mismatch: message body (id+data) does not match Redis", "key", rec.Key, "expected", expectedStoredHash, "got", rec.Hash)
+		m.ConsumerRedisHashMismatchTotal.WithLabelValues(rec.Topic, rec.Partition).Inc()
+		return Mismatch, nil
+	}
+	var overdue *redis.IntCmd
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, rec.Key, marker)
+		overdue = pipe.SRem(ctx, redisKeyOverdue, rec.Key)
+		pipe.Incr(ctx, redisKeyReceivedTotal)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("redis DEL: %w", err)
+	}
+	if overdue.Val() > 0 {
+		return Late, nil
+	}
+	return Matched, nil
+}
+
+// hashValue returns SHA256 hex of data (used for backward compatibility).
+func hashValue(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// hashContent returns SHA256 hex of message body (id + data only). Used for delivery
+// verification so that timestamp differences (retries/duplicates) do not count as mismatch.
+func hashContent(id int64, data string) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%d\n%s", id, data)))
+	return hex.EncodeToString(h[:])
+}
+
+// extractIDAndData returns id and data from decoded Avro message for content-hash verification.
+// Returns (nil, "") if decoded is not a map or id/data are missing or have wrong types.
+func extractIDAndData(decoded interface{}) (*int64, string) {
+	m, ok := decoded.(map[string]interface{})
+	if !ok {
+		return nil, ""
+	}
+	var id int64
+	switch v := m["id"].(type) {
+	case int64:
+		id = v
+	case int32:
+		id = int64(v)
+	case int:
+		id = int64(v)
+	case float64:
+		id = int64(v)
+	default:
+		return nil, ""
+	}
+	data, _ := m["data"].(string)
+	return &id, data
+}
+
+// collectReplayed pops the verifications completed by producers' replays and reports them
+// like those of VerifyReceived: a mismatch is logged and counted, and every outcome reaches
+// the Observer. The consumer counted these messages as Missing when it received them.
+func (v *Verifier) collectReplayed(ctx context.Context) {
+	for {
+		entries, err := v.rdb.LPopCount(ctx, redisKeyReplayed, 100).Result()
+		if err != nil {
+			if err != redis.Nil {
+				v.logger.Debug("Redis LPOP error", "error", err)
+			}
+			return
+		}
+		for _, entry := range entries {
+			var replayed replayedVerification
+			if err := json.Unmarshal([]byte(entry), &replayed); err != nil {
+				continue
+			}
+			if replayed.Outcome == Mismatch {
+				v.logger.Error("Body mismatch: received message does not match the spooled Redis record",
+					"key", replayed.Key, "expected", replayed.Expected, "got", replayed.Got)
+				v.metrics.ConsumerRedisHashMismatchTotal.WithLabelValues(replayed.Topic, replayed.Partition).Inc()
+			}
+			if v.opts.Observer != nil {
+				v.opts.Observer.Verified(replayed.Outcome)
+			}
+		}
+	}
+}
+
+// updateSLOMetrics periodically counts pending messages in Redis and those older than SLO
+// threshold, and collects the verifications completed by replays.
+func (v *Verifier) updateSLOMetrics(ctx context.Context) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	sloThreshold := v.opts.SLOThreshold
+	prefix := v.opts.KeyPrefix
+	rdb := v.rdb
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			v.collectReplayed(ctx)
+			var pending, oldPending int
+			var overdue []interface{}
+			iter := rdb.Scan(ctx, 0, prefix+"*", 100).Iterator()
+			for iter.Next(ctx) {
+				// The config keeps the prefix apart from the metrics: and verified: keys
+				key := iter.Val()
+				pending++
+				val, err := rdb.Get(ctx, key).Result()
+				if err != nil {
+					continue
+				}
+				parts := strings.SplitN(val, ":", 2)
+				if len(parts) != 2 {
+					continue
+				}
+				tsMs, err := strconv.ParseInt(parts[1], 10, 64)
+				if err != nil {
+					continue
+				}
+				if time.Since(time.UnixMilli(tsMs)) > sloThreshold {
+					oldPending++
+					overdue = append(overdue, key)
+				}
+			}
+			if err := iter.Err(); err != nil {
+				v.logger.Debug("Redis SCAN error", "error", err)
+				continue
+			}
+			v.metrics.RedisPendingMessages.Set(float64(pending))
+			v.metrics.RedisPendingOldMessages.Set(float64(oldPending))
+			if v.opts.Observer != nil && len(overdue) > 0 {
+				// SADD counts only keys no replica has reported yet
+				added, err := rdb.SAdd(ctx, redisKeyOverdue, overdue...).Result()
+				if err != nil {
+					v.logger.Debug("Redis SADD error", "error", err)
+					continue
+				}
+				v.opts.Observer.Overdue(int(added))
+			}
+		}
+	}
+}
+
+// HealthCheck returns a check that pings Redis with rdb.
+func HealthCheck(rdb *redis.Client) health.Check {
+	return health.Check{
+		Name:  config.DependencyRedis,
+		Group: config.DependencyRedis,
+		Probe: func(ctx context.Context) error {
+			return rdb.Ping(ctx).Err()
+		},
+	}
+}
+
+// NewRedisClient creates a Redis client for addr; hooks (e.g. a fault injector) are added in order.
+func NewRedisClient(addr, password string, hooks ...redis.Hook) *redis.Client {
+	opts := &redis.Options{Addr: addr}
+	if password != "" {
+		opts.Password = password
+	}
+	client := redis.NewClient(opts)
+	for _, hook := range hooks {
+		client.AddHook(hook)
+	}
+	return client
+}